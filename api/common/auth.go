@@ -11,65 +11,147 @@ import (
 )
 
 type AuthI interface {
-	GenerateToken(id int, username, email string, role Role) (string, error)
-	ValidateToken(role Role, shouldMatchUserID bool) gin.HandlerFunc
+	GenerateToken(id int, username, email string, roles []RoleName, permissions []string, mfa bool) (string, error)
+	ValidateToken(role RoleName, shouldMatchUserID bool) gin.HandlerFunc
+	RequirePermission(permission string) gin.HandlerFunc
 }
 
-func NewAuth(secret string, sessionDurationDays int) *Auth {
+func NewAuth(keySet *KeySet, accessTokenMinutes int, mfaRequiredForAdmins bool, revocationStore RevocationStore) *Auth {
 	return &Auth{
-		secret:              secret,
-		sessionDurationDays: sessionDurationDays,
+		keySet:               keySet,
+		accessTokenMinutes:   accessTokenMinutes,
+		mfaRequiredForAdmins: mfaRequiredForAdmins,
+		revocationStore:      revocationStore,
 	}
 }
 
 type Auth struct {
-	secret              string
-	sessionDurationDays int
+	keySet               *KeySet
+	accessTokenMinutes   int
+	mfaRequiredForAdmins bool
+	revocationStore      RevocationStore
 }
 
-type Role string
+// RoleName is the name of a Role. The roles themselves and their permissions live on the DB.
+type RoleName string
 
 const (
-	AnyRole   Role = "any"
-	UserRole  Role = "user"
-	AdminRole Role = "admin"
+	AnyRole   RoleName = "any"
+	UserRole  RoleName = "user"
+	AdminRole RoleName = "admin"
+)
+
+const (
+	mfaChallengePurpose = "mfa_challenge"
+	mfaChallengeMinutes = 5
 )
 
 type CustomClaims struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Role     Role   `json:"role"`
+	Username    string     `json:"username"`
+	Email       string     `json:"email"`
+	Roles       []RoleName `json:"roles"`
+	Permissions []string   `json:"permissions"`
+	MFA         bool       `json:"mfa,omitempty"`     // Logged in with a second factor
+	Purpose     string     `json:"purpose,omitempty"` // Empty on access tokens
 	jwt.RegisteredClaims
 }
 
-func (auth *Auth) GenerateToken(id int, username, email string, role Role) (string, error) {
+func (claims *CustomClaims) HasRole(role RoleName) bool {
+	for _, claimsRole := range claims.Roles {
+		if claimsRole == role {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateToken generates an access token. mfa is true if the user logged in with a second factor.
+// Roles and permissions are embedded, so changes to them apply when the token is refreshed.
+func (auth *Auth) GenerateToken(id int, username, email string, roles []RoleName, permissions []string, mfa bool) (string, error) {
 
 	var (
 		issuedAt  = time.Now()
-		expiresAt = time.Now().Add(time.Hour * 24 * time.Duration(auth.sessionDurationDays))
+		expiresAt = time.Now().Add(time.Minute * time.Duration(auth.accessTokenMinutes))
 	)
 
-	// Generate claims containing Username, Email, Role and ID
+	// Each token has its own ID, so it can be revoked on its own
+	tokenID, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	// Generate claims containing Username, Email, Roles, Permissions, User ID (subject) and Token ID
 	claims := &CustomClaims{
-		Username: username,
-		Email:    email,
-		Role:     role,
+		Username:    username,
+		Email:       email,
+		Roles:       roles,
+		Permissions: permissions,
+		MFA:         mfa,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ID:        fmt.Sprint(id),
+			ID:        tokenID,
+			Subject:   fmt.Sprint(id),
 			IssuedAt:  jwt.NewNumericDate(issuedAt),
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
 		},
 	}
 
-	// Generate token (struct)
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return auth.signClaims(claims)
+}
+
+// GenerateChallengeToken generates a short-lived token that only proves the user passed the password check.
+// It can't be used as an access token, only exchanged for one along with a valid second factor.
+func (auth *Auth) GenerateChallengeToken(id int) (string, error) {
+	tokenID, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	claims := &CustomClaims{
+		Purpose: mfaChallengePurpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Subject:   fmt.Sprint(id),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute * time.Duration(mfaChallengeMinutes))),
+		},
+	}
+
+	return auth.signClaims(claims)
+}
+
+// ValidateChallengeToken returns the claims of a valid, non revoked challenge token
+func (auth *Auth) ValidateChallengeToken(tokenString string) (*CustomClaims, error) {
+	token, err := auth.decodeTokenString(tokenString)
+	if err != nil {
+		return nil, Wrap("auth.decodeTokenString", ErrInvalidMFAChallenge)
+	}
+
+	claims, ok := token.Claims.(*CustomClaims)
+	if !ok || !token.Valid || claims.Purpose != mfaChallengePurpose {
+		return nil, Wrap("!token.Valid || claims.Purpose != mfaChallengePurpose", ErrInvalidMFAChallenge)
+	}
+
+	userID, _ := strconv.Atoi(claims.Subject)
+	if err := auth.checkNotRevoked(userID, claims); err != nil {
+		return nil, Wrap("auth.checkNotRevoked", ErrInvalidMFAChallenge)
+	}
+
+	return claims, nil
+}
+
+func (auth *Auth) signClaims(claims *CustomClaims) (string, error) {
+
+	// Generate token (struct), with the ID of the key that signs it
+	signingKey := auth.keySet.signingKey
+	token := jwt.NewWithClaims(auth.keySet.method, claims)
+	token.Header["kid"] = signingKey.ID
 
 	// Generate token (string)
-	return token.SignedString([]byte(auth.secret))
+	return token.SignedString(signingKey.PrivateKey)
 }
 
-// ValidateToken validates a token for a specific role and sets ID and Email in context
-func (auth *Auth) ValidateToken(role Role, shouldMatchUserID bool) gin.HandlerFunc {
+// ValidateToken validates a token for a specific role, checks it hasn't been revoked and sets ID and Email in context
+func (auth *Auth) ValidateToken(role RoleName, shouldMatchUserID bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 
 		// Get token string and then convert it to a *jwt.Token
@@ -83,9 +165,16 @@ func (auth *Auth) ValidateToken(role Role, shouldMatchUserID bool) gin.HandlerFu
 		// Get custom claims from token
 		customClaims, ok := token.Claims.(*CustomClaims)
 
-		// Check if claims and token and role are valid
-		if !ok || !token.Valid || customClaims.Valid() != nil || (role != AnyRole && customClaims.Role != role) {
-			c.Error(Wrap("!token.Valid || customClaims.Role != role", ErrUnauthorized))
+		// Check if claims and token and role are valid. Challenge tokens aren't access tokens
+		if !ok || !token.Valid || customClaims.Valid() != nil || customClaims.Purpose != "" || (role != AnyRole && !customClaims.HasRole(role)) {
+			c.Error(Wrap("!token.Valid || !customClaims.HasRole(role)", ErrUnauthorized))
+			c.Abort()
+			return
+		}
+
+		// Admin endpoints may require the admin to have logged in with 2FA
+		if role == AdminRole && auth.mfaRequiredForAdmins && !customClaims.MFA {
+			c.Error(Wrap("role == AdminRole && !customClaims.MFA", ErrMFARequired))
 			c.Abort()
 			return
 		}
@@ -94,23 +183,104 @@ func (auth *Auth) ValidateToken(role Role, shouldMatchUserID bool) gin.HandlerFu
 		if shouldMatchUserID {
 			pathUserIDKey := "user_id"
 			urlUserID, err := getIntFromURLPath(c.Params, pathUserIDKey)
-			if err != nil || customClaims.ID != fmt.Sprint(urlUserID) {
+			if err != nil || customClaims.Subject != fmt.Sprint(urlUserID) {
 				c.Error(Wrap("!shouldMatchUserID", ErrUnauthorized))
 				c.Abort()
 				return
 			}
 		}
 
-		// If OK, set UserID, Username and Email inside of context
-		userID, _ := strconv.Atoi(customClaims.ID)
-		addUserInfoToContext(c, userID, customClaims.Username, customClaims.Email)
+		// Check the token hasn't been revoked
+		userID, _ := strconv.Atoi(customClaims.Subject)
+		if err := auth.checkNotRevoked(userID, customClaims); err != nil {
+			c.Error(Wrap("auth.checkNotRevoked", err))
+			c.Abort()
+			return
+		}
+
+		// If OK, set UserID, Username, Email and Token info inside of context
+		addUserInfoToContext(c, userID, customClaims)
+	}
+}
+
+// RevokeToken makes a single token unusable, e.g. on logout
+func (auth *Auth) RevokeToken(tokenID string, expiresAt time.Time) error {
+	return auth.revocationStore.RevokeToken(tokenID, expiresAt)
+}
+
+// RevokeAllUserTokens makes all of the tokens issued to a user until now unusable
+func (auth *Auth) RevokeAllUserTokens(userID int) error {
+	return auth.revocationStore.RevokeAllUserTokens(userID)
+}
+
+// JWKS returns the public keys other services can use to verify our tokens
+func (auth *Auth) JWKS() JWKS {
+	return auth.keySet.JWKS()
+}
+
+// checkNotRevoked fails with ErrUnauthorized when the store does, e.g. if the user was hard deleted.
+// A token issued in the same second as the revocation is revoked too, JWTs only have second precision.
+func (auth *Auth) checkNotRevoked(userID int, claims *CustomClaims) error {
+	if auth.revocationStore == nil {
+		return nil
+	}
+
+	revoked, err := auth.revocationStore.IsTokenRevoked(claims.ID)
+	if err != nil {
+		return Wrap("revocationStore.IsTokenRevoked: "+err.Error(), ErrUnauthorized)
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+
+	revokedAt, err := auth.revocationStore.GetUserTokensRevokedAt(userID)
+	if err != nil {
+		return Wrap("revocationStore.GetUserTokensRevokedAt: "+err.Error(), ErrUnauthorized)
+	}
+	if revokedAt != nil && (claims.IssuedAt == nil || !claims.IssuedAt.Time.After(*revokedAt)) {
+		return ErrTokenRevoked
 	}
+
+	return nil
 }
 
-func addUserInfoToContext(c *gin.Context, id int, username, email string) {
+// RequirePermission goes after ValidateToken. If 2FA is required for admins, admins also need a token obtained with it.
+func (auth *Auth) RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := c.Get("Claims")
+		customClaims, isClaims := claims.(*CustomClaims)
+		if !ok || !isClaims {
+			c.Error(Wrap("RequirePermission: no claims on context", ErrUnauthorized))
+			c.Abort()
+			return
+		}
+
+		if customClaims.HasRole(AdminRole) && auth.mfaRequiredForAdmins && !customClaims.MFA {
+			c.Error(Wrap("RequirePermission: customClaims.HasRole(AdminRole) && !customClaims.MFA", ErrMFARequired))
+			c.Abort()
+			return
+		}
+
+		for _, claimsPermission := range customClaims.Permissions {
+			if claimsPermission == permission {
+				return
+			}
+		}
+
+		c.Error(Wrap("RequirePermission: missing "+permission, ErrForbidden))
+		c.Abort()
+	}
+}
+
+func addUserInfoToContext(c *gin.Context, id int, claims *CustomClaims) {
 	c.Set("UserID", id)
-	c.Set("Username", username)
-	c.Set("Email", email)
+	c.Set("Username", claims.Username)
+	c.Set("Email", claims.Email)
+	c.Set("Claims", claims)
+	c.Set("TokenID", claims.ID)
+	if claims.ExpiresAt != nil {
+		c.Set("TokenExpiresAt", claims.ExpiresAt.Time)
+	}
 }
 
 func (auth *Auth) getTokenStructFromContext(c *gin.Context) (*jwt.Token, error) {
@@ -136,11 +306,8 @@ func (auth *Auth) decodeTokenString(tokenString string) (*jwt.Token, error) {
 		return &jwt.Token{}, ErrUnauthorized
 	}
 
-	// Make key function
-	keyFunc := func(token *jwt.Token) (interface{}, error) { return []byte(auth.secret), nil }
-
-	// Parse
-	return jwt.ParseWithClaims(tokenString, &CustomClaims{}, keyFunc)
+	// Parse, the verification key is selected by the token's kid
+	return jwt.ParseWithClaims(tokenString, &CustomClaims{}, auth.keySet.keyFunc)
 }
 
 func getIntFromURLPath(params gin.Params, key string) (int, error) {
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
@@ -21,3 +22,18 @@ func Hash(data string, salt string) string {
 	hasher.Write([]byte(data + salt))
 	return base64.URLEncoding.EncodeToString(hasher.Sum(nil))
 }
+
+// GenerateOpaqueToken returns a random URL-safe string, used for refresh tokens and the like
+func GenerateOpaqueToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// HashOpaqueToken is what we store in the DB instead of the opaque token itself
+func HashOpaqueToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
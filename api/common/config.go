@@ -1,6 +1,7 @@
 package common
 
 import (
+	"database/sql"
 	"fmt"
 	"log"
 	"os"
@@ -13,7 +14,20 @@ import (
 type Config struct {
 	General
 	Database   Database
+	Migrations Migrations
 	Monitoring Monitoring
+	Sessions   Sessions
+	Passwords  Passwords
+	Mail       Mail
+	Emails     Emails
+	Lockout    Lockout
+	MFA        MFA
+	Deletion   Deletion
+	Exports    Exports
+	RateLimits RateLimits
+	Scheduler  Scheduler
+	Admin      Admin
+	Seed       Seed
 }
 
 func NewConfig() *Config {
@@ -39,6 +53,19 @@ type Database struct {
 	Hostname string `envconfig:"GO_REST_EXAMPLE_DATABASE_HOSTNAME"`
 	Port     string `envconfig:"GO_REST_EXAMPLE_DATABASE_PORT"`
 	Schema   string `envconfig:"GO_REST_EXAMPLE_DATABASE_SCHEMA"`
+
+	// Default isolation of the transactions: read_uncommitted, read_committed, repeatable_read or serializable.
+	// Empty uses the database's own. Deadlocks and serialization failures are retried up to TxMaxRetries times.
+	TxIsolation  string `envconfig:"GO_REST_EXAMPLE_DATABASE_TX_ISOLATION"`
+	TxMaxRetries int    `envconfig:"GO_REST_EXAMPLE_DATABASE_TX_MAX_RETRIES" default:"3"`
+}
+
+type Migrations struct {
+	// Apply pending migrations on startup. If false, the server refuses to start until `migrate up` is run
+	AutoApply          bool   `envconfig:"GO_REST_EXAMPLE_MIGRATIONS_AUTO_APPLY"`
+	Path               string `envconfig:"GO_REST_EXAMPLE_MIGRATIONS_PATH" default:"api/common/migrations"`
+	LockTimeoutSeconds int    `envconfig:"GO_REST_EXAMPLE_MIGRATIONS_LOCK_TIMEOUT_SECONDS" default:"60"`
+	LockStaleMinutes   int    `envconfig:"GO_REST_EXAMPLE_MIGRATIONS_LOCK_STALE_MINUTES" default:"15"`
 }
 
 type Monitoring struct {
@@ -50,6 +77,90 @@ type Monitoring struct {
 	PrometheusAppName string `envconfig:"GO_REST_EXAMPLE_MONITORING_PROMETHEUS_APP_NAME"`
 }
 
+type Sessions struct {
+	AccessTokenMinutes int `envconfig:"GO_REST_EXAMPLE_SESSIONS_ACCESS_TOKEN_MINUTES" default:"15"`
+	RefreshTokenDays   int `envconfig:"GO_REST_EXAMPLE_SESSIONS_REFRESH_TOKEN_DAYS" default:"30"`
+
+	// HS256 uses the JWT secret. RS256 and EdDSA use PEM keys, listed as "kid1=path1,kid2=path2".
+	// The first key signs, the others only verify.
+	SigningMethod string `envconfig:"GO_REST_EXAMPLE_SESSIONS_SIGNING_METHOD" default:"HS256"`
+	SigningKeys   string `envconfig:"GO_REST_EXAMPLE_SESSIONS_SIGNING_KEYS"`
+}
+
+type Passwords struct {
+	HashAlgorithm     string `envconfig:"GO_REST_EXAMPLE_PASSWORDS_HASH_ALGORITHM" default:"argon2id"`
+	Argon2MemoryKB    uint32 `envconfig:"GO_REST_EXAMPLE_PASSWORDS_ARGON2_MEMORY_KB" default:"65536"`
+	Argon2Iterations  uint32 `envconfig:"GO_REST_EXAMPLE_PASSWORDS_ARGON2_ITERATIONS" default:"3"`
+	Argon2Parallelism uint8  `envconfig:"GO_REST_EXAMPLE_PASSWORDS_ARGON2_PARALLELISM" default:"2"`
+	BcryptCost        int    `envconfig:"GO_REST_EXAMPLE_PASSWORDS_BCRYPT_COST" default:"12"`
+
+	ResetTokenMinutes int `envconfig:"GO_REST_EXAMPLE_PASSWORDS_RESET_TOKEN_MINUTES" default:"30"`
+}
+
+type Mail struct {
+	Type     string `envconfig:"GO_REST_EXAMPLE_MAIL_TYPE" default:"log"`
+	From     string `envconfig:"GO_REST_EXAMPLE_MAIL_FROM"`
+	FilePath string `envconfig:"GO_REST_EXAMPLE_MAIL_FILE_PATH" default:"emails.txt"`
+
+	SMTPHost     string `envconfig:"GO_REST_EXAMPLE_MAIL_SMTP_HOST"`
+	SMTPPort     string `envconfig:"GO_REST_EXAMPLE_MAIL_SMTP_PORT"`
+	SMTPUsername string `envconfig:"GO_REST_EXAMPLE_MAIL_SMTP_USERNAME"`
+	SMTPPassword string `envconfig:"GO_REST_EXAMPLE_MAIL_SMTP_PASSWORD"`
+}
+
+type Emails struct {
+	VerificationRequired   bool `envconfig:"GO_REST_EXAMPLE_EMAILS_VERIFICATION_REQUIRED"`
+	VerificationTokenHours int  `envconfig:"GO_REST_EXAMPLE_EMAILS_VERIFICATION_TOKEN_HOURS" default:"48"`
+}
+
+type Lockout struct {
+	FreeAttempts       int `envconfig:"GO_REST_EXAMPLE_LOCKOUT_FREE_ATTEMPTS" default:"3"`
+	BackoffBaseSeconds int `envconfig:"GO_REST_EXAMPLE_LOCKOUT_BACKOFF_BASE_SECONDS" default:"1"`
+	BackoffMaxSeconds  int `envconfig:"GO_REST_EXAMPLE_LOCKOUT_BACKOFF_MAX_SECONDS" default:"60"`
+	MaxFailedAttempts  int `envconfig:"GO_REST_EXAMPLE_LOCKOUT_MAX_FAILED_ATTEMPTS" default:"10"`
+	LockoutMinutes     int `envconfig:"GO_REST_EXAMPLE_LOCKOUT_MINUTES" default:"15"`
+}
+
+type MFA struct {
+	RequiredForAdmins bool   `envconfig:"GO_REST_EXAMPLE_MFA_REQUIRED_FOR_ADMINS"`
+	Issuer            string `envconfig:"GO_REST_EXAMPLE_MFA_ISSUER" default:"go-rest-example"`
+}
+
+type Deletion struct {
+	GracePeriodDays      int `envconfig:"GO_REST_EXAMPLE_DELETION_GRACE_PERIOD_DAYS" default:"30"`
+	PurgeIntervalMinutes int `envconfig:"GO_REST_EXAMPLE_DELETION_PURGE_INTERVAL_MINUTES" default:"60"`
+}
+
+type Exports struct {
+	Path            string `envconfig:"GO_REST_EXAMPLE_EXPORTS_PATH" default:"exports"`
+	ExpirationHours int    `envconfig:"GO_REST_EXAMPLE_EXPORTS_EXPIRATION_HOURS" default:"24"`
+}
+
+// RateLimits are per IP, for the public endpoints that don't need a token
+type RateLimits struct {
+	PublicRequestsPerSecond int `envconfig:"GO_REST_EXAMPLE_RATE_LIMITS_PUBLIC_REQUESTS_PER_SECOND" default:"5"`
+	PublicBurst             int `envconfig:"GO_REST_EXAMPLE_RATE_LIMITS_PUBLIC_BURST" default:"20"`
+}
+
+type Scheduler struct {
+	IntervalSeconds int `envconfig:"GO_REST_EXAMPLE_SCHEDULER_INTERVAL_SECONDS" default:"30"`
+	BatchSize       int `envconfig:"GO_REST_EXAMPLE_SCHEDULER_BATCH_SIZE" default:"100"`
+}
+
+// Admin is created on startup if it doesn't exist, so a new database has someone that can use /v1/admin.
+// Without a username there's no admin. The password can be read from a file instead, like a Docker secret.
+type Admin struct {
+	Username     string `envconfig:"GO_REST_EXAMPLE_ADMIN_USERNAME"`
+	Email        string `envconfig:"GO_REST_EXAMPLE_ADMIN_EMAIL"`
+	Password     string `envconfig:"GO_REST_EXAMPLE_ADMIN_PASSWORD"`
+	PasswordFile string `envconfig:"GO_REST_EXAMPLE_ADMIN_PASSWORD_FILE"`
+}
+
+// Seed loads demo users, with their details and posts, on startup. The file is JSON or YAML.
+type Seed struct {
+	File string `envconfig:"GO_REST_EXAMPLE_SEED_FILE"`
+}
+
 func (config *Config) setup() {
 
 	// We may be on the cmd folder or not. Hacky, I know.
@@ -69,8 +180,30 @@ func (config *Config) setup() {
 	if err != nil {
 		log.Fatalf("error parsing environment variables: %v", err)
 	}
+
+	// Refuse to start with values that would break something later on
+	if err := config.validate(); err != nil {
+		log.Fatalf("error in config: %v", err)
+	}
 }
 
+func (config *Config) validate() error {
+	if err := config.Passwords.Validate(); err != nil {
+		return err
+	}
+	if err := config.Deletion.Validate(); err != nil {
+		return err
+	}
+	return config.Scheduler.Validate()
+}
+
+// Supported values for Database.Type. An empty type means MySQL
+const (
+	DatabaseTypeMySQL    = "mysql"
+	DatabaseTypePostgres = "postgres"
+	DatabaseTypeSQLite   = "sqlite"
+)
+
 func (dbConfig *Database) GetConnectionString() string {
 	var (
 		username = dbConfig.Username
@@ -78,12 +211,95 @@ func (dbConfig *Database) GetConnectionString() string {
 		hostname = dbConfig.Hostname
 		port     = dbConfig.Port
 		schema   = dbConfig.Schema
-		params   = "?charset=utf8&parseTime=True&loc=Local"
-	)
-	return fmt.Sprintf(
-		"%s:%s@tcp(%s:%s)/%s%s",
-		username, password, hostname, port, schema, params,
 	)
+
+	switch dbConfig.Type {
+	case DatabaseTypePostgres:
+		return fmt.Sprintf(
+			"host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=UTC",
+			hostname, username, password, schema, port,
+		)
+	case DatabaseTypeSQLite:
+		// The schema is the path to the database file, or ":memory:".
+		// Wait for locks instead of failing right away and enforce foreign keys.
+		if schema == ":memory:" {
+			schema = "file::memory:?cache=shared"
+		}
+		separator := "?"
+		if strings.Contains(schema, "?") {
+			separator = "&"
+		}
+		return schema + separator + "_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)"
+	default:
+		params := "?charset=utf8&parseTime=True&loc=Local"
+		return fmt.Sprintf(
+			"%s:%s@tcp(%s:%s)/%s%s",
+			username, password, hostname, port, schema, params,
+		)
+	}
+}
+
+// Supported values for Database.TxIsolation
+var txIsolationLevels = map[string]sql.IsolationLevel{
+	"":                 sql.LevelDefault,
+	"read_uncommitted": sql.LevelReadUncommitted,
+	"read_committed":   sql.LevelReadCommitted,
+	"repeatable_read":  sql.LevelRepeatableRead,
+	"serializable":     sql.LevelSerializable,
+}
+
+func (dbConfig *Database) GetTxOptions() (TxOptions, error) {
+	isolation, ok := txIsolationLevels[strings.ToLower(dbConfig.TxIsolation)]
+	if !ok {
+		return TxOptions{}, fmt.Errorf("unknown transaction isolation %q, must be read_uncommitted, read_committed, repeatable_read or serializable", dbConfig.TxIsolation)
+	}
+	if dbConfig.TxMaxRetries < 0 {
+		return TxOptions{}, fmt.Errorf("transaction max retries can't be negative, got %d", dbConfig.TxMaxRetries)
+	}
+	return TxOptions{Isolation: isolation, MaxRetries: dbConfig.TxMaxRetries}, nil
+}
+
+// Validate rejects unknown algorithms, the hasher would use argon2id instead and rehash on every login
+func (passwordsConfig *Passwords) Validate() error {
+	if passwordsConfig.HashAlgorithm != Argon2idAlgorithm && passwordsConfig.HashAlgorithm != BcryptAlgorithm {
+		return fmt.Errorf("unknown password hash algorithm %q, must be %s or %s", passwordsConfig.HashAlgorithm, Argon2idAlgorithm, BcryptAlgorithm)
+	}
+	return nil
+}
+
+// Validate rejects what would make the purger panic or purge users before they're deleted
+func (deletionConfig *Deletion) Validate() error {
+	if deletionConfig.PurgeIntervalMinutes <= 0 {
+		return fmt.Errorf("deletion purge interval must be positive, got %d minutes", deletionConfig.PurgeIntervalMinutes)
+	}
+	if deletionConfig.GracePeriodDays < 0 {
+		return fmt.Errorf("deletion grace period can't be negative, got %d days", deletionConfig.GracePeriodDays)
+	}
+	return nil
+}
+
+// Validate rejects what would make the scheduler panic or never stop publishing
+func (schedulerConfig *Scheduler) Validate() error {
+	if schedulerConfig.IntervalSeconds <= 0 {
+		return fmt.Errorf("scheduler interval must be positive, got %d seconds", schedulerConfig.IntervalSeconds)
+	}
+	if schedulerConfig.BatchSize <= 0 {
+		return fmt.Errorf("scheduler batch size must be positive, got %d", schedulerConfig.BatchSize)
+	}
+	return nil
+}
+
+// GetPassword prefers the file, if there's one. Its trailing newline is ignored.
+func (adminConfig *Admin) GetPassword() (string, error) {
+	if adminConfig.PasswordFile == "" {
+		return adminConfig.Password, nil
+	}
+
+	content, err := os.ReadFile(adminConfig.PasswordFile)
+	if err != nil {
+		return "", fmt.Errorf("error reading admin password file: %w", err)
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
 }
 
 func currentFolderIsCMD() bool {
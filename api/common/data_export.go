@@ -0,0 +1,194 @@
+package common
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The export has everything we store about the user, except for secrets: password hashes,
+// token hashes, recovery code hashes and the TOTP secret are never written to the zip.
+
+type exportedUser struct {
+	ID              int        `json:"id"`
+	Username        string     `json:"username"`
+	Email           string     `json:"email"`
+	Verified        bool       `json:"verified"`
+	Roles           []RoleName `json:"roles"`
+	TwoFactor       bool       `json:"two_factor"`
+	TokensRevokedAt *time.Time `json:"tokens_revoked_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+type exportedSession struct {
+	ID        int        `json:"id"`
+	FamilyID  string     `json:"family_id"`
+	MFA       bool       `json:"mfa"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+type exportedReaction struct {
+	PostID    int       `json:"post_id"`
+	Kind      string    `json:"kind"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type exportedOneTimeToken struct {
+	Type      string     `json:"type"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+type exportedSecurity struct {
+	RecoveryCodesTotal  int                    `json:"recovery_codes_total"`
+	RecoveryCodesUsed   int                    `json:"recovery_codes_used"`
+	FailedLogins        int                    `json:"failed_logins"`
+	LastFailedLoginAt   *time.Time             `json:"last_failed_login_at,omitempty"`
+	LockedUntil         *time.Time             `json:"locked_until,omitempty"`
+	OneTimeTokens       []exportedOneTimeToken `json:"one_time_tokens"`
+	PreviousDataExports []ResponseDataExport   `json:"previous_data_exports"`
+}
+
+// UserExportData is everything that goes into the zip, before leaving the secrets out
+type UserExportData struct {
+	User                    User
+	RefreshTokens           []RefreshToken
+	Comments                PostComments
+	Reactions               []PostReaction
+	RecoveryCodes           []RecoveryCode
+	LoginThrottles          []LoginThrottle
+	PasswordResetTokens     []PasswordResetToken
+	EmailVerificationTokens []EmailVerificationToken
+	PreviousDataExports     []DataExport
+}
+
+// WriteUserDataExport writes the zip of the export and returns its path
+func WriteUserDataExport(exportsPath string, export DataExport, data UserExportData) (string, error) {
+	files := data.toFiles()
+
+	if err := os.MkdirAll(exportsPath, 0700); err != nil {
+		return "", err
+	}
+
+	filePath := filepath.Join(exportsPath, fmt.Sprintf("user-%d-export-%d.zip", export.UserID, export.ID))
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	zipWriter := zip.NewWriter(file)
+	for _, name := range []string{"user.json", "details.json", "posts.json", "comments.json", "reactions.json", "sessions.json", "security.json"} {
+		writer, err := zipWriter.Create(name)
+		if err != nil {
+			return "", err
+		}
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(files[name]); err != nil {
+			return "", err
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return "", err
+	}
+
+	return filePath, nil
+}
+
+// toFiles returns the content of each file of the zip
+func (data UserExportData) toFiles() map[string]interface{} {
+	user := data.User
+
+	sessions := []exportedSession{}
+	for _, token := range data.RefreshTokens {
+		sessions = append(sessions, exportedSession{
+			ID:        token.ID,
+			FamilyID:  token.FamilyID,
+			MFA:       token.MFA,
+			CreatedAt: token.CreatedAt,
+			ExpiresAt: token.ExpiresAt,
+			UsedAt:    token.UsedAt,
+			RevokedAt: token.RevokedAt,
+		})
+	}
+
+	reactions := []exportedReaction{}
+	for _, reaction := range data.Reactions {
+		reactions = append(reactions, exportedReaction{reaction.PostID, reaction.Kind, reaction.CreatedAt})
+	}
+
+	return map[string]interface{}{
+		"user.json": exportedUser{
+			ID:              user.ID,
+			Username:        user.Username,
+			Email:           user.Email,
+			Verified:        user.Verified,
+			Roles:           user.GetRoleNames(),
+			TwoFactor:       user.TOTPEnabled,
+			TokensRevokedAt: user.TokensRevokedAt,
+			CreatedAt:       user.CreatedAt,
+			UpdatedAt:       user.UpdatedAt,
+		},
+		"details.json":   user.Details.ToResponseModel(),
+		"posts.json":     user.Posts.ToResponseModel(),
+		"comments.json":  data.Comments.ToResponseModel(),
+		"reactions.json": reactions,
+		"sessions.json":  sessions,
+		"security.json":  data.securityData(),
+	}
+}
+
+func (data UserExportData) securityData() exportedSecurity {
+	security := exportedSecurity{OneTimeTokens: []exportedOneTimeToken{}, PreviousDataExports: []ResponseDataExport{}}
+
+	// Recovery codes
+	security.RecoveryCodesTotal = len(data.RecoveryCodes)
+	for _, code := range data.RecoveryCodes {
+		if code.UsedAt != nil {
+			security.RecoveryCodesUsed++
+		}
+	}
+
+	// Failed logins
+	for _, throttle := range data.LoginThrottles {
+		lastFailedAt := throttle.LastFailedAt
+		security.FailedLogins = throttle.FailedAttempts
+		security.LastFailedLoginAt = &lastFailedAt
+		security.LockedUntil = throttle.LockedUntil
+	}
+
+	// Password reset & email verification tokens
+	for _, token := range data.PasswordResetTokens {
+		security.OneTimeTokens = append(security.OneTimeTokens, exportedOneTimeToken{"password_reset", token.CreatedAt, token.ExpiresAt, token.UsedAt})
+	}
+	for _, token := range data.EmailVerificationTokens {
+		security.OneTimeTokens = append(security.OneTimeTokens, exportedOneTimeToken{"email_verification", token.CreatedAt, token.ExpiresAt, token.UsedAt})
+	}
+
+	// Data exports, except for this one
+	for _, dataExport := range data.PreviousDataExports {
+		security.PreviousDataExports = append(security.PreviousDataExports, dataExport.ToResponseModel())
+	}
+
+	return security
+}
+
+// RemoveDataExportFiles doesn't fail on the files that are already gone
+func RemoveDataExportFiles(filePaths []string) error {
+	for _, filePath := range filePaths {
+		if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+			return Wrap(err.Error(), ErrUpdatingDataExport)
+		}
+	}
+	return nil
+}
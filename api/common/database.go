@@ -1,12 +1,18 @@
 package common
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
+	sqlitedriver "github.com/glebarez/go-sqlite"
+	"github.com/glebarez/sqlite"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/sirupsen/logrus"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
@@ -15,6 +21,18 @@ type database struct {
 }
 
 func NewDatabase(config *Config, logger *logrus.Logger) *database {
+	database := NewDatabaseConnection(config, logger)
+
+	// Apply pending migrations, or refuse to start if the schema is behind
+	if err := database.migrate(config, logger); err != nil {
+		log.Fatalf("error migrating database: %v", err)
+	}
+
+	return database
+}
+
+// NewDatabaseConnection connects without touching the schema, the migrate command uses it directly
+func NewDatabaseConnection(config *Config, logger *logrus.Logger) *database {
 	var database database
 
 	// Create connection. It's deferred closed in main.go.
@@ -24,10 +42,6 @@ func NewDatabase(config *Config, logger *logrus.Logger) *database {
 	}
 
 	// Set connection pool limits
-	// Log queries if debug = true
-	// Destroy or clean tables
-	// AutoMigrate fields
-	// Create admin
 	database.configure(config)
 
 	return &database
@@ -42,9 +56,17 @@ func (database *database) connectToDB(config *Config, logger *logrus.Logger) err
 		err        error
 	)
 
+	dialector, err := dbConfig.getDialector()
+	if err != nil {
+		return err
+	}
+
+	// Driver errors get translated to gorm ones, like gorm.ErrDuplicatedKey
+	gormConfig := &gorm.Config{TranslateError: true}
+
 	// Retry connection if it fails due to Docker's orchestration
 	for retries < maxRetries {
-		if database.DB, err = gorm.Open(mysql.Open(dbConfig.GetConnectionString())); err == nil {
+		if database.DB, err = gorm.Open(dialector, gormConfig); err == nil {
 			break
 		}
 
@@ -60,14 +82,68 @@ func (database *database) connectToDB(config *Config, logger *logrus.Logger) err
 	return nil
 }
 
+func (dbConfig *Database) getDialector() (gorm.Dialector, error) {
+	switch dbConfig.Type {
+	case DatabaseTypeMySQL, "":
+		return mysql.Open(dbConfig.GetConnectionString()), nil
+	case DatabaseTypePostgres:
+		return postgres.Open(dbConfig.GetConnectionString()), nil
+	case DatabaseTypeSQLite:
+		return sqlite.Open(dbConfig.GetConnectionString()), nil
+	}
+	return nil, fmt.Errorf("unknown database type %q, must be %s, %s or %s", dbConfig.Type, DatabaseTypeMySQL, DatabaseTypePostgres, DatabaseTypeSQLite)
+}
+
+// isTxConflict is true for deadlocks and serialization failures, the transaction can be run again after those
+func isTxConflict(err error) bool {
+	var mysqlErr *mysqldriver.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1213 || mysqlErr.Number == 1205 // Deadlock, lock wait timeout
+	}
+
+	var postgresErr *pgconn.PgError
+	if errors.As(err, &postgresErr) {
+		return postgresErr.Code == "40001" || postgresErr.Code == "40P01" // Serialization failure, deadlock
+	}
+
+	var sqliteErr *sqlitedriver.Error
+	if errors.As(err, &sqliteErr) {
+		code := sqliteErr.Code() & 0xff // Primary result code, without the extended part
+		return code == 5 || code == 6   // SQLITE_BUSY, SQLITE_LOCKED
+	}
+
+	return false
+}
+
 func (database *database) configure(config *Config) {
-	mySQLDB, _ := database.DB.DB()
+	sqlDB, _ := database.DB.DB()
 
 	// Set connection pool limits
-	mySQLDB.SetMaxIdleConns(100)
-	mySQLDB.SetMaxOpenConns(100)
-	mySQLDB.SetConnMaxLifetime(time.Hour)
+	sqlDB.SetMaxIdleConns(100)
+	sqlDB.SetMaxOpenConns(100)
+	sqlDB.SetConnMaxLifetime(time.Hour)
+
+	// SQLite has a single writer, and each connection to ":memory:" would otherwise be a different database
+	if config.Database.Type == DatabaseTypeSQLite {
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+	}
+}
+
+func (database *database) migrate(config *Config, logger *logrus.Logger) error {
+	migrator, err := NewSchemaMigrator(config.Migrations, database.DB, logger)
+	if err != nil {
+		return err
+	}
 
-	// AutoMigrate fields
-	database.DB.AutoMigrate(AllModels...)
+	if !config.Migrations.AutoApply {
+		return migrator.CheckUpToDate()
+	}
+
+	applied, err := migrator.Up()
+	if err != nil {
+		return err
+	}
+	logger.Info(fmt.Sprintf("Migrations: %d applied on startup", applied))
+	return nil
 }
@@ -33,6 +33,7 @@ var (
 	ErrPasswordsDontMatch = NewError(fmt.Errorf("error, passwords don't match"), 400)
 	ErrBindingRequest     = NewError(fmt.Errorf("error binding request"), 400)
 	ErrInvalidEmailFormat = NewError(fmt.Errorf("error, invalid email format"), 400)
+	ErrInvalidCursor      = NewError(fmt.Errorf("error, invalid cursor"), 400)
 	ErrInvalidValue       = func(field string) error {
 		return NewError(fmt.Errorf("error, invalid value for field %s", field), 400)
 	}
@@ -43,9 +44,20 @@ var (
 		return NewError(fmt.Errorf("error, password must contain between %d and %d characters", min, max), 400)
 	}
 
+	// - Mail errors
+	ErrSendingEmail = NewError(fmt.Errorf("error sending email"), 500)
+
 	// - Service & Repository errors
 	ErrInDBTransaction = NewError(fmt.Errorf("error in database transaction"), 500)
 
+	// --- Migrations
+	ErrLoadingMigrations         = NewError(fmt.Errorf("error loading migrations"), 500)
+	ErrGettingMigrations         = NewError(fmt.Errorf("error getting applied migrations"), 500)
+	ErrMigrating                 = NewError(fmt.Errorf("error migrating database"), 500)
+	ErrMigrationLocked           = NewError(fmt.Errorf("error, another instance is migrating the database"), 500)
+	ErrMigrationChecksumMismatch = NewError(fmt.Errorf("error, applied migration was modified"), 500)
+	ErrSchemaBehind              = NewError(fmt.Errorf("error, database schema is behind"), 500)
+
 	// --- Users
 	ErrCreatingUser                = NewError(fmt.Errorf("error creating user"), 500)
 	ErrGettingUser                 = NewError(fmt.Errorf("error getting user"), 500)
@@ -55,9 +67,102 @@ var (
 	ErrSearchingUsers              = NewError(fmt.Errorf("error searching users"), 500)
 	ErrUserNotFound                = NewError(fmt.Errorf("error, user not found"), 404)
 	ErrUserAlreadyDeleted          = NewError(fmt.Errorf("error, user already deleted"), 404)
+	ErrUserNotDeleted              = NewError(fmt.Errorf("error, user is not deleted"), 409)
 	ErrUsernameOrEmailAlreadyInUse = NewError(fmt.Errorf("error, username or email already in use"), 409)
 	ErrWrongPassword               = NewError(fmt.Errorf("error, wrong password"), 401)
+	ErrHashingPassword             = NewError(fmt.Errorf("error hashing password"), 500)
+	ErrEmailNotVerified            = NewError(fmt.Errorf("error, email not verified"), 403)
+	ErrAccountLocked               = NewError(fmt.Errorf("error, account locked due to too many failed logins"), 423)
+	ErrTooManyLoginAttempts        = NewError(fmt.Errorf("error, too many failed logins, try again later"), 429)
+	ErrMFARequired                 = NewError(fmt.Errorf("error, two factor authentication required"), 403)
+	ErrInvalidMFAChallenge         = NewError(fmt.Errorf("error, invalid or expired mfa challenge"), 401)
+	ErrInvalidMFACode              = NewError(fmt.Errorf("error, invalid two factor code"), 401)
+	ErrTOTPAlreadyEnabled          = NewError(fmt.Errorf("error, two factor authentication already enabled"), 409)
+	ErrTOTPNotEnrolled             = NewError(fmt.Errorf("error, two factor authentication not enrolled"), 400)
+	ErrTOTPNotEnabled              = NewError(fmt.Errorf("error, two factor authentication not enabled"), 400)
+
+	// --- Refresh Tokens
+	ErrCreatingRefreshToken = NewError(fmt.Errorf("error creating refresh token"), 500)
+	ErrGettingRefreshToken  = NewError(fmt.Errorf("error getting refresh token"), 500)
+	ErrUpdatingRefreshToken = NewError(fmt.Errorf("error updating refresh token"), 500)
+	ErrInvalidRefreshToken  = NewError(fmt.Errorf("error, invalid refresh token"), 401)
+	ErrRefreshTokenExpired  = NewError(fmt.Errorf("error, refresh token expired"), 401)
+	ErrRefreshTokenReused   = NewError(fmt.Errorf("error, refresh token already used"), 401)
+
+	// --- Revoked Tokens
+	ErrRevokingToken       = NewError(fmt.Errorf("error revoking token"), 500)
+	ErrGettingRevokedToken = NewError(fmt.Errorf("error getting revoked token"), 500)
+	ErrTokenRevoked        = NewError(fmt.Errorf("error, token revoked"), 401)
+
+	// --- Password Reset Tokens
+	ErrCreatingPasswordResetToken = NewError(fmt.Errorf("error creating password reset token"), 500)
+	ErrGettingPasswordResetToken  = NewError(fmt.Errorf("error getting password reset token"), 500)
+	ErrUpdatingPasswordResetToken = NewError(fmt.Errorf("error updating password reset token"), 500)
+	ErrInvalidPasswordResetToken  = NewError(fmt.Errorf("error, invalid or expired password reset token"), 400)
+
+	// --- Email Verification Tokens
+	ErrCreatingEmailVerificationToken = NewError(fmt.Errorf("error creating email verification token"), 500)
+	ErrGettingEmailVerificationToken  = NewError(fmt.Errorf("error getting email verification token"), 500)
+	ErrUpdatingEmailVerificationToken = NewError(fmt.Errorf("error updating email verification token"), 500)
+	ErrInvalidEmailVerificationToken  = NewError(fmt.Errorf("error, invalid or expired email verification token"), 400)
+
+	// --- Login Throttles
+	ErrGettingLoginThrottle  = NewError(fmt.Errorf("error getting login throttle"), 500)
+	ErrUpdatingLoginThrottle = NewError(fmt.Errorf("error updating login throttle"), 500)
+
+	// --- Recovery Codes
+	ErrCreatingRecoveryCodes = NewError(fmt.Errorf("error creating recovery codes"), 500)
+	ErrGettingRecoveryCode   = NewError(fmt.Errorf("error getting recovery code"), 500)
+	ErrUpdatingRecoveryCode  = NewError(fmt.Errorf("error updating recovery code"), 500)
+
+	// --- Data Exports
+	ErrCreatingDataExport = NewError(fmt.Errorf("error creating data export"), 500)
+	ErrGettingDataExport  = NewError(fmt.Errorf("error getting data export"), 500)
+	ErrUpdatingDataExport = NewError(fmt.Errorf("error updating data export"), 500)
+	ErrDataExportNotFound = NewError(fmt.Errorf("error, data export not found"), 404)
+	ErrDataExportNotReady = NewError(fmt.Errorf("error, data export not ready"), 409)
+	ErrDataExportExpired  = NewError(fmt.Errorf("error, data export expired"), 410)
+
+	// --- Roles
+	ErrCreatingRole       = NewError(fmt.Errorf("error creating role"), 500)
+	ErrGettingRoles       = NewError(fmt.Errorf("error getting roles"), 500)
+	ErrUpdatingUserRoles  = NewError(fmt.Errorf("error updating user roles"), 500)
+	ErrRoleNotFound       = NewError(fmt.Errorf("error, role not found"), 404)
+	ErrRoleAlreadyExists  = NewError(fmt.Errorf("error, role already exists"), 409)
+	ErrPermissionNotFound = NewError(fmt.Errorf("error, permission not found"), 400)
+	ErrForbidden          = NewError(fmt.Errorf("error, forbidden"), 403)
 
 	// --- User Posts
 	ErrCreatingUserPost = NewError(fmt.Errorf("error creating user post"), 500)
+	ErrGettingUserPost  = NewError(fmt.Errorf("error getting user post"), 500)
+	ErrUpdatingUserPost = NewError(fmt.Errorf("error updating user post"), 500)
+	ErrDeletingUserPost = NewError(fmt.Errorf("error deleting user post"), 500)
+	ErrUserPostNotFound = NewError(fmt.Errorf("error, user post not found"), 404)
+	ErrPublishingPosts  = NewError(fmt.Errorf("error publishing scheduled posts"), 500)
+
+	// --- Post Comments
+	ErrCreatingPostComment  = NewError(fmt.Errorf("error creating post comment"), 500)
+	ErrGettingPostComment   = NewError(fmt.Errorf("error getting post comment"), 500)
+	ErrUpdatingPostComment  = NewError(fmt.Errorf("error updating post comment"), 500)
+	ErrDeletingPostComment  = NewError(fmt.Errorf("error deleting post comment"), 500)
+	ErrPostCommentNotFound  = NewError(fmt.Errorf("error, post comment not found"), 404)
+	ErrInvalidParentComment = NewError(fmt.Errorf("error, comments can only reply to top-level comments of the same post"), 400)
+	ErrInvalidCommentLength = func(max int) error {
+		return NewError(fmt.Errorf("error, comment must contain between 1 and %d characters", max), 400)
+	}
+
+	// --- Tags
+	ErrCreatingTags = NewError(fmt.Errorf("error creating tags"), 500)
+	ErrGettingTags  = NewError(fmt.Errorf("error getting tags"), 500)
+	ErrInvalidTag   = func(max int) error {
+		return NewError(fmt.Errorf("error, tags must have up to %d letters, numbers or dashes", max), 400)
+	}
+	ErrTooManyTags = func(max int) error {
+		return NewError(fmt.Errorf("error, posts can have up to %d tags", max), 400)
+	}
+
+	// --- Post Reactions
+	ErrCreatingPostReaction = NewError(fmt.Errorf("error creating post reaction"), 500)
+	ErrGettingPostReactions = NewError(fmt.Errorf("error getting post reactions"), 500)
+	ErrDeletingPostReaction = NewError(fmt.Errorf("error deleting post reaction"), 500)
 )
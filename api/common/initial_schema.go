@@ -0,0 +1,195 @@
+package common
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+/*-------------------------
+//     INITIAL SCHEMA
+//-----------------------*/
+
+// initialSchema is the schema of migration 1, the one we had when we used AutoMigrate. It's frozen:
+// don't ever change it, schema changes go in new migrations. The changes would be caught anyway, the checksum
+// of the migration is made from these structs.
+//
+// The types are named like the models, so GORM names the tables, join tables and foreign keys the same way.
+// Being local, they can't point to the ones declared after them, that's why UserPost has no Author.
+func initialSchema() []interface{} {
+	type Permission struct {
+		ID   int    `gorm:"primaryKey"`
+		Name string `gorm:"size:64;unique;not null"`
+	}
+
+	type Role struct {
+		ID          int          `gorm:"primaryKey"`
+		Name        string       `gorm:"size:64;unique;not null"`
+		Permissions []Permission `gorm:"many2many:role_permissions"`
+		CreatedAt   time.Time
+	}
+
+	type UserDetail struct {
+		ID        int    `gorm:"primaryKey"`
+		UserID    int    `gorm:"unique;not null"`
+		FirstName string `gorm:"not null"`
+		LastName  string `gorm:"not null"`
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+
+	type Tag struct {
+		ID        int    `gorm:"primaryKey"`
+		Name      string `gorm:"size:32;unique;not null"`
+		CreatedAt time.Time
+	}
+
+	type UserPost struct {
+		ID        int        `gorm:"primaryKey"`
+		Title     string     `gorm:"not null"`
+		Body      string     `gorm:"type:text"`
+		UserID    int        `gorm:"not null;index"`
+		Tags      []Tag      `gorm:"many2many:post_tags"`
+		Status    string     `gorm:"size:16;not null;default:published;index:idx_post_status_publish_at"`
+		PublishAt *time.Time `gorm:"index:idx_post_status_publish_at"`
+		Deleted   bool       `gorm:"not null;default:false"`
+		DeletedAt *time.Time
+		CreatedAt time.Time `gorm:"index"`
+		UpdatedAt time.Time
+	}
+
+	type User struct {
+		ID               int        `gorm:"primaryKey"`
+		Username         string     `gorm:"unique;not null"`
+		Email            string     `gorm:"unique;not null"`
+		Password         string     `gorm:"not null"`
+		Roles            []Role     `gorm:"many2many:user_roles"`
+		Verified         bool       `gorm:"not null;default:false"`
+		Details          UserDetail `gorm:"foreignKey:UserID"`
+		Posts            []UserPost `gorm:"foreignKey:UserID;references:ID"`
+		Deleted          bool
+		CreatedAt        time.Time
+		UpdatedAt        time.Time
+		DeletedAt        *time.Time
+		TokensRevokedAt  *time.Time
+		TOTPSecret       string `gorm:"column:totp_secret;size:64"`
+		TOTPEnabled      bool   `gorm:"column:totp_enabled;not null;default:false"`
+		TOTPLastUsedStep int64  `gorm:"column:totp_last_used_step;not null;default:0"`
+	}
+
+	type RefreshToken struct {
+		ID        int       `gorm:"primaryKey"`
+		UserID    int       `gorm:"not null;index"`
+		TokenHash string    `gorm:"size:64;unique;not null"`
+		FamilyID  string    `gorm:"size:64;not null;index"`
+		MFA       bool      `gorm:"not null;default:false"`
+		ExpiresAt time.Time `gorm:"not null"`
+		UsedAt    *time.Time
+		RevokedAt *time.Time
+		CreatedAt time.Time
+	}
+
+	type RevokedToken struct {
+		TokenID   string    `gorm:"primaryKey;size:64"`
+		ExpiresAt time.Time `gorm:"not null;index"`
+		CreatedAt time.Time
+	}
+
+	type PasswordResetToken struct {
+		ID        int       `gorm:"primaryKey"`
+		UserID    int       `gorm:"not null;index"`
+		TokenHash string    `gorm:"size:64;unique;not null"`
+		ExpiresAt time.Time `gorm:"not null"`
+		UsedAt    *time.Time
+		CreatedAt time.Time
+	}
+
+	type EmailVerificationToken struct {
+		ID        int       `gorm:"primaryKey"`
+		UserID    int       `gorm:"not null;index"`
+		TokenHash string    `gorm:"size:64;unique;not null"`
+		ExpiresAt time.Time `gorm:"not null"`
+		UsedAt    *time.Time
+		CreatedAt time.Time
+	}
+
+	type LoginThrottle struct {
+		ThrottleKey    string `gorm:"primaryKey;size:128"`
+		FailedAttempts int    `gorm:"not null;default:0"`
+		LastFailedAt   time.Time
+		LockedUntil    *time.Time
+	}
+
+	type RecoveryCode struct {
+		ID        int    `gorm:"primaryKey"`
+		UserID    int    `gorm:"not null;index"`
+		CodeHash  string `gorm:"size:64;not null"`
+		UsedAt    *time.Time
+		CreatedAt time.Time
+	}
+
+	type DataExport struct {
+		ID          int    `gorm:"primaryKey"`
+		UserID      int    `gorm:"not null;index"`
+		Status      string `gorm:"size:16;not null"`
+		FilePath    string
+		CompletedAt *time.Time
+		ExpiresAt   *time.Time
+		CreatedAt   time.Time
+	}
+
+	type PostComment struct {
+		ID        int           `gorm:"primaryKey"`
+		PostID    int           `gorm:"not null;index"`
+		UserID    int           `gorm:"not null;index"`
+		Author    *User         `gorm:"foreignKey:UserID"`
+		ParentID  *int          `gorm:"index"`
+		Replies   []PostComment `gorm:"foreignKey:ParentID"`
+		Body      string        `gorm:"type:text;not null"`
+		Deleted   bool          `gorm:"not null;default:false"`
+		DeletedAt *time.Time
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+
+	type PostReaction struct {
+		ID        int    `gorm:"primaryKey"`
+		PostID    int    `gorm:"not null;uniqueIndex:idx_post_reaction"`
+		UserID    int    `gorm:"not null;uniqueIndex:idx_post_reaction;index"`
+		Kind      string `gorm:"size:32;not null;uniqueIndex:idx_post_reaction"`
+		CreatedAt time.Time
+	}
+
+	return []interface{}{
+		&User{},
+		&Role{},
+		&Permission{},
+		&UserDetail{},
+		&UserPost{},
+		&RefreshToken{},
+		&RevokedToken{},
+		&PasswordResetToken{},
+		&EmailVerificationToken{},
+		&LoginThrottle{},
+		&RecoveryCode{},
+		&DataExport{},
+		&PostComment{},
+		&PostReaction{},
+		&Tag{},
+	}
+}
+
+// modelsChecksum changes whenever a field of the models, or its type or tags, changes
+func modelsChecksum(models []interface{}) string {
+	var description strings.Builder
+	for _, model := range models {
+		modelType := reflect.TypeOf(model).Elem()
+		description.WriteString(modelType.Name() + "\n")
+		for i := 0; i < modelType.NumField(); i++ {
+			field := modelType.Field(i)
+			description.WriteString(fmt.Sprintf("\t%s %s `%s`\n", field.Name, field.Type, field.Tag))
+		}
+	}
+	return checksum(description.String())
+}
@@ -0,0 +1,184 @@
+package common
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// KeySet holds the keys used to sign and verify JWTs. Only the first key signs new tokens,
+// the rest are kept so tokens signed with them are still accepted while they're being rotated out.
+type KeySet struct {
+	method     jwt.SigningMethod
+	signingKey *SigningKey
+	keys       map[string]*SigningKey
+	keyIDs     []string
+}
+
+type SigningKey struct {
+	ID         string
+	PrivateKey interface{} // nil for verification-only keys
+	PublicKey  interface{} // for HMAC keys this is the shared secret
+}
+
+// JWK is the public part of a key as published on the JWKS endpoint
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func NewKeySet(config *Config) *KeySet {
+	keySet, err := loadKeySet(config.Sessions.SigningMethod, config.Sessions.SigningKeys, config.JWTSecret)
+	if err != nil {
+		log.Fatalf("error loading signing keys: %v", err)
+	}
+	return keySet
+}
+
+// loadKeySet builds the KeySet. For HS256 the JWT secret is used, for RS256 and EdDSA the keys
+// are read from PEM files listed like "kid1=path/to/key1.pem,kid2=path/to/key2.pem".
+func loadKeySet(methodName, keyFiles, secret string) (*KeySet, error) {
+	method := jwt.GetSigningMethod(methodName)
+	if method == nil {
+		return nil, fmt.Errorf("unsupported signing method %s", methodName)
+	}
+
+	keySet := &KeySet{method: method, keys: map[string]*SigningKey{}}
+
+	if method == jwt.SigningMethodHS256 {
+		keySet.add(&SigningKey{ID: "default", PrivateKey: []byte(secret), PublicKey: []byte(secret)})
+		return keySet, nil
+	}
+
+	if method != jwt.SigningMethodRS256 && method != jwt.SigningMethodEdDSA {
+		return nil, fmt.Errorf("unsupported signing method %s", methodName)
+	}
+
+	for _, keyFile := range strings.Split(keyFiles, ",") {
+		keyID, path, found := strings.Cut(strings.TrimSpace(keyFile), "=")
+		if !found || keyID == "" || path == "" {
+			return nil, fmt.Errorf("invalid signing key entry %q, expected kid=path", keyFile)
+		}
+
+		pemBytes, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading key %s: %w", keyID, err)
+		}
+
+		key, err := parseKey(method, keyID, pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing key %s: %w", keyID, err)
+		}
+
+		if _, exists := keySet.keys[keyID]; exists {
+			return nil, fmt.Errorf("duplicated key id %s", keyID)
+		}
+		keySet.add(key)
+	}
+
+	if keySet.signingKey.PrivateKey == nil {
+		return nil, fmt.Errorf("the first key (%s) must be a private key", keySet.signingKey.ID)
+	}
+
+	return keySet, nil
+}
+
+// parseKey accepts both private keys and public keys (verification-only) in PEM format
+func parseKey(method jwt.SigningMethod, keyID string, pemBytes []byte) (*SigningKey, error) {
+	if method == jwt.SigningMethodRS256 {
+		if privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(pemBytes); err == nil {
+			return &SigningKey{ID: keyID, PrivateKey: privateKey, PublicKey: &privateKey.PublicKey}, nil
+		}
+		publicKey, err := jwt.ParseRSAPublicKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, err
+		}
+		return &SigningKey{ID: keyID, PublicKey: publicKey}, nil
+	}
+
+	if privateKey, err := jwt.ParseEdPrivateKeyFromPEM(pemBytes); err == nil {
+		edPrivateKey := privateKey.(ed25519.PrivateKey)
+		return &SigningKey{ID: keyID, PrivateKey: edPrivateKey, PublicKey: edPrivateKey.Public()}, nil
+	}
+	publicKey, err := jwt.ParseEdPublicKeyFromPEM(pemBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &SigningKey{ID: keyID, PublicKey: publicKey}, nil
+}
+
+func (ks *KeySet) add(key *SigningKey) {
+	if ks.signingKey == nil {
+		ks.signingKey = key
+	}
+	ks.keys[key.ID] = key
+	ks.keyIDs = append(ks.keyIDs, key.ID)
+}
+
+// keyFunc selects the verification key by the token's kid. Tokens without kid are checked
+// against the current signing key, so the ones issued before kids were added keep working.
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+
+	// Never let the token choose the algorithm
+	if token.Method.Alg() != ks.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+
+	keyID, _ := token.Header["kid"].(string)
+	if keyID == "" {
+		return ks.signingKey.PublicKey, nil
+	}
+
+	key, ok := ks.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %s", keyID)
+	}
+	return key.PublicKey, nil
+}
+
+// JWKS returns the public keys. HMAC secrets are never published.
+func (ks *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+
+	for _, keyID := range ks.keyIDs {
+		switch publicKey := ks.keys[keyID].PublicKey.(type) {
+		case *rsa.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				KeyType:   "RSA",
+				KeyID:     keyID,
+				Use:       "sig",
+				Algorithm: ks.method.Alg(),
+				N:         base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+				E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				KeyType:   "OKP",
+				KeyID:     keyID,
+				Use:       "sig",
+				Algorithm: ks.method.Alg(),
+				Curve:     "Ed25519",
+				X:         base64.RawURLEncoding.EncodeToString(publicKey),
+			})
+		}
+	}
+
+	return jwks
+}
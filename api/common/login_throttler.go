@@ -0,0 +1,132 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginThrottler keeps count of the failed logins per account and per IP. After a few failures
+// each new attempt has to wait exponentially longer, and after too many the key gets locked.
+type LoginThrottler interface {
+	Check(keys ...string) (retryAfter time.Duration, err error)
+	RegisterFailure(keys ...string) error
+	Reset(keys ...string) error
+}
+
+func UserThrottleKey(userID int) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+func IPThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+func NewLoginThrottler(config Lockout, db *gorm.DB) *loginThrottler {
+	return &loginThrottler{config: config, db: db}
+}
+
+type loginThrottler struct {
+	config Lockout
+	db     *gorm.DB
+}
+
+// Check returns an error if any of the keys is locked or still waiting for its backoff delay
+func (lt *loginThrottler) Check(keys ...string) (time.Duration, error) {
+	var throttles []LoginThrottle
+	if err := lt.db.Where("throttle_key IN ?", keys).Find(&throttles).Error; err != nil {
+		return 0, Wrap(err.Error(), ErrGettingLoginThrottle)
+	}
+	return checkThrottles(lt.config, throttles, time.Now())
+}
+
+func (lt *loginThrottler) RegisterFailure(keys ...string) error {
+	for _, key := range keys {
+		if err := lt.registerFailure(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// registerFailure is safe to run concurrently for the same key, both the insert and the increment are atomic
+func (lt *loginThrottler) registerFailure(key string) error {
+	var (
+		now      = time.Now()
+		window   = time.Minute * time.Duration(lt.config.LockoutMinutes)
+		throttle = LoginThrottle{ThrottleKey: key, LastFailedAt: now}
+	)
+
+	// Create it if it isn't there, with no failures yet
+	if err := lt.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&throttle).Error; err != nil {
+		return Wrap(err.Error(), ErrUpdatingLoginThrottle)
+	}
+
+	// Failures older than the window are forgotten. Otherwise increment
+	failedAttempts := gorm.Expr("CASE WHEN last_failed_at < ? THEN 1 ELSE failed_attempts + 1 END", now.Add(-window))
+
+	updates := map[string]interface{}{"failed_attempts": failedAttempts, "last_failed_at": now, "locked_until": nil}
+	if err := lt.db.Model(&LoginThrottle{}).Where("throttle_key = ?", key).Updates(updates).Error; err != nil {
+		return Wrap(err.Error(), ErrUpdatingLoginThrottle)
+	}
+
+	// Lock if there were too many
+	if err := lt.db.Where("throttle_key = ?", key).First(&throttle).Error; err != nil {
+		return Wrap(err.Error(), ErrGettingLoginThrottle)
+	}
+
+	if throttle.FailedAttempts >= lt.config.MaxFailedAttempts {
+		if err := lt.db.Model(&throttle).Update("locked_until", now.Add(window)).Error; err != nil {
+			return Wrap(err.Error(), ErrUpdatingLoginThrottle)
+		}
+	}
+
+	return nil
+}
+
+func (lt *loginThrottler) Reset(keys ...string) error {
+	if err := lt.db.Where("throttle_key IN ?", keys).Delete(&LoginThrottle{}).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return Wrap(err.Error(), ErrUpdatingLoginThrottle)
+	}
+	return nil
+}
+
+// checkThrottles is the part of Check that doesn't depend on where the throttles are stored
+func checkThrottles(config Lockout, throttles []LoginThrottle, now time.Time) (time.Duration, error) {
+	for _, throttle := range throttles {
+		if throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil) {
+			if throttle.IsUserKey() {
+				return throttle.LockedUntil.Sub(now), ErrAccountLocked
+			}
+			return throttle.LockedUntil.Sub(now), ErrTooManyLoginAttempts
+		}
+
+		retryAt := throttle.LastFailedAt.Add(backoffDelay(config, throttle.FailedAttempts))
+		if now.Before(retryAt) {
+			return retryAt.Sub(now), ErrTooManyLoginAttempts
+		}
+	}
+
+	return 0, nil
+}
+
+// backoffDelay is 0 for the first free attempts, then it doubles with each failure up to the max
+func backoffDelay(config Lockout, failedAttempts int) time.Duration {
+	if failedAttempts <= config.FreeAttempts {
+		return 0
+	}
+
+	maxDelay := time.Second * time.Duration(config.BackoffMaxSeconds)
+	delay := time.Second * time.Duration(config.BackoffBaseSeconds)
+	for i := config.FreeAttempts + 1; i < failedAttempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
@@ -0,0 +1,99 @@
+package common
+
+import (
+	"fmt"
+	"net/smtp"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Mailer sends emails to our users. Locally we just log them or write them to a file.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+const (
+	LogMailerType  = "log"
+	FileMailerType = "file"
+	SMTPMailerType = "smtp"
+)
+
+func NewMailer(config Mail, logger *logrus.Logger) Mailer {
+	switch config.Type {
+	case FileMailerType:
+		logger.Info("Mailer: writing emails to " + config.FilePath)
+		return &fileMailer{from: config.From, filePath: config.FilePath}
+	case SMTPMailerType:
+		logger.Info("Mailer: sending emails through " + config.SMTPHost)
+		return &smtpMailer{from: config.From, config: config}
+	default:
+		logger.Info("Mailer: logging emails")
+		return &logMailer{from: config.From, logger: logger}
+	}
+}
+
+/*-------------------
+//       LOG
+//-----------------*/
+
+type logMailer struct {
+	from   string
+	logger *logrus.Logger
+}
+
+func (m *logMailer) Send(to, subject, body string) error {
+	m.logger.WithField("from", m.from).WithField("to", to).WithField("subject", subject).Info(body)
+	return nil
+}
+
+/*-------------------
+//       FILE
+//-----------------*/
+
+type fileMailer struct {
+	from     string
+	filePath string
+	mu       sync.Mutex
+}
+
+func (m *fileMailer) Send(to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	file, err := os.OpenFile(m.filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return Wrap(err.Error(), ErrSendingEmail)
+	}
+	defer file.Close()
+
+	email := fmt.Sprintf("Date: %s\nFrom: %s\nTo: %s\nSubject: %s\n\n%s\n\n---\n\n", time.Now().Format(time.RFC1123Z), m.from, to, subject, body)
+	if _, err := file.WriteString(email); err != nil {
+		return Wrap(err.Error(), ErrSendingEmail)
+	}
+	return nil
+}
+
+/*-------------------
+//       SMTP
+//-----------------*/
+
+type smtpMailer struct {
+	from   string
+	config Mail
+}
+
+func (m *smtpMailer) Send(to, subject, body string) error {
+	var (
+		address = m.config.SMTPHost + ":" + m.config.SMTPPort
+		auth    = smtp.PlainAuth("", m.config.SMTPUsername, m.config.SMTPPassword, m.config.SMTPHost)
+		message = fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s\r\n", m.from, to, subject, body)
+	)
+
+	if err := smtp.SendMail(address, auth, m.from, []string{to}, []byte(message)); err != nil {
+		return Wrap(err.Error(), ErrSendingEmail)
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -26,7 +27,7 @@ func NewCORSConfigMiddleware() gin.HandlerFunc {
 		AllowCredentials: true,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Authentication", "Authorization", "Content-Type"},
-		ExposeHeaders:    []string{"Authentication", "Authorization", "Content-Type"},
+		ExposeHeaders:    []string{"Authentication", "Authorization", "Content-Type", "Retry-After"},
 	})
 }
 
@@ -403,6 +404,67 @@ func NewRateLimiter(requestsPerSecond int) *rate.Limiter { // TODO RPS to Config
 	return rate.NewLimiter(rate.Every(time.Second/time.Duration(requestsPerSecond)), requestsPerSecond)
 }
 
+// NewIPRateLimiterMiddleware is like NewRateLimiterMiddleware, but each IP gets its own limit
+func NewIPRateLimiterMiddleware(limiter *IPRateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !limiter.Allow(c.ClientIP()) {
+			c.Error(ErrTooManyRequests)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// IPRateLimiter keeps a rate.Limiter per IP. IPs that haven't been seen in a while are forgotten.
+type IPRateLimiter struct {
+	requestsPerSecond int
+	burst             int
+	limiters          map[string]*ipLimiter
+	lastCleanup       time.Time
+	mu                sync.Mutex
+}
+
+type ipLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+const ipRateLimiterCleanupInterval = time.Minute * 5
+
+func NewIPRateLimiter(requestsPerSecond, burst int) *IPRateLimiter {
+	return &IPRateLimiter{
+		requestsPerSecond: requestsPerSecond,
+		burst:             burst,
+		limiters:          map[string]*ipLimiter{},
+		lastCleanup:       time.Now(),
+	}
+}
+
+func (l *IPRateLimiter) Allow(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastCleanup) > ipRateLimiterCleanupInterval {
+		for key, limiter := range l.limiters {
+			if now.Sub(limiter.lastSeen) > ipRateLimiterCleanupInterval {
+				delete(l.limiters, key)
+			}
+		}
+		l.lastCleanup = now
+	}
+
+	limiter, ok := l.limiters[ip]
+	if !ok {
+		limiter = &ipLimiter{limiter: rate.NewLimiter(rate.Limit(l.requestsPerSecond), l.burst)}
+		l.limiters[ip] = limiter
+	}
+	limiter.lastSeen = now
+
+	return limiter.limiter.Allow()
+}
+
 func NewTimeoutMiddleware(timeoutSeconds int) gin.HandlerFunc {
 	return timeout.New(
 		timeout.WithTimeout(time.Duration(timeoutSeconds)*time.Second),
@@ -0,0 +1,454 @@
+package common
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+/*-------------------------
+//       MIGRATIONS
+//-----------------------*/
+
+// Migrations are applied in order of version and recorded on the schema_migrations table.
+// Most of them are SQL files on the migrations folder, embedded in the binary:
+//
+//	<version>_<name>.up.sql / <version>_<name>.down.sql
+//
+// A file can be overridden for a single database type, e.g. <version>_<name>.down.postgres.sql.
+// Statements are separated by semicolons. Use `migrate create <name>` to start a new one.
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var migrationFileRegex = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)(?:\.(mysql|postgres|sqlite))?\.sql$`)
+
+type Migration struct {
+	Version  int64
+	Name     string
+	Checksum string
+	Up       func(tx *gorm.DB) error
+	Down     func(tx *gorm.DB) error
+}
+
+type MigrationStatus struct {
+	Migration
+	Applied          bool
+	AppliedAt        *time.Time
+	ChecksumMismatch bool
+}
+
+// SchemaMigration is a migration that was already applied
+type SchemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255;not null"`
+	Checksum  string    `gorm:"size:64;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// SchemaMigrationLock has at most one row, held by whoever is migrating
+type SchemaMigrationLock struct {
+	ID       int       `gorm:"primaryKey;autoIncrement:false"`
+	LockedBy string    `gorm:"size:255;not null"`
+	LockedAt time.Time `gorm:"not null"`
+}
+
+// goMigrations are the ones that can't be written as plain SQL for every database type.
+// Their checksum is set here, the SQL ones get theirs from the content of the files.
+var goMigrations = []Migration{
+	{
+		// The schema as it was when we used AutoMigrate. It's a no-op on databases created back then.
+		Version:  1,
+		Name:     "initial_schema",
+		Checksum: modelsChecksum(initialSchema()),
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(initialSchema()...)
+		},
+		Down: func(tx *gorm.DB) error {
+			models := initialSchema()
+			tables := []interface{}{"post_tags", "role_permissions", "user_roles"}
+			for i := len(models) - 1; i >= 0; i-- {
+				tables = append(tables, models[i])
+			}
+			return tx.Migrator().DropTable(tables...)
+		},
+	},
+	{
+		// The default roles and permissions, that used to be created on every startup
+		Version:  2,
+		Name:     "create_initial_roles",
+		Checksum: initialRolesChecksum(),
+		Up:       createInitialRoles,
+		Down:     dropInitialRoles,
+	},
+	{
+		// The users flagged with the old is_admin column get the admin role instead
+		Version:  3,
+		Name:     "move_is_admin_to_roles",
+		Checksum: checksum(modelsChecksum([]interface{}{&isAdminUser{}}) + restoreIsAdminSQL),
+		Up:       moveIsAdminToRoles,
+		Down:     restoreIsAdmin,
+	},
+}
+
+type SchemaMigrator struct {
+	config     Migrations
+	db         *gorm.DB
+	logger     *logrus.Logger
+	migrations []Migration
+}
+
+func NewSchemaMigrator(config Migrations, db *gorm.DB, logger *logrus.Logger) (*SchemaMigrator, error) {
+	migrations, err := loadMigrations(db.Dialector.Name())
+	if err != nil {
+		return nil, Wrap(err.Error(), ErrLoadingMigrations)
+	}
+
+	if err := db.AutoMigrate(&SchemaMigration{}, &SchemaMigrationLock{}); err != nil {
+		return nil, Wrap(err.Error(), ErrMigrating)
+	}
+
+	return &SchemaMigrator{config: config, db: db, logger: logger, migrations: migrations}, nil
+}
+
+// Status returns every known migration, applied or not
+func (m *SchemaMigrator) Status() ([]MigrationStatus, error) {
+	var applied []SchemaMigration
+	if err := m.db.Order("version").Find(&applied).Error; err != nil {
+		return nil, Wrap(err.Error(), ErrGettingMigrations)
+	}
+
+	appliedByVersion := map[int64]SchemaMigration{}
+	for _, migration := range applied {
+		appliedByVersion[migration.Version] = migration
+	}
+
+	statuses := []MigrationStatus{}
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Migration: migration}
+		if record, ok := appliedByVersion[migration.Version]; ok {
+			appliedAt := record.AppliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+			status.ChecksumMismatch = record.Checksum != migration.Checksum
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Pending returns the migrations that still have to be applied.
+// It fails if an applied migration was changed afterwards.
+func (m *SchemaMigrator) Pending() ([]Migration, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []Migration{}
+	for _, status := range statuses {
+		if status.ChecksumMismatch {
+			return nil, Wrap(fmt.Sprintf("migration %d_%s", status.Version, status.Name), ErrMigrationChecksumMismatch)
+		}
+		if !status.Applied {
+			pending = append(pending, status.Migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// CheckUpToDate fails if there are pending migrations, or applied ones that don't match their files
+func (m *SchemaMigrator) CheckUpToDate() error {
+	pending, err := m.Pending()
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return Wrap(fmt.Sprintf("%d pending migrations, first one is %d_%s", len(pending), pending[0].Version, pending[0].Name), ErrSchemaBehind)
+	}
+	return nil
+}
+
+// Up applies every pending migration and returns how many were applied
+func (m *SchemaMigrator) Up() (int, error) {
+	applied := 0
+
+	err := m.withLock(func() error {
+		pending, err := m.Pending()
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range pending {
+			err := m.db.Transaction(func(tx *gorm.DB) error {
+				if err := migration.Up(tx); err != nil {
+					return err
+				}
+				record := SchemaMigration{Version: migration.Version, Name: migration.Name, Checksum: migration.Checksum, AppliedAt: time.Now()}
+				return tx.Create(&record).Error
+			})
+			if err != nil {
+				return Wrap(fmt.Sprintf("up %d_%s: %v", migration.Version, migration.Name, err), ErrMigrating)
+			}
+
+			m.logger.Info(fmt.Sprintf("Migrations: applied %d_%s", migration.Version, migration.Name))
+			applied++
+		}
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down reverts the last applied migrations, up to steps of them
+func (m *SchemaMigrator) Down(steps int) (int, error) {
+	reverted := 0
+
+	err := m.withLock(func() error {
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+
+		for i := len(statuses) - 1; i >= 0 && reverted < steps; i-- {
+			migration := statuses[i]
+			if !migration.Applied {
+				continue
+			}
+
+			err := m.db.Transaction(func(tx *gorm.DB) error {
+				if err := migration.Down(tx); err != nil {
+					return err
+				}
+				return tx.Delete(&SchemaMigration{}, "version = ?", migration.Version).Error
+			})
+			if err != nil {
+				return Wrap(fmt.Sprintf("down %d_%s: %v", migration.Version, migration.Name, err), ErrMigrating)
+			}
+
+			m.logger.Info(fmt.Sprintf("Migrations: reverted %d_%s", migration.Version, migration.Name))
+			reverted++
+		}
+		return nil
+	})
+
+	return reverted, err
+}
+
+// withLock runs fn while holding the migrations lock, so two replicas don't migrate at the same time.
+// A lock older than the stale timeout is considered abandoned and taken over, so while fn runs the lock is
+// kept fresh, however long the migrations take.
+func (m *SchemaMigrator) withLock(fn func() error) error {
+	hostname, _ := os.Hostname()
+	lockedBy := fmt.Sprintf("%s:%d", hostname, os.Getpid())
+	deadline := time.Now().Add(time.Second * time.Duration(m.config.LockTimeoutSeconds))
+
+	for {
+		err := m.db.Create(&SchemaMigrationLock{ID: 1, LockedBy: lockedBy, LockedAt: time.Now()}).Error
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, gorm.ErrDuplicatedKey) {
+			return Wrap(err.Error(), ErrMigrating)
+		}
+
+		staleBefore := time.Now().Add(-time.Minute * time.Duration(m.config.LockStaleMinutes))
+		if err := m.db.Where("id = ? AND locked_at < ?", 1, staleBefore).Delete(&SchemaMigrationLock{}).Error; err != nil {
+			return Wrap(err.Error(), ErrMigrating)
+		}
+
+		if time.Now().After(deadline) {
+			return ErrMigrationLocked
+		}
+		m.logger.Info("Migrations: waiting for another instance to finish migrating")
+		time.Sleep(time.Second)
+	}
+
+	stopRefreshing := m.refreshLock(lockedBy)
+
+	defer func() {
+		stopRefreshing()
+		if err := m.db.Where("id = ? AND locked_by = ?", 1, lockedBy).Delete(&SchemaMigrationLock{}).Error; err != nil {
+			m.logger.Error("Migrations: error releasing lock: " + err.Error())
+		}
+	}()
+
+	return fn()
+}
+
+// refreshLock updates locked_at a few times per stale timeout, until the returned func is called
+func (m *SchemaMigrator) refreshLock(lockedBy string) func() {
+	interval := time.Minute * time.Duration(m.config.LockStaleMinutes) / 3
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	var (
+		stop    = make(chan struct{})
+		stopped = make(chan struct{})
+	)
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				err := m.db.Model(&SchemaMigrationLock{}).Where("id = ? AND locked_by = ?", 1, lockedBy).Update("locked_at", time.Now()).Error
+				if err != nil {
+					m.logger.Error("Migrations: error refreshing lock: " + err.Error())
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-stopped
+	}
+}
+
+// loadMigrations merges the Go and SQL migrations for a database type, sorted by version
+func loadMigrations(dbType string) ([]Migration, error) {
+	type sqlMigration struct {
+		name           string
+		up, down       string
+		hasUp, hasDown bool
+	}
+
+	sqlMigrations := map[int64]*sqlMigration{}
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		matches := migrationFileRegex.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+
+		fileDBType := matches[4]
+		if fileDBType != "" && fileDBType != dbType {
+			continue
+		}
+
+		version, _ := strconv.ParseInt(matches[1], 10, 64)
+		migration, ok := sqlMigrations[version]
+		if !ok {
+			migration = &sqlMigration{name: matches[2]}
+			sqlMigrations[version] = migration
+		}
+		if migration.name != matches[2] {
+			return nil, fmt.Errorf("migration version %d has two names, %s and %s", version, migration.name, matches[2])
+		}
+
+		content, err := fs.ReadFile(migrationFiles, "migrations/"+entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		// Database specific files win over the generic ones, whatever the order they're read in
+		isSpecific := fileDBType != ""
+		if matches[3] == "up" && (!migration.hasUp || isSpecific) {
+			migration.up, migration.hasUp = string(content), true
+		}
+		if matches[3] == "down" && (!migration.hasDown || isSpecific) {
+			migration.down, migration.hasDown = string(content), true
+		}
+	}
+
+	migrations := append([]Migration{}, goMigrations...)
+
+	for version, migration := range sqlMigrations {
+		if !migration.hasUp || !migration.hasDown {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", version, migration.name)
+		}
+		migrations = append(migrations, Migration{
+			Version:  version,
+			Name:     migration.name,
+			Checksum: checksum(migration.up + migration.down),
+			Up:       sqlMigrationFunc(migration.up),
+			Down:     sqlMigrationFunc(migration.down),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("duplicated migration version %d", migrations[i].Version)
+		}
+	}
+
+	return migrations, nil
+}
+
+// sqlMigrationFunc runs the statements of a SQL file one by one, as not every driver allows several per Exec
+func sqlMigrationFunc(content string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		for _, statement := range strings.Split(content, ";") {
+			if strings.TrimSpace(removeSQLComments(statement)) == "" {
+				continue
+			}
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func removeSQLComments(statement string) string {
+	lines := []string{}
+	for _, line := range strings.Split(statement, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func checksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateMigrationFiles writes an empty up and down file for a new migration, versioned by the current time
+func CreateMigrationFiles(dir, name string) ([]string, error) {
+	name = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), " ", "_"))
+	if !regexp.MustCompile(`^[a-z0-9_]+$`).MatchString(name) {
+		return nil, fmt.Errorf("invalid migration name %q, use letters, numbers and underscores", name)
+	}
+
+	version := time.Now().UTC().Format("20060102150405")
+	paths := []string{}
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%s_%s.%s.sql", version, name, direction))
+		content := fmt.Sprintf("-- %s_%s (%s)\n", version, name, direction)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
@@ -0,0 +1 @@
+DROP INDEX idx_users_deleted_deleted_at ON users;
@@ -0,0 +1,2 @@
+-- The user purger looks for soft deleted users by deletion time
+CREATE INDEX idx_users_deleted_deleted_at ON users (deleted, deleted_at);
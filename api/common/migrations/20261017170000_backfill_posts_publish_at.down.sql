@@ -0,0 +1 @@
+-- Nothing to revert, the published posts had to have a publish_at anyway
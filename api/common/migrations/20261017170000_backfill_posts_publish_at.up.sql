@@ -0,0 +1,3 @@
+-- The feed is sorted by publish_at. Posts published before there were post states don't have one,
+-- they were published when they were created. The oldest ones don't even have a created_at
+UPDATE user_posts SET publish_at = COALESCE(created_at, CURRENT_TIMESTAMP) WHERE status = 'published' AND publish_at IS NULL;
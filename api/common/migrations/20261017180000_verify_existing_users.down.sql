@@ -0,0 +1 @@
+-- Nothing to revert, there's no telling which users were verified by the up migration
@@ -0,0 +1,3 @@
+-- Users from before email verification existed were added as not verified, and would be locked out
+-- once it's required. Every signup since then got a verification token, so the ones without any are the old ones
+UPDATE users SET verified = true WHERE verified = false AND id NOT IN (SELECT user_id FROM email_verification_tokens);
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"strings"
 	"time"
 )
 
@@ -9,12 +10,6 @@ import (
 // They are probably the most important part of the app.
 ------------------------*/
 
-var AllModels = []interface{}{
-	&User{},
-	&UserDetail{},
-	&UserPost{},
-}
-
 type Users []User
 
 type User struct {
@@ -22,17 +17,41 @@ type User struct {
 	Username  string `gorm:"unique;not null"`
 	Email     string `gorm:"unique;not null"`
 	Password  string `gorm:"not null"`
-	IsAdmin   bool
+	Roles     []Role `gorm:"many2many:user_roles"`
+	Verified  bool   `gorm:"not null;default:false"`
 	Details   UserDetail
 	Posts     UserPosts `gorm:"foreignKey:UserID;references:ID"`
 	Deleted   bool
 	CreatedAt time.Time
 	UpdatedAt time.Time
 
+	// Set on soft delete. After the grace period the user gets purged
+	DeletedAt *time.Time
+
+	// Access tokens issued before this are rejected
+	TokensRevokedAt *time.Time
+
+	// 2FA. The secret is set on enrollment, but it's only enabled after the first code is confirmed
+	TOTPSecret       string `gorm:"column:totp_secret;size:64"`
+	TOTPEnabled      bool   `gorm:"column:totp_enabled;not null;default:false"`
+	TOTPLastUsedStep int64  `gorm:"column:totp_last_used_step;not null;default:0"`
+
 	// DTOs
 	NewPassword string `gorm:"-"`
 }
 
+type Role struct {
+	ID          int          `gorm:"primaryKey"`
+	Name        RoleName     `gorm:"size:64;unique;not null"`
+	Permissions []Permission `gorm:"many2many:role_permissions"`
+	CreatedAt   time.Time
+}
+
+type Permission struct {
+	ID   int    `gorm:"primaryKey"`
+	Name string `gorm:"size:64;unique;not null"`
+}
+
 type UserDetail struct {
 	ID        int    `gorm:"primaryKey"`
 	UserID    int    `gorm:"unique;not null"`
@@ -45,10 +64,153 @@ type UserDetail struct {
 type UserPosts []UserPost
 
 type UserPost struct {
-	ID     int    `gorm:"primaryKey"`
-	Title  string `gorm:"not null"`
-	Body   string `gorm:"type:text"`
-	UserID int    `gorm:"not null"`
+	ID        int        `gorm:"primaryKey"`
+	Title     string     `gorm:"not null"`
+	Body      string     `gorm:"type:text"`
+	UserID    int        `gorm:"not null;index"`
+	Author    *User      `gorm:"foreignKey:UserID"`
+	Tags      Tags       `gorm:"many2many:post_tags"`
+	Status    string     `gorm:"size:16;not null;default:published;index:idx_post_status_publish_at"`
+	PublishAt *time.Time `gorm:"index:idx_post_status_publish_at"`
+	Deleted   bool       `gorm:"not null;default:false"`
+	DeletedAt *time.Time
+	CreatedAt time.Time `gorm:"index"`
+	UpdatedAt time.Time
+
+	// Counts, loaded separately
+	CommentCount   int            `gorm:"-"`
+	ReactionCounts map[string]int `gorm:"-"`
+}
+
+// Only published posts can be seen by others. Scheduled posts get published by the PostScheduler at their PublishAt.
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+	PostStatusArchived  = "archived"
+)
+
+var PostStatuses = []string{PostStatusDraft, PostStatusScheduled, PostStatusPublished, PostStatusArchived}
+
+func IsValidPostStatus(status string) bool {
+	for _, postStatus := range PostStatuses {
+		if status == postStatus {
+			return true
+		}
+	}
+	return false
+}
+
+type Tags []Tag
+
+type Tag struct {
+	ID        int    `gorm:"primaryKey"`
+	Name      string `gorm:"size:32;unique;not null"`
+	CreatedAt time.Time
+}
+
+// PostReaction is unique per post, user and kind, so reacting twice doesn't count twice
+type PostReaction struct {
+	ID        int    `gorm:"primaryKey"`
+	PostID    int    `gorm:"not null;uniqueIndex:idx_post_reaction"`
+	UserID    int    `gorm:"not null;uniqueIndex:idx_post_reaction;index"`
+	Kind      string `gorm:"size:32;not null;uniqueIndex:idx_post_reaction"`
+	CreatedAt time.Time
+}
+
+// ReactionKinds are the only reactions allowed
+var ReactionKinds = []string{"like", "love", "laugh", "sad", "angry"}
+
+type PostComments []PostComment
+
+// PostComment can answer another comment, but only top-level ones. There's just one level of threading.
+type PostComment struct {
+	ID        int          `gorm:"primaryKey"`
+	PostID    int          `gorm:"not null;index"`
+	UserID    int          `gorm:"not null;index"`
+	Author    *User        `gorm:"foreignKey:UserID"`
+	ParentID  *int         `gorm:"index"`
+	Replies   PostComments `gorm:"foreignKey:ParentID"`
+	Body      string       `gorm:"type:text;not null"`
+	Deleted   bool         `gorm:"not null;default:false"`
+	DeletedAt *time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// RefreshToken is stored hashed. All tokens rotated from the same login share a FamilyID,
+// so if an already used token is replayed we can revoke the whole family at once.
+type RefreshToken struct {
+	ID        int       `gorm:"primaryKey"`
+	UserID    int       `gorm:"not null;index"`
+	TokenHash string    `gorm:"size:64;unique;not null"`
+	FamilyID  string    `gorm:"size:64;not null;index"`
+	MFA       bool      `gorm:"not null;default:false"` // The session was started with 2FA
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// RevokedToken is an access token that was revoked before it expired. It's identified by its jti.
+type RevokedToken struct {
+	TokenID   string    `gorm:"primaryKey;size:64"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time
+}
+
+// PasswordResetToken is stored hashed and can only be used once
+type PasswordResetToken struct {
+	ID        int       `gorm:"primaryKey"`
+	UserID    int       `gorm:"not null;index"`
+	TokenHash string    `gorm:"size:64;unique;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// EmailVerificationToken is stored hashed and can only be used once
+type EmailVerificationToken struct {
+	ID        int       `gorm:"primaryKey"`
+	UserID    int       `gorm:"not null;index"`
+	TokenHash string    `gorm:"size:64;unique;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// LoginThrottle counts the recent failed logins of an account or an IP, see UserThrottleKey and IPThrottleKey
+type LoginThrottle struct {
+	ThrottleKey    string `gorm:"primaryKey;size:128"`
+	FailedAttempts int    `gorm:"not null;default:0"`
+	LastFailedAt   time.Time
+	LockedUntil    *time.Time
+}
+
+// RecoveryCode is a one-time 2FA code, for when the user loses their authenticator. Stored hashed.
+type RecoveryCode struct {
+	ID        int    `gorm:"primaryKey"`
+	UserID    int    `gorm:"not null;index"`
+	CodeHash  string `gorm:"size:64;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+const (
+	DataExportPending = "pending"
+	DataExportReady   = "ready"
+	DataExportFailed  = "failed"
+)
+
+// DataExport is a zip with everything we store about a user. It's built in the background.
+type DataExport struct {
+	ID          int    `gorm:"primaryKey"`
+	UserID      int    `gorm:"not null;index"`
+	Status      string `gorm:"size:16;not null"`
+	FilePath    string
+	CompletedAt *time.Time
+	ExpiresAt   *time.Time
+	CreatedAt   time.Time
 }
 
 /*---------------------------------------------------------------------------
@@ -60,27 +222,96 @@ type UserPost struct {
 //       AUTH
 //-----------------*/
 
-func (u *User) GenerateTokenString(a AuthI) (string, error) {
-	return a.GenerateToken(u.ID, u.Username, u.Email, u.GetRole())
+// GenerateTokenString needs the user's Roles and their Permissions to be loaded
+func (u *User) GenerateTokenString(a AuthI, mfa bool) (string, error) {
+	return a.GenerateToken(u.ID, u.Username, u.Email, u.GetRoleNames(), u.GetPermissions(), mfa)
+}
+
+func (t *RefreshToken) IsExpired() bool {
+	return time.Now().After(t.ExpiresAt)
+}
+
+// WasUsed is true if the token was already rotated or revoked
+func (t *RefreshToken) WasUsed() bool {
+	return t.UsedAt != nil || t.RevokedAt != nil
+}
+
+func (t *PasswordResetToken) IsUsable() bool {
+	return t.UsedAt == nil && time.Now().Before(t.ExpiresAt)
+}
+
+func (t *EmailVerificationToken) IsUsable() bool {
+	return t.UsedAt == nil && time.Now().Before(t.ExpiresAt)
+}
+
+func (e *DataExport) IsExpired() bool {
+	return e.ExpiresAt != nil && time.Now().After(*e.ExpiresAt)
+}
+
+func (e DataExport) ToResponseModel() ResponseDataExport {
+	return ResponseDataExport{
+		ID:          e.ID,
+		Status:      e.Status,
+		CreatedAt:   e.CreatedAt,
+		CompletedAt: e.CompletedAt,
+		ExpiresAt:   e.ExpiresAt,
+	}
+}
+
+func (t *LoginThrottle) IsUserKey() bool {
+	return strings.HasPrefix(t.ThrottleKey, "user:")
 }
 
 /*----------------
 //     USERS
 //--------------*/
 
-func (u *User) GetRole() Role {
-	if u.IsAdmin {
-		return AdminRole
+func (u *User) GetRoleNames() []RoleName {
+	roleNames := []RoleName{}
+	for _, role := range u.Roles {
+		roleNames = append(roleNames, role.Name)
 	}
-	return UserRole
+	return roleNames
 }
 
-func (u *User) HashPassword(salt string) {
-	u.Password = Hash(u.Password, salt)
+func (u *User) HasRole(roleName RoleName) bool {
+	for _, role := range u.Roles {
+		if role.Name == roleName {
+			return true
+		}
+	}
+	return false
 }
 
-func (u *User) PasswordMatches(password, salt string) bool {
-	return u.Password == Hash(password, salt)
+// GetPermissions returns the permissions of all of the user's roles, without duplicates
+func (u *User) GetPermissions() []string {
+	var (
+		permissions = []string{}
+		seen        = map[string]bool{}
+	)
+	for _, role := range u.Roles {
+		for _, permission := range role.Permissions {
+			if !seen[permission.Name] {
+				seen[permission.Name] = true
+				permissions = append(permissions, permission.Name)
+			}
+		}
+	}
+	return permissions
+}
+
+func (u *User) HashPassword(hasher PasswordHasher) error {
+	hashedPassword, err := hasher.Hash(u.Password)
+	if err != nil {
+		return Wrap(err.Error(), ErrHashingPassword)
+	}
+	u.Password = hashedPassword
+	return nil
+}
+
+// PasswordMatches also returns if the stored hash is outdated and should be replaced
+func (u *User) PasswordMatches(password string, hasher PasswordHasher) (bool, bool) {
+	return hasher.Verify(password, u.Password)
 }
 
 func (u *User) OverwriteFields(username, email, password string) {
@@ -119,15 +350,27 @@ func (u User) ToResponseModel() ResponseUser {
 		ID:        u.ID,
 		Username:  u.Username,
 		Email:     u.Email,
-		IsAdmin:   u.IsAdmin,
+		IsAdmin:   u.HasRole(AdminRole),
+		Roles:     u.GetRoleNames(),
+		Verified:  u.Verified,
+		TwoFactor: u.TOTPEnabled,
 		Details:   u.Details.ToResponseModel(),
 		Posts:     u.Posts.ToResponseModel(),
 		Deleted:   u.Deleted,
+		DeletedAt: u.DeletedAt,
 		CreatedAt: u.CreatedAt,
 		UpdatedAt: u.UpdatedAt,
 	}
 }
 
+// ToResponseAuthorModel is the public part of the user, it's nil if the user isn't loaded
+func (u *User) ToResponseAuthorModel() *ResponseAuthor {
+	if u == nil {
+		return nil
+	}
+	return &ResponseAuthor{ID: u.ID, Username: u.Username}
+}
+
 func (u Users) ToResponseModel() []ResponseUser {
 	users := []ResponseUser{}
 	for _, user := range u {
@@ -143,15 +386,107 @@ func (u UserDetail) ToResponseModel() ResponseUserDetail {
 	}
 }
 
+/*-------------------
+//      ROLES
+//-----------------*/
+
+func (r Role) ToResponseModel() ResponseRole {
+	permissions := []string{}
+	for _, permission := range r.Permissions {
+		permissions = append(permissions, permission.Name)
+	}
+	return ResponseRole{
+		ID:          r.ID,
+		Name:        r.Name,
+		Permissions: permissions,
+	}
+}
+
 /*-------------------
 //      POSTS
 //-----------------*/
 
 func (p UserPost) ToResponseModel() ResponseUserPost {
 	return ResponseUserPost{
-		ID:    p.ID,
-		Title: p.Title,
-		Body:  p.Body,
+		ID:             p.ID,
+		Title:          p.Title,
+		Body:           p.Body,
+		Author:         p.Author.ToResponseAuthorModel(),
+		Tags:           p.Tags.GetNames(),
+		Status:         p.Status,
+		PublishAt:      p.PublishAt,
+		CommentCount:   p.CommentCount,
+		ReactionCounts: p.ReactionCounts,
+		Deleted:        p.Deleted,
+		CreatedAt:      p.CreatedAt,
+		UpdatedAt:      p.UpdatedAt,
+	}
+}
+
+func (t Tags) GetNames() []string {
+	names := []string{}
+	for _, tag := range t {
+		names = append(names, tag.Name)
+	}
+	return names
+}
+
+/*-------------------
+//     COMMENTS
+//-----------------*/
+
+func (pc PostComment) ToResponseModel() ResponsePostComment {
+	return ResponsePostComment{
+		ID:        pc.ID,
+		PostID:    pc.PostID,
+		ParentID:  pc.ParentID,
+		Author:    pc.Author.ToResponseAuthorModel(),
+		Body:      pc.Body,
+		Replies:   pc.Replies.ToResponseModel(),
+		CreatedAt: pc.CreatedAt,
+		UpdatedAt: pc.UpdatedAt,
+	}
+}
+
+func (pc PostComments) ToResponseModel() []ResponsePostComment {
+	comments := []ResponsePostComment{}
+	for _, comment := range pc {
+		comments = append(comments, comment.ToResponseModel())
+	}
+	return comments
+}
+
+// SetStatus expects a valid status. Published posts keep their original PublishAt.
+func (p *UserPost) SetStatus(status string, publishAt *time.Time) {
+	switch status {
+	case PostStatusPublished:
+		if p.Status != PostStatusPublished || p.PublishAt == nil {
+			now := time.Now()
+			p.PublishAt = &now
+		}
+	case PostStatusScheduled:
+		p.PublishAt = publishAt
+	case PostStatusDraft:
+		p.PublishAt = nil
+	}
+	p.Status = status
+}
+
+// PublishedAt is when the post went public. Published posts from before there were post states may not have
+// a PublishAt, those were published when they were created.
+func (p *UserPost) PublishedAt() time.Time {
+	if p.PublishAt == nil {
+		return p.CreatedAt
+	}
+	return *p.PublishAt
+}
+
+func (p *UserPost) OverwriteFields(title, body *string) {
+	if title != nil {
+		p.Title = *title
+	}
+	if body != nil {
+		p.Body = *body
 	}
 }
 
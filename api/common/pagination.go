@@ -0,0 +1,34 @@
+package common
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// Cursor points to a row of a sorted list, so the next page starts right after it (or right before it, if Backward).
+// It's sent to clients as an opaque string, they shouldn't rely on what's inside.
+type Cursor struct {
+	Sort     string `json:"s"`
+	Value    string `json:"v"`
+	ID       int    `json:"i"`
+	Backward bool   `json:"b,omitempty"`
+}
+
+func (c Cursor) Encode() string {
+	cursorJSON, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(cursorJSON)
+}
+
+func DecodeCursor(encoded string) (Cursor, error) {
+	cursorJSON, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(cursorJSON, &cursor); err != nil || cursor.ID == 0 || cursor.Sort == "" {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
@@ -0,0 +1,139 @@
+package common
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHasher hashes passwords into strings that carry their own algorithm and parameters,
+// so when those change the old hashes can still be verified and then upgraded.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password, encodedHash string) (matches bool, needsRehash bool)
+}
+
+const (
+	Argon2idAlgorithm = "argon2id"
+	BcryptAlgorithm   = "bcrypt"
+
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+func NewPasswordHasher(config Passwords, legacySalt string) *passwordHasher {
+	return &passwordHasher{
+		algorithm:         config.HashAlgorithm,
+		argon2Memory:      config.Argon2MemoryKB,
+		argon2Iterations:  config.Argon2Iterations,
+		argon2Parallelism: config.Argon2Parallelism,
+		bcryptCost:        config.BcryptCost,
+		legacySalt:        legacySalt,
+	}
+}
+
+type passwordHasher struct {
+	algorithm string
+
+	argon2Memory      uint32
+	argon2Iterations  uint32
+	argon2Parallelism uint8
+	bcryptCost        int
+
+	// Hashes without a known prefix are the old SHA-256 + global salt ones
+	legacySalt string
+}
+
+// Hash always uses the configured algorithm and parameters. The config only allows argon2id and bcrypt.
+func (ph *passwordHasher) Hash(password string) (string, error) {
+	if ph.algorithm == BcryptAlgorithm {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), ph.bcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	}
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, ph.argon2Iterations, ph.argon2Memory, ph.argon2Parallelism, argon2KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, ph.argon2Memory, ph.argon2Iterations, ph.argon2Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify checks the password against a hash of any supported algorithm. needsRehash is true when
+// the password matches but the hash wasn't made with the current algorithm or parameters.
+func (ph *passwordHasher) Verify(password, encodedHash string) (bool, bool) {
+	switch {
+	case strings.HasPrefix(encodedHash, "$argon2id$"):
+		return ph.verifyArgon2id(password, encodedHash)
+	case strings.HasPrefix(encodedHash, "$2a$"), strings.HasPrefix(encodedHash, "$2b$"), strings.HasPrefix(encodedHash, "$2y$"):
+		return ph.verifyBcrypt(password, encodedHash)
+	default:
+		matches := subtle.ConstantTimeCompare([]byte(encodedHash), []byte(Hash(password, ph.legacySalt))) == 1
+		return matches, matches
+	}
+}
+
+func (ph *passwordHasher) verifyArgon2id(password, encodedHash string) (bool, bool) {
+	var (
+		version                 int
+		memory, iterations      uint32
+		parallelism             uint8
+		encodedSalt, encodedKey string
+	)
+
+	// $argon2id$v=19$m=65536,t=3,p=2$salt$key
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 {
+		return false, false
+	}
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &parallelism); err != nil {
+		return false, false
+	}
+	encodedSalt, encodedKey = parts[4], parts[5]
+
+	salt, err := base64.RawStdEncoding.DecodeString(encodedSalt)
+	if err != nil {
+		return false, false
+	}
+	expectedKey, err := base64.RawStdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return false, false
+	}
+
+	key := argon2.IDKey([]byte(password), salt, iterations, memory, parallelism, uint32(len(expectedKey)))
+	if subtle.ConstantTimeCompare(key, expectedKey) != 1 {
+		return false, false
+	}
+
+	needsRehash := ph.algorithm != Argon2idAlgorithm ||
+		memory != ph.argon2Memory || iterations != ph.argon2Iterations || parallelism != ph.argon2Parallelism
+
+	return true, needsRehash
+}
+
+func (ph *passwordHasher) verifyBcrypt(password, encodedHash string) (bool, bool) {
+	if err := bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(password)); err != nil {
+		return false, false
+	}
+
+	cost, err := bcrypt.Cost([]byte(encodedHash))
+	needsRehash := ph.algorithm != BcryptAlgorithm || err != nil || cost != ph.bcryptCost
+
+	return true, needsRehash
+}
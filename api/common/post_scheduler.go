@@ -0,0 +1,154 @@
+package common
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PostScheduler publishes the scheduled posts once their PublishAt is reached. Every replica runs one,
+// the rows are locked with SKIP LOCKED so each post is only published by one of them.
+type PostScheduler struct {
+	config Scheduler
+	db     *gorm.DB
+	logger *logrus.Logger
+
+	runs      prometheus.Counter
+	published prometheus.Counter
+	errors    prometheus.Counter
+	pending   prometheus.Gauge
+	lastRun   prometheus.Gauge
+}
+
+var metricSchedulerRuns = &Metric{
+	ID:          "schedulerRuns",
+	Name:        "scheduler_runs",
+	Description: "Times the post scheduler looked for posts to publish.",
+	Type:        "counter",
+}
+
+var metricSchedulerPublished = &Metric{
+	ID:          "schedulerPublished",
+	Name:        "scheduler_published_posts",
+	Description: "Scheduled posts published by this instance.",
+	Type:        "counter",
+}
+
+var metricSchedulerErrors = &Metric{
+	ID:          "schedulerErrors",
+	Name:        "scheduler_errors",
+	Description: "Failed post scheduler runs.",
+	Type:        "counter",
+}
+
+var metricSchedulerPending = &Metric{
+	ID:          "schedulerPending",
+	Name:        "scheduler_pending_posts",
+	Description: "Scheduled posts still waiting to be published, after the last run.",
+	Type:        "gauge",
+}
+
+var metricSchedulerLastRun = &Metric{
+	ID:          "schedulerLastRun",
+	Name:        "scheduler_last_run_timestamp_seconds",
+	Description: "Unix time of the last successful post scheduler run.",
+	Type:        "gauge",
+}
+
+// NewPostScheduler only registers its metrics if Prometheus is enabled
+func NewPostScheduler(config Scheduler, monitoring Monitoring, db *gorm.DB, logger *logrus.Logger) *PostScheduler {
+	s := &PostScheduler{
+		config:    config,
+		db:        db,
+		logger:    logger,
+		runs:      NewMetric(metricSchedulerRuns, monitoring.PrometheusAppName).(prometheus.Counter),
+		published: NewMetric(metricSchedulerPublished, monitoring.PrometheusAppName).(prometheus.Counter),
+		errors:    NewMetric(metricSchedulerErrors, monitoring.PrometheusAppName).(prometheus.Counter),
+		pending:   NewMetric(metricSchedulerPending, monitoring.PrometheusAppName).(prometheus.Gauge),
+		lastRun:   NewMetric(metricSchedulerLastRun, monitoring.PrometheusAppName).(prometheus.Gauge),
+	}
+
+	if monitoring.PrometheusEnabled {
+		for _, metric := range []prometheus.Collector{s.runs, s.published, s.errors, s.pending, s.lastRun} {
+			if err := prometheus.Register(metric); err != nil {
+				logger.Error(err.Error())
+			}
+		}
+	}
+
+	return s
+}
+
+// Run publishes the due posts every IntervalSeconds. It never returns, call it on a goroutine.
+func (s *PostScheduler) Run() {
+	ticker := time.NewTicker(time.Second * time.Duration(s.config.IntervalSeconds))
+	defer ticker.Stop()
+
+	for {
+		s.runs.Inc()
+		if published, err := s.PublishDue(); err != nil {
+			s.errors.Inc()
+			s.logger.WithField("published", published).Error("Post Scheduler: " + err.Error())
+		} else {
+			s.lastRun.SetToCurrentTime()
+			if published > 0 {
+				s.logger.WithField("published", published).Info("Post Scheduler: posts published")
+			}
+		}
+		<-ticker.C
+	}
+}
+
+// PublishDue publishes batches of due posts until there are none left, and returns how many it published
+func (s *PostScheduler) PublishDue() (int, error) {
+	total := 0
+	for {
+		published, err := s.publishBatch()
+		total += published
+		s.published.Add(float64(published))
+		if err != nil {
+			return total, err
+		}
+		if published < s.config.BatchSize {
+			break
+		}
+	}
+
+	var pending int64
+	if err := s.db.Model(&UserPost{}).Where("status = ? AND deleted = ?", PostStatusScheduled, false).Count(&pending).Error; err != nil {
+		return total, Wrap(err.Error(), ErrGettingUserPost)
+	}
+	s.pending.Set(float64(pending))
+
+	return total, nil
+}
+
+// publishBatch locks the due posts, skipping the ones other replicas already locked
+func (s *PostScheduler) publishBatch() (int, error) {
+	published := 0
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var postIDs []int
+		err := tx.Model(&UserPost{}).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND publish_at <= ? AND deleted = ?", PostStatusScheduled, time.Now(), false).
+			Order("publish_at").
+			Limit(s.config.BatchSize).
+			Pluck("id", &postIDs).Error
+		if err != nil || len(postIDs) == 0 {
+			return err
+		}
+
+		result := tx.Model(&UserPost{}).Where("id IN ? AND status = ?", postIDs, PostStatusScheduled).Update("status", PostStatusPublished)
+		published = int(result.RowsAffected)
+		return result.Error
+	})
+	if err != nil {
+		return 0, Wrap(err.Error(), ErrPublishingPosts)
+	}
+
+	return published, nil
+}
@@ -0,0 +1,248 @@
+package common
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+/*-------------------------
+//      REPOSITORIES
+//-----------------------*/
+
+// Repositories are how the handlers talk to the database. There's a GORM implementation, and an in-memory one
+// so the handlers can be tested without a database. Their errors are already mapped to ours, e.g. ErrUserNotFound.
+type Repositories struct {
+	Users    UserRepository
+	Tokens   TokenRepository
+	Roles    RoleRepository
+	Posts    PostRepository
+	Comments CommentRepository
+	Exports  DataExportRepository
+
+	UnitOfWork UnitOfWork
+}
+
+// UnitOfWork runs several repository calls atomically. The repositories given to fn share a transaction,
+// committed if fn returns nil and rolled back if it returns an error or panics. Errors from fn are returned
+// as they are, the rest are ErrInDBTransaction. Deadlocks and serialization failures run fn again.
+// Inside fn, repos.UnitOfWork joins the same transaction.
+type UnitOfWork interface {
+	Do(fn func(repos Repositories) error) error
+	DoWithIsolation(isolation sql.IsolationLevel, fn func(repos Repositories) error) error
+}
+
+// TxOptions are the defaults of the unit of work
+type TxOptions struct {
+	Isolation  sql.IsolationLevel
+	MaxRetries int
+}
+
+type UserRepository interface {
+	Create(user *User) error
+
+	// Get returns deleted users too, the others don't
+	Get(userID int) (User, error)
+	GetActive(userID int) (User, error)
+	GetActiveByUsernameOrEmail(username, email string) (User, error)
+
+	// GetActiveByID also has the details and roles
+	GetActiveByID(userID int) (User, error)
+
+	// ExistsByUsernameOrEmailExcept checks the non-empty username and email against the other users, deleted ones too
+	ExistsByUsernameOrEmailExcept(userID int, username, email string) (bool, error)
+
+	// GetWithDetails also has the details, roles and posts that aren't deleted, of any user.
+	// GetWithPermissions has the roles and their permissions.
+	GetWithDetails(userID int) (User, error)
+	GetWithPermissions(userID int) (User, error)
+
+	Search(search UserSearch) (Users, *int64, error)
+
+	// Update saves the user without its details or roles, UpdateDetails saves the details, creating them if needed.
+	// UpdateFields sets the columns on the user too.
+	Update(user *User) error
+	UpdateDetails(details *UserDetail) error
+	UpdateFields(user *User, fields map[string]interface{}) error
+	UpdatePassword(userID int, hashedPassword string) error
+
+	// UseTOTPStep is false if the step, or a later one, was already used
+	UseTOTPStep(userID int, step int64) (bool, error)
+
+	// AddRoles and RemoveRoles reload the roles of the user afterwards
+	AddRoles(user *User, roles []Role) error
+	RemoveRoles(user *User, roles []Role) error
+
+	SoftDelete(user *User) error
+	Restore(user *User) error
+
+	// HardDelete returns the files of the user's data exports. They're not removed, as the deletion could still
+	// be rolled back, it's up to the caller to remove them once it's committed.
+	HardDelete(userID int) (exportFilePaths []string, err error)
+
+	// StartMissingGracePeriods sets deleted_at to now on the users deleted before we kept track of when.
+	// ListDeletedBefore returns the IDs of the users whose grace period started before deletedBefore.
+	StartMissingGracePeriods() error
+	ListDeletedBefore(deletedBefore time.Time) ([]int, error)
+}
+
+// TokenRepository has the refresh tokens and the one-time tokens and codes. Only their hashes are stored.
+type TokenRepository interface {
+	CreateRefreshToken(refreshToken *RefreshToken) error
+	GetRefreshToken(tokenHash string) (RefreshToken, error)
+	GetUserRefreshToken(tokenHash string, userID int) (RefreshToken, error)
+	UseRefreshToken(refreshTokenID int) (bool, error)
+	RevokeRefreshTokenFamily(familyID string) error
+
+	CreatePasswordResetToken(resetToken *PasswordResetToken) error
+	GetPasswordResetToken(tokenHash string) (PasswordResetToken, error)
+	UsePasswordResetToken(resetTokenID int) (bool, error)
+	InvalidatePasswordResetTokens(userID int) error
+
+	CreateEmailVerificationToken(verificationToken *EmailVerificationToken) error
+	GetEmailVerificationToken(tokenHash string) (EmailVerificationToken, error)
+	UseEmailVerificationToken(verificationTokenID int) error
+	InvalidateEmailVerificationTokens(userID int) error
+
+	ReplaceRecoveryCodes(userID int, recoveryCodes []RecoveryCode) error
+	UseRecoveryCode(userID int, codeHash string) (bool, error)
+	DeleteRecoveryCodes(userID int) error
+}
+
+type RoleRepository interface {
+	Create(role *Role) error
+	GetAll() ([]Role, error)
+	GetByName(name RoleName) (Role, error)
+
+	// GetByNames and GetPermissionsByNames leave out the names that don't exist
+	GetByNames(names ...RoleName) ([]Role, error)
+	GetPermissionsByNames(names []string) ([]Permission, error)
+}
+
+// PostRepository has the posts, their tags and their reactions
+type PostRepository interface {
+	Create(post *UserPost) error
+
+	// GetUserPost and ListUserPosts are for the owner, in any status. GetPublic and ListPublic only have published posts.
+	GetUserPost(userID, postID int) (UserPost, error)
+	ListUserPosts(userID int, status string, offset, limit int) (UserPosts, int64, error)
+	GetPublic(postID int) (UserPost, error)
+	ListPublic(feed PostFeed) (UserPosts, error)
+
+	// Update saves the title, body, status and publish_at
+	Update(post *UserPost) error
+	ReplaceTags(post *UserPost, tags Tags) error
+	SoftDelete(post *UserPost) error
+
+	// LoadCounts sets the comment and reaction counts of the posts
+	LoadCounts(posts UserPosts) error
+
+	// FindOrCreateTags expects the names to be already normalized
+	FindOrCreateTags(names []string) (Tags, error)
+	SearchTags(prefix string, limit int) ([]ResponseTag, error)
+
+	// React and Unreact don't fail if the reaction was already there, or wasn't
+	React(reaction *PostReaction) error
+	Unreact(postID, userID int, kind string) error
+}
+
+type CommentRepository interface {
+	Create(comment *PostComment) error
+
+	// Get returns the comment with its author, only if it's from the post and isn't deleted
+	Get(postID, commentID int) (PostComment, error)
+	ListTopLevel(postID int, offset, limit int) (PostComments, int64, error)
+
+	UpdateBody(comment *PostComment) error
+	SoftDeleteWithReplies(commentID int) error
+}
+
+type DataExportRepository interface {
+	Create(export *DataExport) error
+
+	// Get only returns exports of the given user. GetPending only returns the ones created after createdAfter.
+	Get(userID, exportID int) (DataExport, error)
+	GetPending(userID int, createdAfter time.Time) (DataExport, error)
+	UpdateFields(exportID int, fields map[string]interface{}) error
+
+	// ListExpired returns the exports that expired before expiredBefore and still have a file
+	ListExpired(expiredBefore time.Time) ([]DataExport, error)
+
+	// CollectUserData gets everything we store about the user of the export, to write it with WriteUserDataExport
+	CollectUserData(export DataExport) (UserExportData, error)
+}
+
+// UserSearch results are sorted by SortField and then id. With After, only the users past that position are returned.
+type UserSearch struct {
+	Username     string
+	Filters      []UserFilter
+	IncludeTotal bool
+	SortField    string
+	Descending   bool
+	After        *KeysetPosition
+	Offset       int
+	Limit        int
+}
+
+// PostFeed results are sorted by publication time and then id, so scheduled posts show up when they're published. With After, only the posts past that position are returned.
+type PostFeed struct {
+	Tag        string
+	Descending bool
+	After      *KeysetPosition
+	Limit      int
+}
+
+// KeysetPosition is where a page ended, the value of the sort field and the id of the last row
+type KeysetPosition struct {
+	Value interface{}
+	ID    int
+}
+
+// errTxConflict is a deadlock or a serialization failure. The unit of work runs the transaction again on those.
+var errTxConflict = Wrap("deadlock or serialization failure", ErrInDBTransaction)
+
+// retryTx runs the transaction again while it fails with errTxConflict, waiting longer each time.
+// There's some jitter so the conflicting transactions don't meet again.
+func retryTx(maxRetries int, runTx func() error) error {
+	err := runTx()
+	for retry := 1; retry <= maxRetries && errors.Is(err, errTxConflict); retry++ {
+		delay := time.Duration(retry*retry) * 10 * time.Millisecond
+		time.Sleep(delay + time.Duration(rand.Int63n(int64(delay))))
+		err = runTx()
+	}
+	if errors.Is(err, errTxConflict) {
+		return Wrap(fmt.Sprintf("retryTx: gave up after %d retries", maxRetries), err)
+	}
+	return err
+}
+
+// mapDBError maps gorm's not found error to notFoundErr and any other to otherErr
+func mapDBError(err error, notFoundErr, otherErr error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Wrap(err.Error(), notFoundErr)
+	}
+	return wrapDBError(err, otherErr)
+}
+
+// wrapDBError wraps err with otherErr, unless the transaction can be retried
+func wrapDBError(err error, otherErr error) error {
+	if isTxConflict(err) {
+		return Wrap(err.Error(), errTxConflict)
+	}
+	return Wrap(err.Error(), otherErr)
+}
+
+// setPostCounts leaves the counts of the posts that aren't in the maps in zero
+func setPostCounts(posts UserPosts, commentCounts map[int]int, reactionCounts map[int]map[string]int) {
+	for i := range posts {
+		posts[i].CommentCount = commentCounts[posts[i].ID]
+		posts[i].ReactionCounts = reactionCounts[posts[i].ID]
+		if posts[i].ReactionCounts == nil {
+			posts[i].ReactionCounts = map[string]int{}
+		}
+	}
+}
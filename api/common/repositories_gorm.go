@@ -0,0 +1,901 @@
+package common
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func NewGormRepositories(db *gorm.DB, txOptions TxOptions) Repositories {
+	return newGormRepositories(db, &gormUnitOfWork{db: db, options: txOptions})
+}
+
+func newGormRepositories(db *gorm.DB, unitOfWork UnitOfWork) Repositories {
+	return Repositories{
+		Users:      &userRepository{db: db},
+		Tokens:     &tokenRepository{db: db},
+		Roles:      &roleRepository{db: db},
+		Posts:      &postRepository{db: db},
+		Comments:   &commentRepository{db: db},
+		Exports:    &dataExportRepository{db: db},
+		UnitOfWork: unitOfWork,
+	}
+}
+
+/*-------------------------
+//         USERS
+//-----------------------*/
+
+type userRepository struct {
+	db *gorm.DB
+}
+
+func (r *userRepository) Create(user *User) error {
+	if err := r.db.Create(user).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return Wrap(err.Error(), ErrUsernameOrEmailAlreadyInUse)
+		}
+		return wrapDBError(err, ErrCreatingUser)
+	}
+	return nil
+}
+
+func (r *userRepository) Get(userID int) (User, error) {
+	return r.first(r.db.Where("id = ?", userID))
+}
+
+func (r *userRepository) GetActive(userID int) (User, error) {
+	return r.first(r.db.Where("id = ? AND deleted = false", userID))
+}
+
+func (r *userRepository) GetActiveByUsernameOrEmail(username, email string) (User, error) {
+	return r.first(r.db.Where("(username = ? OR email = ?) AND deleted = false", username, email))
+}
+
+func (r *userRepository) GetActiveByID(userID int) (User, error) {
+	return r.first(r.db.Preload("Details").Preload("Roles").Where("id = ? AND deleted = false", userID))
+}
+
+func (r *userRepository) ExistsByUsernameOrEmailExcept(userID int, username, email string) (bool, error) {
+	if username == "" && email == "" {
+		return false, nil
+	}
+
+	var count int64
+	query := r.db.Model(&User{}).Where("id <> ?", userID).Where(r.db.Where("username = ? AND username <> ''", username).Or("email = ? AND email <> ''", email))
+	if err := query.Count(&count).Error; err != nil {
+		return false, wrapDBError(err, ErrGettingUser)
+	}
+	return count > 0, nil
+}
+
+func (r *userRepository) GetWithDetails(userID int) (User, error) {
+	return r.first(r.db.Preload("Details").Preload("Posts", "deleted = ?", false).Preload("Roles").Where("id = ?", userID))
+}
+
+func (r *userRepository) GetWithPermissions(userID int) (User, error) {
+	return r.first(r.db.Preload("Roles.Permissions").Where("id = ?", userID))
+}
+
+func (r *userRepository) first(query *gorm.DB) (User, error) {
+	user := User{}
+	if err := query.First(&user).Error; err != nil {
+		return User{}, mapDBError(err, ErrUserNotFound, ErrGettingUser)
+	}
+	return user, nil
+}
+
+// Search uses keyset pagination if there's an After position, and offset pagination if there isn't.
+// The SortField goes straight into the query, so it has to come from an allowlist.
+func (r *userRepository) Search(search UserSearch) (Users, *int64, error) {
+	var users Users
+
+	filter := r.db.Model(&User{}).Where("username LIKE ? ESCAPE '!'", EscapeLike(search.Username))
+	filter = ApplyUserFilters(filter, search.Filters)
+	if !HasUserFilter(search.Filters, "deleted") {
+		filter = filter.Where("deleted = ?", false)
+	}
+
+	// Total
+	var total *int64
+	if search.IncludeTotal {
+		total = new(int64)
+		if err := filter.Session(&gorm.Session{}).Count(total).Error; err != nil {
+			return nil, nil, wrapDBError(err, ErrSearchingUsers)
+		}
+	}
+
+	direction, comparison := "ASC", ">"
+	if search.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	field := search.SortField
+	query := filter.Preload("Details").Preload("Roles").Order(fmt.Sprintf("%s %s, id %s", field, direction, direction))
+	if search.After != nil {
+		condition := fmt.Sprintf("((%s %s ?) OR (%s = ? AND id %s ?))", field, comparison, field, comparison)
+		query = query.Where(condition, search.After.Value, search.After.Value, search.After.ID)
+	} else {
+		query = query.Offset(search.Offset)
+	}
+
+	if err := query.Limit(search.Limit).Find(&users).Error; err != nil {
+		return nil, nil, wrapDBError(err, ErrSearchingUsers)
+	}
+
+	return users, total, nil
+}
+
+func (r *userRepository) Update(user *User) error {
+	if err := r.db.Omit("Details", "Roles").Save(user).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return Wrap(err.Error(), ErrUsernameOrEmailAlreadyInUse)
+		}
+		return wrapDBError(err, ErrUpdatingUser)
+	}
+	return nil
+}
+
+func (r *userRepository) UpdateDetails(details *UserDetail) error {
+	if err := r.db.Save(details).Error; err != nil {
+		return wrapDBError(err, ErrUpdatingUserDetail)
+	}
+	return nil
+}
+
+func (r *userRepository) UpdateFields(user *User, fields map[string]interface{}) error {
+	if err := r.db.Model(user).Updates(fields).Error; err != nil {
+		return wrapDBError(err, ErrUpdatingUser)
+	}
+	return nil
+}
+
+func (r *userRepository) UpdatePassword(userID int, hashedPassword string) error {
+	if err := r.db.Model(&User{}).Where("id = ?", userID).Update("password", hashedPassword).Error; err != nil {
+		return wrapDBError(err, ErrUpdatingUser)
+	}
+	return nil
+}
+
+// UseTOTPStep only lets one concurrent request use the step
+func (r *userRepository) UseTOTPStep(userID int, step int64) (bool, error) {
+	result := r.db.Model(&User{}).Where("id = ? AND totp_last_used_step < ?", userID, step).Update("totp_last_used_step", step)
+	if result.Error != nil {
+		return false, wrapDBError(result.Error, ErrUpdatingUser)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *userRepository) AddRoles(user *User, roles []Role) error {
+	if err := r.db.Model(user).Association("Roles").Append(&roles); err != nil {
+		return wrapDBError(err, ErrUpdatingUserRoles)
+	}
+	return r.reloadRoles(user)
+}
+
+func (r *userRepository) RemoveRoles(user *User, roles []Role) error {
+	if err := r.db.Model(user).Association("Roles").Delete(&roles); err != nil {
+		return wrapDBError(err, ErrUpdatingUserRoles)
+	}
+	return r.reloadRoles(user)
+}
+
+func (r *userRepository) reloadRoles(user *User) error {
+	user.Roles = nil
+	if err := r.db.Model(user).Association("Roles").Find(&user.Roles); err != nil {
+		return wrapDBError(err, ErrGettingRoles)
+	}
+	return nil
+}
+
+// SoftDelete sets the deletion fields on the user too. It can be restored until it gets purged.
+func (r *userRepository) SoftDelete(user *User) error {
+	now := time.Now()
+	user.Deleted, user.DeletedAt = true, &now
+	if err := r.db.Model(user).Select("deleted", "deleted_at").Updates(user).Error; err != nil {
+		return wrapDBError(err, ErrDeletingUser)
+	}
+	return nil
+}
+
+func (r *userRepository) Restore(user *User) error {
+	user.Deleted, user.DeletedAt = false, nil
+	if err := r.db.Model(user).Select("deleted", "deleted_at").Updates(user).Error; err != nil {
+		return wrapDBError(err, ErrUpdatingUser)
+	}
+	return nil
+}
+
+func (r *userRepository) StartMissingGracePeriods() error {
+	if err := r.db.Model(&User{}).Where("deleted = ? AND deleted_at IS NULL", true).Update("deleted_at", time.Now()).Error; err != nil {
+		return wrapDBError(err, ErrUpdatingUser)
+	}
+	return nil
+}
+
+func (r *userRepository) ListDeletedBefore(deletedBefore time.Time) ([]int, error) {
+	var userIDs []int
+	if err := r.db.Model(&User{}).Where("deleted = ? AND deleted_at < ?", true, deletedBefore).Order("id").Pluck("id", &userIDs).Error; err != nil {
+		return nil, wrapDBError(err, ErrGettingUser)
+	}
+	return userIDs, nil
+}
+
+// HardDelete removes the user and everything that belongs to them in a single transaction, and the zips of their exports
+func (r *userRepository) HardDelete(userID int) ([]string, error) {
+	var filePaths []string
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&DataExport{}).Where("user_id = ? AND file_path <> ''", userID).Pluck("file_path", &filePaths).Error; err != nil {
+			return err
+		}
+
+		// Comments & reactions first: the ones on their posts, their own ones and the replies to them
+		var postIDs, commentIDs []int
+		if err := tx.Model(&UserPost{}).Where("user_id = ?", userID).Pluck("id", &postIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&PostComment{}).Where("user_id = ?", userID).Pluck("id", &commentIDs).Error; err != nil {
+			return err
+		}
+		err := tx.Where("user_id = ? OR post_id IN ? OR parent_id IN ?", userID, append(postIDs, 0), append(commentIDs, 0)).Delete(&PostComment{}).Error
+		if err != nil {
+			return err
+		}
+
+		// Same with reactions
+		if err := tx.Where("user_id = ? OR post_id IN ?", userID, append(postIDs, 0)).Delete(&PostReaction{}).Error; err != nil {
+			return err
+		}
+
+		// Tags stay, but not on their posts
+		if err := tx.Exec("DELETE FROM post_tags WHERE user_post_id IN ?", append(postIDs, 0)).Error; err != nil {
+			return err
+		}
+
+		userOwned := []interface{}{
+			&UserDetail{}, &UserPost{}, &RefreshToken{}, &PasswordResetToken{}, &EmailVerificationToken{}, &RecoveryCode{}, &DataExport{},
+		}
+		for _, model := range userOwned {
+			if err := tx.Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&User{ID: userID}).Association("Roles").Clear(); err != nil {
+			return err
+		}
+
+		if err := tx.Where("throttle_key = ?", UserThrottleKey(userID)).Delete(&LoginThrottle{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&User{}, userID).Error
+	})
+	if err != nil {
+		return nil, wrapDBError(err, ErrDeletingUser)
+	}
+	return filePaths, nil
+}
+
+/*-------------------------
+//         TOKENS
+//-----------------------*/
+
+type tokenRepository struct {
+	db *gorm.DB
+}
+
+func (r *tokenRepository) CreateRefreshToken(refreshToken *RefreshToken) error {
+	if err := r.db.Create(refreshToken).Error; err != nil {
+		return wrapDBError(err, ErrCreatingRefreshToken)
+	}
+	return nil
+}
+
+func (r *tokenRepository) GetRefreshToken(tokenHash string) (RefreshToken, error) {
+	refreshToken := RefreshToken{}
+	if err := r.db.Where("token_hash = ?", tokenHash).First(&refreshToken).Error; err != nil {
+		return RefreshToken{}, mapDBError(err, ErrInvalidRefreshToken, ErrGettingRefreshToken)
+	}
+	return refreshToken, nil
+}
+
+func (r *tokenRepository) GetUserRefreshToken(tokenHash string, userID int) (RefreshToken, error) {
+	refreshToken := RefreshToken{}
+	if err := r.db.Where("token_hash = ? AND user_id = ?", tokenHash, userID).First(&refreshToken).Error; err != nil {
+		return RefreshToken{}, mapDBError(err, ErrInvalidRefreshToken, ErrGettingRefreshToken)
+	}
+	return refreshToken, nil
+}
+
+// UseRefreshToken only lets one concurrent request use the token
+func (r *tokenRepository) UseRefreshToken(refreshTokenID int) (bool, error) {
+	result := r.db.Model(&RefreshToken{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", refreshTokenID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, wrapDBError(result.Error, ErrUpdatingRefreshToken)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *tokenRepository) RevokeRefreshTokenFamily(familyID string) error {
+	err := r.db.Model(&RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return wrapDBError(err, ErrUpdatingRefreshToken)
+	}
+	return nil
+}
+
+func (r *tokenRepository) CreatePasswordResetToken(resetToken *PasswordResetToken) error {
+	if err := r.db.Create(resetToken).Error; err != nil {
+		return wrapDBError(err, ErrCreatingPasswordResetToken)
+	}
+	return nil
+}
+
+func (r *tokenRepository) GetPasswordResetToken(tokenHash string) (PasswordResetToken, error) {
+	resetToken := PasswordResetToken{}
+	if err := r.db.Where("token_hash = ?", tokenHash).First(&resetToken).Error; err != nil {
+		return PasswordResetToken{}, mapDBError(err, ErrInvalidPasswordResetToken, ErrGettingPasswordResetToken)
+	}
+	return resetToken, nil
+}
+
+// UsePasswordResetToken only lets one concurrent request use the token
+func (r *tokenRepository) UsePasswordResetToken(resetTokenID int) (bool, error) {
+	result := r.db.Model(&PasswordResetToken{}).Where("id = ? AND used_at IS NULL", resetTokenID).Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, wrapDBError(result.Error, ErrUpdatingPasswordResetToken)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *tokenRepository) InvalidatePasswordResetTokens(userID int) error {
+	if err := r.db.Model(&PasswordResetToken{}).Where("user_id = ? AND used_at IS NULL", userID).Update("used_at", time.Now()).Error; err != nil {
+		return wrapDBError(err, ErrUpdatingPasswordResetToken)
+	}
+	return nil
+}
+
+func (r *tokenRepository) CreateEmailVerificationToken(verificationToken *EmailVerificationToken) error {
+	if err := r.db.Create(verificationToken).Error; err != nil {
+		return wrapDBError(err, ErrCreatingEmailVerificationToken)
+	}
+	return nil
+}
+
+func (r *tokenRepository) GetEmailVerificationToken(tokenHash string) (EmailVerificationToken, error) {
+	verificationToken := EmailVerificationToken{}
+	if err := r.db.Where("token_hash = ?", tokenHash).First(&verificationToken).Error; err != nil {
+		return EmailVerificationToken{}, mapDBError(err, ErrInvalidEmailVerificationToken, ErrGettingEmailVerificationToken)
+	}
+	return verificationToken, nil
+}
+
+func (r *tokenRepository) UseEmailVerificationToken(verificationTokenID int) error {
+	if err := r.db.Model(&EmailVerificationToken{ID: verificationTokenID}).Update("used_at", time.Now()).Error; err != nil {
+		return wrapDBError(err, ErrUpdatingEmailVerificationToken)
+	}
+	return nil
+}
+
+func (r *tokenRepository) InvalidateEmailVerificationTokens(userID int) error {
+	if err := r.db.Model(&EmailVerificationToken{}).Where("user_id = ? AND used_at IS NULL", userID).Update("used_at", time.Now()).Error; err != nil {
+		return wrapDBError(err, ErrUpdatingEmailVerificationToken)
+	}
+	return nil
+}
+
+func (r *tokenRepository) ReplaceRecoveryCodes(userID int, recoveryCodes []RecoveryCode) error {
+	if err := r.db.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+		return wrapDBError(err, ErrCreatingRecoveryCodes)
+	}
+	if len(recoveryCodes) == 0 {
+		return nil
+	}
+	if err := r.db.Create(&recoveryCodes).Error; err != nil {
+		return wrapDBError(err, ErrCreatingRecoveryCodes)
+	}
+	return nil
+}
+
+// UseRecoveryCode only lets one concurrent request use the code
+func (r *tokenRepository) UseRecoveryCode(userID int, codeHash string) (bool, error) {
+	result := r.db.Model(&RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, wrapDBError(result.Error, ErrUpdatingRecoveryCode)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *tokenRepository) DeleteRecoveryCodes(userID int) error {
+	if err := r.db.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+		return wrapDBError(err, ErrUpdatingRecoveryCode)
+	}
+	return nil
+}
+
+/*-------------------------
+//         ROLES
+//-----------------------*/
+
+type roleRepository struct {
+	db *gorm.DB
+}
+
+func (r *roleRepository) Create(role *Role) error {
+	if err := r.db.Create(role).Error; err != nil {
+		return wrapDBError(err, ErrCreatingRole)
+	}
+	return nil
+}
+
+func (r *roleRepository) GetAll() ([]Role, error) {
+	var roles []Role
+	if err := r.db.Preload("Permissions").Order("id").Find(&roles).Error; err != nil {
+		return nil, wrapDBError(err, ErrGettingRoles)
+	}
+	return roles, nil
+}
+
+func (r *roleRepository) GetByName(name RoleName) (Role, error) {
+	role := Role{}
+	if err := r.db.Preload("Permissions").Where("name = ?", name).First(&role).Error; err != nil {
+		return Role{}, mapDBError(err, ErrRoleNotFound, ErrGettingRoles)
+	}
+	return role, nil
+}
+
+func (r *roleRepository) GetByNames(names ...RoleName) ([]Role, error) {
+	var roles []Role
+	if err := r.db.Preload("Permissions").Where("name IN ?", names).Find(&roles).Error; err != nil {
+		return nil, wrapDBError(err, ErrGettingRoles)
+	}
+	return roles, nil
+}
+
+func (r *roleRepository) GetPermissionsByNames(names []string) ([]Permission, error) {
+	var permissions []Permission
+	if err := r.db.Where("name IN ?", names).Find(&permissions).Error; err != nil {
+		return nil, wrapDBError(err, ErrGettingRoles)
+	}
+	return permissions, nil
+}
+
+/*-------------------------
+//         POSTS
+//-----------------------*/
+
+type postRepository struct {
+	db *gorm.DB
+}
+
+// Create also links the post to its tags
+func (r *postRepository) Create(post *UserPost) error {
+	if err := r.db.Create(post).Error; err != nil {
+		return wrapDBError(err, ErrCreatingUserPost)
+	}
+	return nil
+}
+
+func (r *postRepository) GetUserPost(userID, postID int) (UserPost, error) {
+	post := UserPost{}
+	if err := r.db.Preload("Tags").Where("id = ? AND user_id = ? AND deleted = ?", postID, userID, false).First(&post).Error; err != nil {
+		return UserPost{}, mapDBError(err, ErrUserPostNotFound, ErrGettingUserPost)
+	}
+	return post, nil
+}
+
+// ListUserPosts returns the newest posts first
+func (r *postRepository) ListUserPosts(userID int, status string, offset, limit int) (UserPosts, int64, error) {
+	var (
+		posts UserPosts
+		total int64
+	)
+
+	query := r.db.Model(&UserPost{}).Where("user_id = ? AND deleted = ?", userID, false)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	query = query.Session(&gorm.Session{})
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, wrapDBError(err, ErrGettingUserPost)
+	}
+
+	if err := query.Preload("Tags").Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&posts).Error; err != nil {
+		return nil, 0, wrapDBError(err, ErrGettingUserPost)
+	}
+
+	return posts, total, nil
+}
+
+func (r *postRepository) GetPublic(postID int) (UserPost, error) {
+	post := UserPost{}
+	if err := r.publicPostsQuery().Where("user_posts.id = ?", postID).First(&post).Error; err != nil {
+		return UserPost{}, mapDBError(err, ErrUserPostNotFound, ErrGettingUserPost)
+	}
+	return post, nil
+}
+
+func (r *postRepository) ListPublic(feed PostFeed) (UserPosts, error) {
+	var posts UserPosts
+
+	direction, comparison := "ASC", ">"
+	if feed.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	query := r.publicPostsQuery().Order("user_posts.publish_at " + direction + ", user_posts.id " + direction)
+	if feed.After != nil {
+		condition := "((user_posts.publish_at " + comparison + " ?) OR (user_posts.publish_at = ? AND user_posts.id " + comparison + " ?))"
+		query = query.Where(condition, feed.After.Value, feed.After.Value, feed.After.ID)
+	}
+
+	if feed.Tag != "" {
+		taggedPosts := "SELECT post_tags.user_post_id FROM post_tags JOIN tags ON tags.id = post_tags.tag_id WHERE tags.name = ?"
+		query = query.Where("user_posts.id IN ("+taggedPosts+")", feed.Tag)
+	}
+
+	if err := query.Limit(feed.Limit).Find(&posts).Error; err != nil {
+		return nil, wrapDBError(err, ErrGettingUserPost)
+	}
+
+	return posts, nil
+}
+
+// publicPostsQuery only has published posts, leaving out deleted ones and the ones of deleted users
+func (r *postRepository) publicPostsQuery() *gorm.DB {
+	return r.db.Model(&UserPost{}).
+		Joins("JOIN users ON users.id = user_posts.user_id AND users.deleted = ?", false).
+		Where("user_posts.deleted = ? AND user_posts.status = ?", false, PostStatusPublished).
+		Preload("Author").
+		Preload("Tags")
+}
+
+func (r *postRepository) Update(post *UserPost) error {
+	if err := r.db.Model(post).Select("title", "body", "status", "publish_at").Updates(post).Error; err != nil {
+		return wrapDBError(err, ErrUpdatingUserPost)
+	}
+	return nil
+}
+
+func (r *postRepository) ReplaceTags(post *UserPost, tags Tags) error {
+	if err := r.db.Model(post).Association("Tags").Replace(tags); err != nil {
+		return wrapDBError(err, ErrUpdatingUserPost)
+	}
+	post.Tags = tags
+	return nil
+}
+
+func (r *postRepository) SoftDelete(post *UserPost) error {
+	now := time.Now()
+	post.Deleted, post.DeletedAt = true, &now
+	if err := r.db.Model(post).Select("deleted", "deleted_at").Updates(post).Error; err != nil {
+		return wrapDBError(err, ErrDeletingUserPost)
+	}
+	return nil
+}
+
+// LoadCounts does a single query per count for all of the posts
+func (r *postRepository) LoadCounts(posts UserPosts) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	postIDs := []int{}
+	for _, post := range posts {
+		postIDs = append(postIDs, post.ID)
+	}
+
+	var commentCounts []struct {
+		PostID int
+		Count  int
+	}
+	err := r.db.Model(&PostComment{}).Select("post_id, COUNT(*) AS count").
+		Where("post_id IN ? AND deleted = ?", postIDs, false).Group("post_id").Scan(&commentCounts).Error
+	if err != nil {
+		return wrapDBError(err, ErrGettingPostComment)
+	}
+
+	var reactionCounts []struct {
+		PostID int
+		Kind   string
+		Count  int
+	}
+	err = r.db.Model(&PostReaction{}).Select("post_id, kind, COUNT(*) AS count").
+		Where("post_id IN ?", postIDs).Group("post_id, kind").Scan(&reactionCounts).Error
+	if err != nil {
+		return wrapDBError(err, ErrGettingPostReactions)
+	}
+
+	commentCountsByPost := map[int]int{}
+	for _, commentCount := range commentCounts {
+		commentCountsByPost[commentCount.PostID] = commentCount.Count
+	}
+	reactionCountsByPost := map[int]map[string]int{}
+	for _, reactionCount := range reactionCounts {
+		if reactionCountsByPost[reactionCount.PostID] == nil {
+			reactionCountsByPost[reactionCount.PostID] = map[string]int{}
+		}
+		reactionCountsByPost[reactionCount.PostID][reactionCount.Kind] = reactionCount.Count
+	}
+
+	setPostCounts(posts, commentCountsByPost, reactionCountsByPost)
+	return nil
+}
+
+func (r *postRepository) FindOrCreateTags(names []string) (Tags, error) {
+	tags := Tags{}
+	if len(names) == 0 {
+		return tags, nil
+	}
+
+	// Other posts may be creating the same tags right now, so conflicts are ignored
+	for _, name := range names {
+		tags = append(tags, Tag{Name: name})
+	}
+	if err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&tags).Error; err != nil {
+		return nil, wrapDBError(err, ErrCreatingTags)
+	}
+
+	tags = Tags{}
+	if err := r.db.Where("name IN ?", names).Find(&tags).Error; err != nil {
+		return nil, wrapDBError(err, ErrGettingTags)
+	}
+	return tags, nil
+}
+
+// SearchTags returns the most used tags starting with the prefix. Tags that aren't on any visible post are left out.
+func (r *postRepository) SearchTags(prefix string, limit int) ([]ResponseTag, error) {
+	tags := []ResponseTag{}
+	err := r.db.Table("tags").
+		Select("tags.name AS name, COUNT(user_posts.id) AS post_count").
+		Joins("JOIN post_tags ON post_tags.tag_id = tags.id").
+		Joins("JOIN user_posts ON user_posts.id = post_tags.user_post_id AND user_posts.deleted = ? AND user_posts.status = ?", false, PostStatusPublished).
+		Joins("JOIN users ON users.id = user_posts.user_id AND users.deleted = ?", false).
+		Where("tags.name LIKE ? ESCAPE '!'", EscapeLikePrefix(prefix)).
+		Group("tags.name").
+		Order("post_count DESC, tags.name").
+		Limit(limit).
+		Scan(&tags).Error
+	if err != nil {
+		return nil, wrapDBError(err, ErrGettingTags)
+	}
+	return tags, nil
+}
+
+// React relies on the unique index for concurrent requests
+func (r *postRepository) React(reaction *PostReaction) error {
+	if err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(reaction).Error; err != nil {
+		return wrapDBError(err, ErrCreatingPostReaction)
+	}
+	return nil
+}
+
+func (r *postRepository) Unreact(postID, userID int, kind string) error {
+	if err := r.db.Where("post_id = ? AND user_id = ? AND kind = ?", postID, userID, kind).Delete(&PostReaction{}).Error; err != nil {
+		return wrapDBError(err, ErrDeletingPostReaction)
+	}
+	return nil
+}
+
+/*-------------------------
+//        COMMENTS
+//-----------------------*/
+
+type commentRepository struct {
+	db *gorm.DB
+}
+
+func (r *commentRepository) Create(comment *PostComment) error {
+	if err := r.db.Create(comment).Error; err != nil {
+		return wrapDBError(err, ErrCreatingPostComment)
+	}
+	return nil
+}
+
+func (r *commentRepository) Get(postID, commentID int) (PostComment, error) {
+	comment := PostComment{}
+	if err := r.db.Preload("Author").Where("id = ? AND post_id = ? AND deleted = ?", commentID, postID, false).First(&comment).Error; err != nil {
+		return PostComment{}, mapDBError(err, ErrPostCommentNotFound, ErrGettingPostComment)
+	}
+	return comment, nil
+}
+
+// ListTopLevel returns the oldest comments first, with all of their replies
+func (r *commentRepository) ListTopLevel(postID int, offset, limit int) (PostComments, int64, error) {
+	var (
+		comments PostComments
+		total    int64
+	)
+
+	query := r.db.Model(&PostComment{}).Where("post_id = ? AND parent_id IS NULL AND deleted = ?", postID, false).Session(&gorm.Session{})
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, wrapDBError(err, ErrGettingPostComment)
+	}
+
+	err := query.Preload("Author").
+		Preload("Replies", func(db *gorm.DB) *gorm.DB { return db.Where("deleted = ?", false).Order("created_at, id") }).
+		Preload("Replies.Author").
+		Order("created_at, id").Offset(offset).Limit(limit).Find(&comments).Error
+	if err != nil {
+		return nil, 0, wrapDBError(err, ErrGettingPostComment)
+	}
+
+	return comments, total, nil
+}
+
+func (r *commentRepository) UpdateBody(comment *PostComment) error {
+	if err := r.db.Model(comment).Select("body").Updates(comment).Error; err != nil {
+		return wrapDBError(err, ErrUpdatingPostComment)
+	}
+	return nil
+}
+
+func (r *commentRepository) SoftDeleteWithReplies(commentID int) error {
+	updates := map[string]interface{}{"deleted": true, "deleted_at": time.Now()}
+	err := r.db.Model(&PostComment{}).Where("(id = ? OR parent_id = ?) AND deleted = ?", commentID, commentID, false).Updates(updates).Error
+	if err != nil {
+		return wrapDBError(err, ErrDeletingPostComment)
+	}
+	return nil
+}
+
+/*-------------------------
+//      DATA EXPORTS
+//-----------------------*/
+
+type dataExportRepository struct {
+	db *gorm.DB
+}
+
+func (r *dataExportRepository) Create(export *DataExport) error {
+	if err := r.db.Create(export).Error; err != nil {
+		return wrapDBError(err, ErrCreatingDataExport)
+	}
+	return nil
+}
+
+func (r *dataExportRepository) Get(userID, exportID int) (DataExport, error) {
+	export := DataExport{}
+	if err := r.db.Where("id = ? AND user_id = ?", exportID, userID).First(&export).Error; err != nil {
+		return DataExport{}, mapDBError(err, ErrDataExportNotFound, ErrGettingDataExport)
+	}
+	return export, nil
+}
+
+func (r *dataExportRepository) GetPending(userID int, createdAfter time.Time) (DataExport, error) {
+	export := DataExport{}
+	err := r.db.Where("user_id = ? AND status = ? AND created_at > ?", userID, DataExportPending, createdAfter).First(&export).Error
+	if err != nil {
+		return DataExport{}, mapDBError(err, ErrDataExportNotFound, ErrGettingDataExport)
+	}
+	return export, nil
+}
+
+func (r *dataExportRepository) UpdateFields(exportID int, fields map[string]interface{}) error {
+	if err := r.db.Model(&DataExport{}).Where("id = ?", exportID).Updates(fields).Error; err != nil {
+		return wrapDBError(err, ErrUpdatingDataExport)
+	}
+	return nil
+}
+
+func (r *dataExportRepository) ListExpired(expiredBefore time.Time) ([]DataExport, error) {
+	var exports []DataExport
+	if err := r.db.Where("expires_at < ? AND file_path <> ''", expiredBefore).Order("id").Find(&exports).Error; err != nil {
+		return nil, wrapDBError(err, ErrGettingDataExport)
+	}
+	return exports, nil
+}
+
+// CollectUserData gets the user with its details, posts and roles, and everything else that belongs to it
+func (r *dataExportRepository) CollectUserData(export DataExport) (UserExportData, error) {
+	data := UserExportData{}
+	if err := r.db.Preload("Details").Preload("Posts").Preload("Roles").Where("id = ?", export.UserID).First(&data.User).Error; err != nil {
+		return UserExportData{}, Wrap(err.Error(), ErrGettingUser)
+	}
+	userID := data.User.ID
+
+	if err := r.db.Where("user_id = ?", userID).Order("id").Find(&data.RefreshTokens).Error; err != nil {
+		return UserExportData{}, Wrap(err.Error(), ErrGettingRefreshToken)
+	}
+
+	if err := r.db.Where("user_id = ?", userID).Order("id").Find(&data.Comments).Error; err != nil {
+		return UserExportData{}, Wrap(err.Error(), ErrGettingPostComment)
+	}
+
+	if err := r.db.Where("user_id = ?", userID).Order("id").Find(&data.Reactions).Error; err != nil {
+		return UserExportData{}, Wrap(err.Error(), ErrGettingPostReactions)
+	}
+
+	if err := r.db.Where("user_id = ?", userID).Find(&data.RecoveryCodes).Error; err != nil {
+		return UserExportData{}, Wrap(err.Error(), ErrGettingRecoveryCode)
+	}
+
+	if err := r.db.Where("throttle_key = ?", UserThrottleKey(userID)).Find(&data.LoginThrottles).Error; err != nil {
+		return UserExportData{}, Wrap(err.Error(), ErrGettingLoginThrottle)
+	}
+
+	if err := r.db.Where("user_id = ?", userID).Order("id").Find(&data.PasswordResetTokens).Error; err != nil {
+		return UserExportData{}, Wrap(err.Error(), ErrGettingPasswordResetToken)
+	}
+
+	if err := r.db.Where("user_id = ?", userID).Order("id").Find(&data.EmailVerificationTokens).Error; err != nil {
+		return UserExportData{}, Wrap(err.Error(), ErrGettingEmailVerificationToken)
+	}
+
+	// Data exports, except for this one
+	if err := r.db.Where("user_id = ? AND id <> ?", userID, export.ID).Order("id").Find(&data.PreviousDataExports).Error; err != nil {
+		return UserExportData{}, Wrap(err.Error(), ErrGettingDataExport)
+	}
+
+	return data, nil
+}
+
+/*-------------------------
+//      UNIT OF WORK
+//-----------------------*/
+
+type gormUnitOfWork struct {
+	db      *gorm.DB
+	options TxOptions
+
+	// Set on the unit of work of the repositories inside a transaction
+	inTx bool
+}
+
+func (u *gormUnitOfWork) Do(fn func(repos Repositories) error) error {
+	return u.DoWithIsolation(u.options.Isolation, fn)
+}
+
+func (u *gormUnitOfWork) DoWithIsolation(isolation sql.IsolationLevel, fn func(repos Repositories) error) error {
+
+	// Nested, it's part of the outer transaction. Retrying is up to that one
+	if u.inTx {
+		return fn(u.txRepositories(u.db))
+	}
+
+	return retryTx(u.options.MaxRetries, func() error {
+		return u.run(isolation, fn)
+	})
+}
+
+// run is one attempt of the transaction
+func (u *gormUnitOfWork) run(isolation sql.IsolationLevel, fn func(repos Repositories) error) (err error) {
+	tx := u.db.Begin(&sql.TxOptions{Isolation: isolation})
+	if tx.Error != nil {
+		return wrapDBError(tx.Error, ErrInDBTransaction)
+	}
+
+	// A panic is rolled back and returned as an error, gin would only log it otherwise
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			err = Wrap(fmt.Sprintf("unitOfWork: panic: %v", r), ErrInDBTransaction)
+		}
+	}()
+
+	if err := fn(u.txRepositories(tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return wrapDBError(err, ErrInDBTransaction)
+	}
+	return nil
+}
+
+func (u *gormUnitOfWork) txRepositories(tx *gorm.DB) Repositories {
+	return newGormRepositories(tx, &gormUnitOfWork{db: tx, options: u.options, inTx: true})
+}
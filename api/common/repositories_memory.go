@@ -0,0 +1,1686 @@
+package common
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// NewMemoryRepositories keeps everything in maps, for tests. It starts with the default roles and permissions.
+// The rows are stored without their associations, which get loaded on the way out, like GORM's preloads.
+func NewMemoryRepositories() Repositories {
+	return newMemoryStore().repositories(false)
+}
+
+func (s *memoryStore) repositories(inTx bool) Repositories {
+	return Repositories{
+		Users:      &memoryUserRepository{s},
+		Tokens:     &memoryTokenRepository{s},
+		Roles:      &memoryRoleRepository{s},
+		Posts:      &memoryPostRepository{s},
+		Comments:   &memoryCommentRepository{s},
+		Exports:    &memoryDataExportRepository{s},
+		UnitOfWork: &memoryUnitOfWork{s, inTx},
+	}
+}
+
+type memoryStore struct {
+	mu      sync.Mutex
+	lastIDs map[string]int
+
+	// Transactions run one at a time
+	txMu sync.Mutex
+
+	users           map[int]User
+	userDetails     map[int]UserDetail
+	userRoles       map[int][]int
+	roles           map[int]Role
+	permissions     map[int]Permission
+	rolePermissions map[int][]int
+
+	refreshTokens           map[int]RefreshToken
+	passwordResetTokens     map[int]PasswordResetToken
+	emailVerificationTokens map[int]EmailVerificationToken
+	recoveryCodes           map[int]RecoveryCode
+
+	posts     map[int]UserPost
+	postTags  map[int][]int
+	tags      map[int]Tag
+	reactions map[int]PostReaction
+	comments  map[int]PostComment
+	exports   map[int]DataExport
+
+	// Outside of the repositories, like on the DB. Transactions don't roll them back
+	revokedTokens  map[string]RevokedToken
+	loginThrottles map[string]LoginThrottle
+}
+
+func newMemoryStore() *memoryStore {
+	s := &memoryStore{
+		lastIDs:                 map[string]int{},
+		users:                   map[int]User{},
+		userDetails:             map[int]UserDetail{},
+		userRoles:               map[int][]int{},
+		roles:                   map[int]Role{},
+		permissions:             map[int]Permission{},
+		rolePermissions:         map[int][]int{},
+		refreshTokens:           map[int]RefreshToken{},
+		passwordResetTokens:     map[int]PasswordResetToken{},
+		emailVerificationTokens: map[int]EmailVerificationToken{},
+		recoveryCodes:           map[int]RecoveryCode{},
+		posts:                   map[int]UserPost{},
+		postTags:                map[int][]int{},
+		tags:                    map[int]Tag{},
+		reactions:               map[int]PostReaction{},
+		comments:                map[int]PostComment{},
+		exports:                 map[int]DataExport{},
+		revokedTokens:           map[string]RevokedToken{},
+		loginThrottles:          map[string]LoginThrottle{},
+	}
+
+	// Same as the roles migration
+	permissionIDs := map[string]int{}
+	for _, name := range AllPermissions {
+		permissionIDs[name] = s.nextID("permissions")
+		s.permissions[permissionIDs[name]] = Permission{ID: permissionIDs[name], Name: name}
+	}
+
+	roleNames := []RoleName{}
+	for name := range defaultRoles {
+		roleNames = append(roleNames, name)
+	}
+	sort.Slice(roleNames, func(i, j int) bool { return roleNames[i] < roleNames[j] })
+
+	for _, name := range roleNames {
+		role := Role{ID: s.nextID("roles"), Name: name, CreatedAt: time.Now()}
+		s.roles[role.ID] = role
+		for _, permissionName := range defaultRoles[name] {
+			s.rolePermissions[role.ID] = append(s.rolePermissions[role.ID], permissionIDs[permissionName])
+		}
+	}
+
+	return s
+}
+
+func (s *memoryStore) nextID(table string) int {
+	s.lastIDs[table]++
+	return s.lastIDs[table]
+}
+
+// sortedIDs is how rows are iterated, so the results are the same on every run
+func sortedIDs[T any](rows map[int]T) []int {
+	ids := make([]int, 0, len(rows))
+	for id := range rows {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// loadUser sets the details and the roles with their permissions. The posts that aren't deleted, only if withPosts.
+func (s *memoryStore) loadUser(user User, withPosts bool) User {
+	user.Details = UserDetail{}
+	for _, id := range sortedIDs(s.userDetails) {
+		if s.userDetails[id].UserID == user.ID {
+			user.Details = s.userDetails[id]
+		}
+	}
+
+	user.Roles = []Role{}
+	for _, roleID := range s.userRoles[user.ID] {
+		user.Roles = append(user.Roles, s.loadRole(s.roles[roleID]))
+	}
+
+	user.Posts = nil
+	if withPosts {
+		user.Posts = UserPosts{}
+		for _, id := range sortedIDs(s.posts) {
+			if post := s.posts[id]; post.UserID == user.ID && !post.Deleted {
+				user.Posts = append(user.Posts, post)
+			}
+		}
+	}
+
+	return user
+}
+
+func (s *memoryStore) loadRole(role Role) Role {
+	role.Permissions = []Permission{}
+	for _, permissionID := range s.rolePermissions[role.ID] {
+		role.Permissions = append(role.Permissions, s.permissions[permissionID])
+	}
+	return role
+}
+
+func (s *memoryStore) loadPost(post UserPost, withAuthor bool) UserPost {
+	post.Tags = Tags{}
+	for _, tagID := range s.postTags[post.ID] {
+		post.Tags = append(post.Tags, s.tags[tagID])
+	}
+
+	post.Author = nil
+	if author, ok := s.users[post.UserID]; ok && withAuthor {
+		post.Author = &author
+	}
+	return post
+}
+
+func (s *memoryStore) loadComment(comment PostComment) PostComment {
+	comment.Author = nil
+	if author, ok := s.users[comment.UserID]; ok {
+		comment.Author = &author
+	}
+	return comment
+}
+
+func (s *memoryStore) isUsernameOrEmailTaken(userID int, username, email string) bool {
+	for _, user := range s.users {
+		if user.ID != userID && (user.Username == username || user.Email == email) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memoryStore) isAdmin(userID int) bool {
+	for _, roleID := range s.userRoles[userID] {
+		if s.roles[roleID].Name == AdminRole {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memoryStore) isPublic(post UserPost) bool {
+	author, ok := s.users[post.UserID]
+	return ok && !author.Deleted && !post.Deleted && post.Status == PostStatusPublished
+}
+
+/*-------------------------
+//         USERS
+//-----------------------*/
+
+type memoryUserRepository struct {
+	*memoryStore
+}
+
+// Create also creates the details, and links the roles
+func (r *memoryUserRepository) Create(user *User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.isUsernameOrEmailTaken(0, user.Username, user.Email) {
+		return Wrap(gorm.ErrDuplicatedKey.Error(), ErrUsernameOrEmailAlreadyInUse)
+	}
+
+	now := time.Now()
+	user.ID, user.CreatedAt, user.UpdatedAt = r.nextID("users"), now, now
+	r.users[user.ID] = bareUser(*user)
+
+	if user.Details != (UserDetail{}) {
+		user.Details.ID, user.Details.UserID = r.nextID("user_details"), user.ID
+		user.Details.CreatedAt, user.Details.UpdatedAt = now, now
+		r.userDetails[user.Details.ID] = user.Details
+	}
+
+	for _, role := range user.Roles {
+		r.userRoles[user.ID] = append(r.userRoles[user.ID], role.ID)
+	}
+	return nil
+}
+
+func (r *memoryUserRepository) Get(userID int) (User, error) {
+	return r.first(false, func(user User) bool { return user.ID == userID })
+}
+
+func (r *memoryUserRepository) GetActive(userID int) (User, error) {
+	return r.first(false, func(user User) bool { return user.ID == userID && !user.Deleted })
+}
+
+func (r *memoryUserRepository) GetActiveByUsernameOrEmail(username, email string) (User, error) {
+	return r.first(false, func(user User) bool { return (user.Username == username || user.Email == email) && !user.Deleted })
+}
+
+func (r *memoryUserRepository) GetActiveByID(userID int) (User, error) {
+	return r.first(false, func(user User) bool { return user.ID == userID && !user.Deleted })
+}
+
+func (r *memoryUserRepository) ExistsByUsernameOrEmailExcept(userID int, username, email string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		if user.ID != userID && ((username != "" && user.Username == username) || (email != "" && user.Email == email)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryUserRepository) GetWithDetails(userID int) (User, error) {
+	return r.first(true, func(user User) bool { return user.ID == userID })
+}
+
+func (r *memoryUserRepository) GetWithPermissions(userID int) (User, error) {
+	return r.first(false, func(user User) bool { return user.ID == userID })
+}
+
+func (r *memoryUserRepository) first(withPosts bool, matches func(user User) bool) (User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range sortedIDs(r.users) {
+		if matches(r.users[id]) {
+			return r.loadUser(r.users[id], withPosts), nil
+		}
+	}
+	return User{}, mapDBError(gorm.ErrRecordNotFound, ErrUserNotFound, ErrGettingUser)
+}
+
+func (r *memoryUserRepository) Search(search UserSearch) (Users, *int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	users := Users{}
+	for _, id := range sortedIDs(r.users) {
+		user := r.loadUser(r.users[id], false)
+		if !containsFold(user.Username, search.Username) || !r.matchesFilters(user, search.Filters) {
+			continue
+		}
+		if !HasUserFilter(search.Filters, "deleted") && user.Deleted {
+			continue
+		}
+		users = append(users, user)
+	}
+
+	var total *int64
+	if search.IncludeTotal {
+		total = new(int64)
+		*total = int64(len(users))
+	}
+
+	// Sort field, then id
+	compare := func(user User, value interface{}, id int) int {
+		if result := compareValues(r.userColumn(user, search.SortField), value); result != 0 {
+			return result
+		}
+		return compareValues(user.ID, id)
+	}
+	sort.SliceStable(users, func(i, j int) bool {
+		result := compare(users[i], r.userColumn(users[j], search.SortField), users[j].ID)
+		return (result < 0) != search.Descending && result != 0
+	})
+
+	if search.After != nil {
+		after := Users{}
+		for _, user := range users {
+			result := compare(user, search.After.Value, search.After.ID)
+			if (result > 0 && !search.Descending) || (result < 0 && search.Descending) {
+				after = append(after, user)
+			}
+		}
+		users = after
+	} else {
+		users = users[minInt(search.Offset, len(users)):]
+	}
+
+	return users[:minInt(search.Limit, len(users))], total, nil
+}
+
+func (r *memoryUserRepository) matchesFilters(user User, filters []UserFilter) bool {
+	for _, filter := range filters {
+		value := r.userColumn(user, filter.Field)
+		result := compareValues(value, filter.Value)
+
+		matches := false
+		switch filter.Operator {
+		case "eq":
+			matches = result == 0
+		case "ne":
+			matches = result != 0
+		case "like":
+			matches = containsFold(fmt.Sprint(value), fmt.Sprint(filter.Value))
+		case "gt":
+			matches = result > 0
+		case "gte":
+			matches = result >= 0
+		case "lt":
+			matches = result < 0
+		case "lte":
+			matches = result <= 0
+		}
+		if !matches {
+			return false
+		}
+	}
+	return true
+}
+
+// userColumn returns the value of the fields that can be filtered or sorted by. The user needs its details loaded.
+func (r *memoryUserRepository) userColumn(user User, column string) interface{} {
+	switch column {
+	case "id":
+		return user.ID
+	case "username":
+		return user.Username
+	case "email":
+		return user.Email
+	case "first_name":
+		return user.Details.FirstName
+	case "last_name":
+		return user.Details.LastName
+	case "is_admin":
+		return r.isAdmin(user.ID)
+	case "deleted":
+		return user.Deleted
+	case "created_at":
+		return user.CreatedAt
+	case "updated_at":
+		return user.UpdatedAt
+	}
+	return nil
+}
+
+func (r *memoryUserRepository) Update(user *User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.isUsernameOrEmailTaken(user.ID, user.Username, user.Email) {
+		return Wrap(gorm.ErrDuplicatedKey.Error(), ErrUsernameOrEmailAlreadyInUse)
+	}
+
+	user.UpdatedAt = time.Now()
+	r.users[user.ID] = bareUser(*user)
+	return nil
+}
+
+func (r *memoryUserRepository) UpdateDetails(details *UserDetail) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if details.ID == 0 {
+		details.ID, details.CreatedAt = r.nextID("user_details"), now
+	}
+	details.UpdatedAt = now
+	r.userDetails[details.ID] = *details
+	return nil
+}
+
+func (r *memoryUserRepository) UpdateFields(user *User, fields map[string]interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.users[user.ID]
+	if !ok {
+		return nil
+	}
+	if err := setColumns(&stored, fields); err != nil {
+		return Wrap(err.Error(), ErrUpdatingUser)
+	}
+	stored.UpdatedAt = time.Now()
+	r.users[user.ID] = stored
+
+	setColumns(user, fields)
+	user.UpdatedAt = stored.UpdatedAt
+	return nil
+}
+
+func (r *memoryUserRepository) UpdatePassword(userID int, hashedPassword string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if user, ok := r.users[userID]; ok {
+		user.Password, user.UpdatedAt = hashedPassword, time.Now()
+		r.users[userID] = user
+	}
+	return nil
+}
+
+func (r *memoryUserRepository) UseTOTPStep(userID int, step int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok || user.TOTPLastUsedStep >= step {
+		return false, nil
+	}
+	user.TOTPLastUsedStep = step
+	r.users[userID] = user
+	return true, nil
+}
+
+func (r *memoryUserRepository) AddRoles(user *User, roles []Role) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, role := range roles {
+		if !containsInt(r.userRoles[user.ID], role.ID) {
+			r.userRoles[user.ID] = append(r.userRoles[user.ID], role.ID)
+		}
+	}
+	user.Roles = r.loadUser(*user, false).Roles
+	return nil
+}
+
+func (r *memoryUserRepository) RemoveRoles(user *User, roles []Role) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, role := range roles {
+		r.userRoles[user.ID] = removeInt(r.userRoles[user.ID], role.ID)
+	}
+	user.Roles = r.loadUser(*user, false).Roles
+	return nil
+}
+
+func (r *memoryUserRepository) SoftDelete(user *User) error {
+	now := time.Now()
+	user.Deleted, user.DeletedAt = true, &now
+	return r.setDeleted(user)
+}
+
+func (r *memoryUserRepository) Restore(user *User) error {
+	user.Deleted, user.DeletedAt = false, nil
+	return r.setDeleted(user)
+}
+
+func (r *memoryUserRepository) setDeleted(user *User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if stored, ok := r.users[user.ID]; ok {
+		stored.Deleted, stored.DeletedAt = user.Deleted, user.DeletedAt
+		r.users[user.ID] = stored
+	}
+	return nil
+}
+
+func (r *memoryUserRepository) StartMissingGracePeriods() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for id, user := range r.users {
+		if user.Deleted && user.DeletedAt == nil {
+			user.DeletedAt = &now
+			r.users[id] = user
+		}
+	}
+	return nil
+}
+
+func (r *memoryUserRepository) ListDeletedBefore(deletedBefore time.Time) ([]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	userIDs := []int{}
+	for _, id := range sortedIDs(r.users) {
+		if user := r.users[id]; user.Deleted && user.DeletedAt != nil && user.DeletedAt.Before(deletedBefore) {
+			userIDs = append(userIDs, id)
+		}
+	}
+	return userIDs, nil
+}
+
+// HardDelete removes the same rows and files as the GORM one, except for the login throttles, which aren't here
+func (r *memoryUserRepository) HardDelete(userID int) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	filePaths := []string{}
+	for _, export := range userRows(r.exports, userID, func(row DataExport) int { return row.UserID }) {
+		if export.FilePath != "" {
+			filePaths = append(filePaths, export.FilePath)
+		}
+	}
+
+	var postIDs, commentIDs []int
+	for id, post := range r.posts {
+		if post.UserID == userID {
+			postIDs = append(postIDs, id)
+		}
+	}
+	for id, comment := range r.comments {
+		if comment.UserID == userID {
+			commentIDs = append(commentIDs, id)
+		}
+	}
+
+	for id, comment := range r.comments {
+		if comment.UserID == userID || containsInt(postIDs, comment.PostID) || (comment.ParentID != nil && containsInt(commentIDs, *comment.ParentID)) {
+			delete(r.comments, id)
+		}
+	}
+	for id, reaction := range r.reactions {
+		if reaction.UserID == userID || containsInt(postIDs, reaction.PostID) {
+			delete(r.reactions, id)
+		}
+	}
+	for _, postID := range postIDs {
+		delete(r.postTags, postID)
+		delete(r.posts, postID)
+	}
+
+	deleteUserRows(r.userDetails, userID, func(row UserDetail) int { return row.UserID })
+	deleteUserRows(r.refreshTokens, userID, func(row RefreshToken) int { return row.UserID })
+	deleteUserRows(r.passwordResetTokens, userID, func(row PasswordResetToken) int { return row.UserID })
+	deleteUserRows(r.emailVerificationTokens, userID, func(row EmailVerificationToken) int { return row.UserID })
+	deleteUserRows(r.recoveryCodes, userID, func(row RecoveryCode) int { return row.UserID })
+	deleteUserRows(r.exports, userID, func(row DataExport) int { return row.UserID })
+
+	delete(r.userRoles, userID)
+	delete(r.users, userID)
+	return filePaths, nil
+}
+
+/*-------------------------
+//         TOKENS
+//-----------------------*/
+
+type memoryTokenRepository struct {
+	*memoryStore
+}
+
+func (r *memoryTokenRepository) CreateRefreshToken(refreshToken *RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	refreshToken.ID, refreshToken.CreatedAt = r.nextID("refresh_tokens"), time.Now()
+	r.refreshTokens[refreshToken.ID] = *refreshToken
+	return nil
+}
+
+func (r *memoryTokenRepository) GetRefreshToken(tokenHash string) (RefreshToken, error) {
+	return r.GetUserRefreshToken(tokenHash, 0)
+}
+
+// GetUserRefreshToken doesn't check the user if userID is 0
+func (r *memoryTokenRepository) GetUserRefreshToken(tokenHash string, userID int) (RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range sortedIDs(r.refreshTokens) {
+		if token := r.refreshTokens[id]; token.TokenHash == tokenHash && (userID == 0 || token.UserID == userID) {
+			return token, nil
+		}
+	}
+	return RefreshToken{}, mapDBError(gorm.ErrRecordNotFound, ErrInvalidRefreshToken, ErrGettingRefreshToken)
+}
+
+func (r *memoryTokenRepository) UseRefreshToken(refreshTokenID int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.refreshTokens[refreshTokenID]
+	if !ok || token.UsedAt != nil || token.RevokedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	r.refreshTokens[refreshTokenID] = token
+	return true, nil
+}
+
+func (r *memoryTokenRepository) RevokeRefreshTokenFamily(familyID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for id, token := range r.refreshTokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+			r.refreshTokens[id] = token
+		}
+	}
+	return nil
+}
+
+func (r *memoryTokenRepository) CreatePasswordResetToken(resetToken *PasswordResetToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	resetToken.ID, resetToken.CreatedAt = r.nextID("password_reset_tokens"), time.Now()
+	r.passwordResetTokens[resetToken.ID] = *resetToken
+	return nil
+}
+
+func (r *memoryTokenRepository) GetPasswordResetToken(tokenHash string) (PasswordResetToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range sortedIDs(r.passwordResetTokens) {
+		if r.passwordResetTokens[id].TokenHash == tokenHash {
+			return r.passwordResetTokens[id], nil
+		}
+	}
+	return PasswordResetToken{}, mapDBError(gorm.ErrRecordNotFound, ErrInvalidPasswordResetToken, ErrGettingPasswordResetToken)
+}
+
+func (r *memoryTokenRepository) UsePasswordResetToken(resetTokenID int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.passwordResetTokens[resetTokenID]
+	if !ok || token.UsedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	r.passwordResetTokens[resetTokenID] = token
+	return true, nil
+}
+
+func (r *memoryTokenRepository) InvalidatePasswordResetTokens(userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for id, token := range r.passwordResetTokens {
+		if token.UserID == userID && token.UsedAt == nil {
+			token.UsedAt = &now
+			r.passwordResetTokens[id] = token
+		}
+	}
+	return nil
+}
+
+func (r *memoryTokenRepository) CreateEmailVerificationToken(verificationToken *EmailVerificationToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	verificationToken.ID, verificationToken.CreatedAt = r.nextID("email_verification_tokens"), time.Now()
+	r.emailVerificationTokens[verificationToken.ID] = *verificationToken
+	return nil
+}
+
+func (r *memoryTokenRepository) GetEmailVerificationToken(tokenHash string) (EmailVerificationToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range sortedIDs(r.emailVerificationTokens) {
+		if r.emailVerificationTokens[id].TokenHash == tokenHash {
+			return r.emailVerificationTokens[id], nil
+		}
+	}
+	return EmailVerificationToken{}, mapDBError(gorm.ErrRecordNotFound, ErrInvalidEmailVerificationToken, ErrGettingEmailVerificationToken)
+}
+
+func (r *memoryTokenRepository) UseEmailVerificationToken(verificationTokenID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if token, ok := r.emailVerificationTokens[verificationTokenID]; ok {
+		now := time.Now()
+		token.UsedAt = &now
+		r.emailVerificationTokens[verificationTokenID] = token
+	}
+	return nil
+}
+
+func (r *memoryTokenRepository) InvalidateEmailVerificationTokens(userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for id, token := range r.emailVerificationTokens {
+		if token.UserID == userID && token.UsedAt == nil {
+			token.UsedAt = &now
+			r.emailVerificationTokens[id] = token
+		}
+	}
+	return nil
+}
+
+func (r *memoryTokenRepository) ReplaceRecoveryCodes(userID int, recoveryCodes []RecoveryCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleteUserRows(r.recoveryCodes, userID, func(row RecoveryCode) int { return row.UserID })
+	for i := range recoveryCodes {
+		recoveryCodes[i].ID, recoveryCodes[i].CreatedAt = r.nextID("recovery_codes"), time.Now()
+		r.recoveryCodes[recoveryCodes[i].ID] = recoveryCodes[i]
+	}
+	return nil
+}
+
+func (r *memoryTokenRepository) UseRecoveryCode(userID int, codeHash string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range sortedIDs(r.recoveryCodes) {
+		if code := r.recoveryCodes[id]; code.UserID == userID && code.CodeHash == codeHash && code.UsedAt == nil {
+			now := time.Now()
+			code.UsedAt = &now
+			r.recoveryCodes[id] = code
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryTokenRepository) DeleteRecoveryCodes(userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleteUserRows(r.recoveryCodes, userID, func(row RecoveryCode) int { return row.UserID })
+	return nil
+}
+
+/*-------------------------
+//         ROLES
+//-----------------------*/
+
+type memoryRoleRepository struct {
+	*memoryStore
+}
+
+// Create also links the permissions
+func (r *memoryRoleRepository) Create(role *Role) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.roles {
+		if existing.Name == role.Name {
+			return Wrap(gorm.ErrDuplicatedKey.Error(), ErrCreatingRole)
+		}
+	}
+
+	role.ID, role.CreatedAt = r.nextID("roles"), time.Now()
+	stored := *role
+	stored.Permissions = nil
+	r.roles[role.ID] = stored
+
+	for _, permission := range role.Permissions {
+		r.rolePermissions[role.ID] = append(r.rolePermissions[role.ID], permission.ID)
+	}
+	return nil
+}
+
+func (r *memoryRoleRepository) GetAll() ([]Role, error) {
+	return r.find(func(role Role) bool { return true }), nil
+}
+
+func (r *memoryRoleRepository) GetByName(name RoleName) (Role, error) {
+	roles := r.find(func(role Role) bool { return role.Name == name })
+	if len(roles) == 0 {
+		return Role{}, mapDBError(gorm.ErrRecordNotFound, ErrRoleNotFound, ErrGettingRoles)
+	}
+	return roles[0], nil
+}
+
+func (r *memoryRoleRepository) GetByNames(names ...RoleName) ([]Role, error) {
+	return r.find(func(role Role) bool {
+		for _, name := range names {
+			if role.Name == name {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func (r *memoryRoleRepository) GetPermissionsByNames(names []string) ([]Permission, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	permissions := []Permission{}
+	for _, id := range sortedIDs(r.permissions) {
+		if containsString(names, r.permissions[id].Name) {
+			permissions = append(permissions, r.permissions[id])
+		}
+	}
+	return permissions, nil
+}
+
+func (r *memoryRoleRepository) find(matches func(role Role) bool) []Role {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	roles := []Role{}
+	for _, id := range sortedIDs(r.roles) {
+		if matches(r.roles[id]) {
+			roles = append(roles, r.loadRole(r.roles[id]))
+		}
+	}
+	return roles
+}
+
+/*-------------------------
+//         POSTS
+//-----------------------*/
+
+type memoryPostRepository struct {
+	*memoryStore
+}
+
+// Create also links the post to its tags
+func (r *memoryPostRepository) Create(post *UserPost) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if post.Status == "" {
+		post.Status = PostStatusPublished
+	}
+
+	now := time.Now()
+	post.ID, post.CreatedAt, post.UpdatedAt = r.nextID("user_posts"), now, now
+	r.posts[post.ID] = barePost(*post)
+
+	r.postTags[post.ID] = nil
+	for _, tag := range post.Tags {
+		r.postTags[post.ID] = append(r.postTags[post.ID], tag.ID)
+	}
+	return nil
+}
+
+func (r *memoryPostRepository) GetUserPost(userID, postID int) (UserPost, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	post, ok := r.posts[postID]
+	if !ok || post.UserID != userID || post.Deleted {
+		return UserPost{}, mapDBError(gorm.ErrRecordNotFound, ErrUserPostNotFound, ErrGettingUserPost)
+	}
+	return r.loadPost(post, false), nil
+}
+
+func (r *memoryPostRepository) ListUserPosts(userID int, status string, offset, limit int) (UserPosts, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	posts := UserPosts{}
+	for _, id := range sortedIDs(r.posts) {
+		post := r.posts[id]
+		if post.UserID == userID && !post.Deleted && (status == "" || post.Status == status) {
+			posts = append(posts, r.loadPost(post, false))
+		}
+	}
+
+	// Newest first
+	sortPosts(posts, true)
+	total := int64(len(posts))
+	posts = posts[minInt(offset, len(posts)):]
+	return posts[:minInt(limit, len(posts))], total, nil
+}
+
+func (r *memoryPostRepository) GetPublic(postID int) (UserPost, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	post, ok := r.posts[postID]
+	if !ok || !r.isPublic(post) {
+		return UserPost{}, mapDBError(gorm.ErrRecordNotFound, ErrUserPostNotFound, ErrGettingUserPost)
+	}
+	return r.loadPost(post, true), nil
+}
+
+func (r *memoryPostRepository) ListPublic(feed PostFeed) (UserPosts, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	posts := UserPosts{}
+	for _, id := range sortedIDs(r.posts) {
+		post := r.loadPost(r.posts[id], true)
+		if !r.isPublic(post) || (feed.Tag != "" && !post.Tags.hasTag(feed.Tag)) {
+			continue
+		}
+		if feed.After != nil {
+			result := compareValues(post.PublishedAt(), feed.After.Value)
+			if result == 0 {
+				result = compareValues(post.ID, feed.After.ID)
+			}
+			if result == 0 || (result < 0) != feed.Descending {
+				continue
+			}
+		}
+		posts = append(posts, post)
+	}
+
+	sortPostsByPublishAt(posts, feed.Descending)
+	return posts[:minInt(feed.Limit, len(posts))], nil
+}
+
+func (r *memoryPostRepository) Update(post *UserPost) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if stored, ok := r.posts[post.ID]; ok {
+		post.UpdatedAt = time.Now()
+		stored.Title, stored.Body, stored.Status, stored.PublishAt, stored.UpdatedAt = post.Title, post.Body, post.Status, post.PublishAt, post.UpdatedAt
+		r.posts[post.ID] = stored
+	}
+	return nil
+}
+
+func (r *memoryPostRepository) ReplaceTags(post *UserPost, tags Tags) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.postTags[post.ID] = nil
+	for _, tag := range tags {
+		r.postTags[post.ID] = append(r.postTags[post.ID], tag.ID)
+	}
+	post.Tags = tags
+	return nil
+}
+
+func (r *memoryPostRepository) SoftDelete(post *UserPost) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	post.Deleted, post.DeletedAt = true, &now
+	if stored, ok := r.posts[post.ID]; ok {
+		stored.Deleted, stored.DeletedAt = post.Deleted, post.DeletedAt
+		r.posts[post.ID] = stored
+	}
+	return nil
+}
+
+func (r *memoryPostRepository) LoadCounts(posts UserPosts) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	commentCounts := map[int]int{}
+	for _, comment := range r.comments {
+		if !comment.Deleted {
+			commentCounts[comment.PostID]++
+		}
+	}
+
+	reactionCounts := map[int]map[string]int{}
+	for _, reaction := range r.reactions {
+		if reactionCounts[reaction.PostID] == nil {
+			reactionCounts[reaction.PostID] = map[string]int{}
+		}
+		reactionCounts[reaction.PostID][reaction.Kind]++
+	}
+
+	setPostCounts(posts, commentCounts, reactionCounts)
+	return nil
+}
+
+func (r *memoryPostRepository) FindOrCreateTags(names []string) (Tags, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tags := Tags{}
+	for _, name := range names {
+		tag, found := Tag{}, false
+		for _, existing := range r.tags {
+			if existing.Name == name {
+				tag, found = existing, true
+			}
+		}
+		if !found {
+			tag = Tag{ID: r.nextID("tags"), Name: name, CreatedAt: time.Now()}
+			r.tags[tag.ID] = tag
+		}
+		tags = append(tags, tag)
+	}
+
+	sort.Slice(tags, func(i, j int) bool { return tags[i].ID < tags[j].ID })
+	return tags, nil
+}
+
+func (r *memoryPostRepository) SearchTags(prefix string, limit int) ([]ResponseTag, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	postCounts := map[string]int{}
+	for postID, tagIDs := range r.postTags {
+		if post, ok := r.posts[postID]; !ok || !r.isPublic(post) {
+			continue
+		}
+		for _, tagID := range tagIDs {
+			if name := r.tags[tagID].Name; strings.HasPrefix(name, prefix) {
+				postCounts[name]++
+			}
+		}
+	}
+
+	tags := []ResponseTag{}
+	for name, postCount := range postCounts {
+		tags = append(tags, ResponseTag{Name: name, PostCount: postCount})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].PostCount != tags[j].PostCount {
+			return tags[i].PostCount > tags[j].PostCount
+		}
+		return tags[i].Name < tags[j].Name
+	})
+	return tags[:minInt(limit, len(tags))], nil
+}
+
+func (r *memoryPostRepository) React(reaction *PostReaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.reactions {
+		if existing.PostID == reaction.PostID && existing.UserID == reaction.UserID && existing.Kind == reaction.Kind {
+			return nil
+		}
+	}
+	reaction.ID, reaction.CreatedAt = r.nextID("post_reactions"), time.Now()
+	r.reactions[reaction.ID] = *reaction
+	return nil
+}
+
+func (r *memoryPostRepository) Unreact(postID, userID int, kind string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, reaction := range r.reactions {
+		if reaction.PostID == postID && reaction.UserID == userID && reaction.Kind == kind {
+			delete(r.reactions, id)
+		}
+	}
+	return nil
+}
+
+/*-------------------------
+//        COMMENTS
+//-----------------------*/
+
+type memoryCommentRepository struct {
+	*memoryStore
+}
+
+func (r *memoryCommentRepository) Create(comment *PostComment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	comment.ID, comment.CreatedAt, comment.UpdatedAt = r.nextID("post_comments"), now, now
+	r.comments[comment.ID] = bareComment(*comment)
+	return nil
+}
+
+func (r *memoryCommentRepository) Get(postID, commentID int) (PostComment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	comment, ok := r.comments[commentID]
+	if !ok || comment.PostID != postID || comment.Deleted {
+		return PostComment{}, mapDBError(gorm.ErrRecordNotFound, ErrPostCommentNotFound, ErrGettingPostComment)
+	}
+	return r.loadComment(comment), nil
+}
+
+func (r *memoryCommentRepository) ListTopLevel(postID int, offset, limit int) (PostComments, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	comments, replies := PostComments{}, map[int]PostComments{}
+	for _, id := range sortedIDs(r.comments) {
+		comment := r.comments[id]
+		if comment.PostID != postID || comment.Deleted {
+			continue
+		}
+		if comment.ParentID == nil {
+			comments = append(comments, r.loadComment(comment))
+		} else {
+			replies[*comment.ParentID] = append(replies[*comment.ParentID], r.loadComment(comment))
+		}
+	}
+
+	// Oldest first
+	sortComments(comments)
+	total := int64(len(comments))
+	comments = comments[minInt(offset, len(comments)):]
+	comments = comments[:minInt(limit, len(comments))]
+
+	for i := range comments {
+		comments[i].Replies = replies[comments[i].ID]
+		if comments[i].Replies == nil {
+			comments[i].Replies = PostComments{}
+		}
+		sortComments(comments[i].Replies)
+	}
+
+	return comments, total, nil
+}
+
+func (r *memoryCommentRepository) UpdateBody(comment *PostComment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if stored, ok := r.comments[comment.ID]; ok {
+		comment.UpdatedAt = time.Now()
+		stored.Body, stored.UpdatedAt = comment.Body, comment.UpdatedAt
+		r.comments[comment.ID] = stored
+	}
+	return nil
+}
+
+func (r *memoryCommentRepository) SoftDeleteWithReplies(commentID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for id, comment := range r.comments {
+		if (comment.ID == commentID || (comment.ParentID != nil && *comment.ParentID == commentID)) && !comment.Deleted {
+			comment.Deleted, comment.DeletedAt = true, &now
+			r.comments[id] = comment
+		}
+	}
+	return nil
+}
+
+/*-------------------------
+//      DATA EXPORTS
+//-----------------------*/
+
+type memoryDataExportRepository struct {
+	*memoryStore
+}
+
+func (r *memoryDataExportRepository) Create(export *DataExport) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	export.ID, export.CreatedAt = r.nextID("data_exports"), time.Now()
+	r.exports[export.ID] = *export
+	return nil
+}
+
+func (r *memoryDataExportRepository) Get(userID, exportID int) (DataExport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	export, ok := r.exports[exportID]
+	if !ok || export.UserID != userID {
+		return DataExport{}, mapDBError(gorm.ErrRecordNotFound, ErrDataExportNotFound, ErrGettingDataExport)
+	}
+	return export, nil
+}
+
+func (r *memoryDataExportRepository) GetPending(userID int, createdAfter time.Time) (DataExport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range sortedIDs(r.exports) {
+		if export := r.exports[id]; export.UserID == userID && export.Status == DataExportPending && export.CreatedAt.After(createdAfter) {
+			return export, nil
+		}
+	}
+	return DataExport{}, mapDBError(gorm.ErrRecordNotFound, ErrDataExportNotFound, ErrGettingDataExport)
+}
+
+func (r *memoryDataExportRepository) UpdateFields(exportID int, fields map[string]interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	export, ok := r.exports[exportID]
+	if !ok {
+		return nil
+	}
+	if err := setColumns(&export, fields); err != nil {
+		return Wrap(err.Error(), ErrUpdatingDataExport)
+	}
+	r.exports[exportID] = export
+	return nil
+}
+
+func (r *memoryDataExportRepository) ListExpired(expiredBefore time.Time) ([]DataExport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	exports := []DataExport{}
+	for _, id := range sortedIDs(r.exports) {
+		if export := r.exports[id]; export.ExpiresAt != nil && export.ExpiresAt.Before(expiredBefore) && export.FilePath != "" {
+			exports = append(exports, export)
+		}
+	}
+	return exports, nil
+}
+
+// CollectUserData is the same as the GORM one, but all of the user's posts go in, even the deleted ones
+func (r *memoryDataExportRepository) CollectUserData(export DataExport) (UserExportData, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[export.UserID]
+	if !ok {
+		return UserExportData{}, mapDBError(gorm.ErrRecordNotFound, ErrGettingUser, ErrGettingUser)
+	}
+
+	data := UserExportData{User: r.loadUser(user, false)}
+	data.User.Posts = UserPosts{}
+	for _, id := range sortedIDs(r.posts) {
+		if r.posts[id].UserID == user.ID {
+			data.User.Posts = append(data.User.Posts, r.posts[id])
+		}
+	}
+
+	data.RefreshTokens = userRows(r.refreshTokens, user.ID, func(row RefreshToken) int { return row.UserID })
+	data.Comments = userRows(r.comments, user.ID, func(row PostComment) int { return row.UserID })
+	data.Reactions = userRows(r.reactions, user.ID, func(row PostReaction) int { return row.UserID })
+	data.RecoveryCodes = userRows(r.recoveryCodes, user.ID, func(row RecoveryCode) int { return row.UserID })
+	data.PasswordResetTokens = userRows(r.passwordResetTokens, user.ID, func(row PasswordResetToken) int { return row.UserID })
+	data.EmailVerificationTokens = userRows(r.emailVerificationTokens, user.ID, func(row EmailVerificationToken) int { return row.UserID })
+
+	for _, dataExport := range userRows(r.exports, user.ID, func(row DataExport) int { return row.UserID }) {
+		if dataExport.ID != export.ID {
+			data.PreviousDataExports = append(data.PreviousDataExports, dataExport)
+		}
+	}
+
+	return data, nil
+}
+
+/*-------------------------
+//  REVOCATION & THROTTLING
+//-----------------------*/
+
+// NewMemoryRevocationStore and NewMemoryLoginThrottler are the in-memory versions of NewRevocationStore
+// and NewLoginThrottler, for tests. They share the users and refresh tokens of repos, which have to be
+// memory repositories.
+func NewMemoryRevocationStore(repos Repositories) *memoryRevocationStore {
+	return &memoryRevocationStore{repos.Users.(*memoryUserRepository).memoryStore}
+}
+
+func NewMemoryLoginThrottler(config Lockout, repos Repositories) *memoryLoginThrottler {
+	return &memoryLoginThrottler{repos.Users.(*memoryUserRepository).memoryStore, config}
+}
+
+type memoryRevocationStore struct {
+	*memoryStore
+}
+
+func (s *memoryRevocationStore) RevokeToken(tokenID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.revokedTokens[tokenID]; ok {
+		return Wrap(gorm.ErrDuplicatedKey.Error(), ErrRevokingToken)
+	}
+	s.revokedTokens[tokenID] = RevokedToken{TokenID: tokenID, ExpiresAt: expiresAt, CreatedAt: time.Now()}
+	return nil
+}
+
+func (s *memoryRevocationStore) IsTokenRevoked(tokenID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.revokedTokens[tokenID]
+	return ok, nil
+}
+
+func (s *memoryRevocationStore) RevokeAllUserTokens(userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if user, ok := s.users[userID]; ok {
+		revokedAt := now.Truncate(time.Second)
+		user.TokensRevokedAt = &revokedAt
+		s.users[userID] = user
+	}
+
+	for id, token := range s.refreshTokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+			s.refreshTokens[id] = token
+		}
+	}
+	return nil
+}
+
+func (s *memoryRevocationStore) GetUserTokensRevokedAt(userID int) (*time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return nil, mapDBError(gorm.ErrRecordNotFound, ErrUserNotFound, ErrGettingRevokedToken)
+	}
+	return user.TokensRevokedAt, nil
+}
+
+type memoryLoginThrottler struct {
+	*memoryStore
+	config Lockout
+}
+
+func (lt *memoryLoginThrottler) Check(keys ...string) (time.Duration, error) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	throttles := []LoginThrottle{}
+	for _, key := range keys {
+		if throttle, ok := lt.loginThrottles[key]; ok {
+			throttles = append(throttles, throttle)
+		}
+	}
+	return checkThrottles(lt.config, throttles, time.Now())
+}
+
+func (lt *memoryLoginThrottler) RegisterFailure(keys ...string) error {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	now := time.Now()
+	window := time.Minute * time.Duration(lt.config.LockoutMinutes)
+	for _, key := range keys {
+		throttle, ok := lt.loginThrottles[key]
+		if !ok || throttle.LastFailedAt.Before(now.Add(-window)) {
+			throttle = LoginThrottle{ThrottleKey: key}
+		}
+
+		throttle.FailedAttempts++
+		throttle.LastFailedAt, throttle.LockedUntil = now, nil
+		if throttle.FailedAttempts >= lt.config.MaxFailedAttempts {
+			lockedUntil := now.Add(window)
+			throttle.LockedUntil = &lockedUntil
+		}
+		lt.loginThrottles[key] = throttle
+	}
+	return nil
+}
+
+func (lt *memoryLoginThrottler) Reset(keys ...string) error {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	for _, key := range keys {
+		delete(lt.loginThrottles, key)
+	}
+	return nil
+}
+
+/*-------------------------
+//      UNIT OF WORK
+//-----------------------*/
+
+// memoryUnitOfWork copies the tables before running fn, and puts them back if it fails.
+// As transactions run one at a time, they are all serializable. Writes from outside of
+// a transaction aren't blocked by it though, and get lost if it's rolled back.
+const memoryTxMaxRetries = 3
+
+type memoryUnitOfWork struct {
+	*memoryStore
+	inTx bool
+}
+
+func (u *memoryUnitOfWork) Do(fn func(repos Repositories) error) error {
+	return u.DoWithIsolation(sql.LevelDefault, fn)
+}
+
+func (u *memoryUnitOfWork) DoWithIsolation(_ sql.IsolationLevel, fn func(repos Repositories) error) error {
+
+	// Nested, it's part of the outer transaction
+	if u.inTx {
+		return fn(u.repositories(true))
+	}
+
+	u.txMu.Lock()
+	defer u.txMu.Unlock()
+
+	// Nothing conflicts here, but fn may still return errTxConflict
+	return retryTx(memoryTxMaxRetries, func() error {
+		return u.run(fn)
+	})
+}
+
+func (u *memoryUnitOfWork) run(fn func(repos Repositories) error) (err error) {
+	snapshot := u.snapshot()
+	defer func() {
+		if r := recover(); r != nil {
+			u.restore(snapshot)
+			err = Wrap(fmt.Sprintf("unitOfWork: panic: %v", r), ErrInDBTransaction)
+		}
+	}()
+
+	if err := fn(u.repositories(true)); err != nil {
+		u.restore(snapshot)
+		return err
+	}
+	return nil
+}
+
+// snapshot only has the tables, restore puts them back
+func (s *memoryStore) snapshot() *memoryStore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return &memoryStore{
+		lastIDs:                 copyMap(s.lastIDs),
+		users:                   copyMap(s.users),
+		userDetails:             copyMap(s.userDetails),
+		userRoles:               copyIDLists(s.userRoles),
+		roles:                   copyMap(s.roles),
+		permissions:             copyMap(s.permissions),
+		rolePermissions:         copyIDLists(s.rolePermissions),
+		refreshTokens:           copyMap(s.refreshTokens),
+		passwordResetTokens:     copyMap(s.passwordResetTokens),
+		emailVerificationTokens: copyMap(s.emailVerificationTokens),
+		recoveryCodes:           copyMap(s.recoveryCodes),
+		posts:                   copyMap(s.posts),
+		postTags:                copyIDLists(s.postTags),
+		tags:                    copyMap(s.tags),
+		reactions:               copyMap(s.reactions),
+		comments:                copyMap(s.comments),
+		exports:                 copyMap(s.exports),
+	}
+}
+
+func (s *memoryStore) restore(snapshot *memoryStore) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastIDs = snapshot.lastIDs
+	s.users, s.userDetails, s.userRoles = snapshot.users, snapshot.userDetails, snapshot.userRoles
+	s.roles, s.permissions, s.rolePermissions = snapshot.roles, snapshot.permissions, snapshot.rolePermissions
+	s.refreshTokens, s.passwordResetTokens = snapshot.refreshTokens, snapshot.passwordResetTokens
+	s.emailVerificationTokens, s.recoveryCodes = snapshot.emailVerificationTokens, snapshot.recoveryCodes
+	s.posts, s.postTags, s.tags = snapshot.posts, snapshot.postTags, snapshot.tags
+	s.reactions, s.comments, s.exports = snapshot.reactions, snapshot.comments, snapshot.exports
+}
+
+/*-------------------------
+//        HELPERS
+//-----------------------*/
+
+// The bare functions leave out the associations, so they aren't stored with the row
+
+func bareUser(user User) User {
+	user.Details, user.Roles, user.Posts, user.NewPassword = UserDetail{}, nil, nil, ""
+	return user
+}
+
+func barePost(post UserPost) UserPost {
+	post.Author, post.Tags, post.CommentCount, post.ReactionCounts = nil, nil, 0, nil
+	return post
+}
+
+func bareComment(comment PostComment) PostComment {
+	comment.Author, comment.Replies = nil, nil
+	return comment
+}
+
+func userRows[T any](rows map[int]T, userID int, getUserID func(row T) int) []T {
+	userRows := []T{}
+	for _, id := range sortedIDs(rows) {
+		if getUserID(rows[id]) == userID {
+			userRows = append(userRows, rows[id])
+		}
+	}
+	return userRows
+}
+
+func deleteUserRows[T any](rows map[int]T, userID int, getUserID func(row T) int) {
+	for id, row := range rows {
+		if getUserID(row) == userID {
+			delete(rows, id)
+		}
+	}
+}
+
+func sortPosts(posts UserPosts, descending bool) {
+	sort.SliceStable(posts, func(i, j int) bool {
+		if !posts[i].CreatedAt.Equal(posts[j].CreatedAt) {
+			return posts[i].CreatedAt.Before(posts[j].CreatedAt) != descending
+		}
+		return (posts[i].ID < posts[j].ID) != descending
+	})
+}
+
+func sortPostsByPublishAt(posts UserPosts, descending bool) {
+	sort.SliceStable(posts, func(i, j int) bool {
+		if publishedAt := posts[i].PublishedAt(); !publishedAt.Equal(posts[j].PublishedAt()) {
+			return publishedAt.Before(posts[j].PublishedAt()) != descending
+		}
+		return (posts[i].ID < posts[j].ID) != descending
+	})
+}
+
+func sortComments(comments PostComments) {
+	sort.SliceStable(comments, func(i, j int) bool {
+		if !comments[i].CreatedAt.Equal(comments[j].CreatedAt) {
+			return comments[i].CreatedAt.Before(comments[j].CreatedAt)
+		}
+		return comments[i].ID < comments[j].ID
+	})
+}
+
+func (tags Tags) hasTag(name string) bool {
+	for _, tag := range tags {
+		if tag.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// compareValues returns -1, 0 or 1, like a comparison in SQL. Values of different types are never equal.
+func compareValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case int:
+		if b, ok := b.(int); ok {
+			return compareOrdered(a, b)
+		}
+	case string:
+		if b, ok := b.(string); ok {
+			return compareOrdered(a, b)
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			if a == b {
+				return 0
+			}
+			if !a {
+				return -1
+			}
+			return 1
+		}
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			return a.Compare(b)
+		}
+	}
+	return 1
+}
+
+func compareOrdered[T int | string](a, b T) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+// containsFold is like LIKE '%substr%', which is case insensitive on most databases
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func removeInt(values []int, value int) []int {
+	kept := []int{}
+	for _, v := range values {
+		if v != value {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+// setColumns does what GORM's Updates does with a map: the keys are column names.
+// Plain values can be set on pointer fields, and nil clears them.
+func setColumns(model interface{}, columns map[string]interface{}) error {
+	naming := schema.NamingStrategy{}
+	modelValue := reflect.ValueOf(model).Elem()
+
+	for column, value := range columns {
+		var field reflect.Value
+		for i := 0; i < modelValue.NumField(); i++ {
+			if naming.ColumnName("", modelValue.Type().Field(i).Name) == column {
+				field = modelValue.Field(i)
+			}
+		}
+		if !field.IsValid() {
+			return fmt.Errorf("unknown column %s", column)
+		}
+
+		if value == nil {
+			field.Set(reflect.Zero(field.Type()))
+			continue
+		}
+
+		newValue := reflect.ValueOf(value)
+		if field.Kind() == reflect.Pointer && newValue.Kind() != reflect.Pointer {
+			pointer := reflect.New(field.Type().Elem())
+			pointer.Elem().Set(newValue.Convert(field.Type().Elem()))
+			newValue = pointer
+		}
+		if !newValue.Type().ConvertibleTo(field.Type()) {
+			return fmt.Errorf("invalid value for column %s", column)
+		}
+		field.Set(newValue.Convert(field.Type()))
+	}
+
+	return nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func copyMap[K comparable, V any](rows map[K]V) map[K]V {
+	copied := make(map[K]V, len(rows))
+	for key, row := range rows {
+		copied[key] = row
+	}
+	return copied
+}
+
+// copyIDLists also copies the lists, as they get appended to
+func copyIDLists(lists map[int][]int) map[int][]int {
+	copied := make(map[int][]int, len(lists))
+	for key, ids := range lists {
+		copied[key] = append([]int(nil), ids...)
+	}
+	return copied
+}
@@ -1,15 +1,54 @@
 package common
 
+import "time"
+
 type AllRequests interface {
 	SignupRequest |
 		LoginRequest |
+		RefreshTokenRequest |
+		LogoutRequest |
+		RevokeUserSessionsRequest |
+		ForgotPasswordRequest |
+		ResetPasswordRequest |
+		VerifyEmailRequest |
+		ResendVerificationEmailRequest |
+		UnlockUserRequest |
+		LoginMFARequest |
+		EnrollTOTPRequest |
+		ConfirmTOTPRequest |
+		DisableTOTPRequest |
+		GetRolesRequest |
+		CreateRoleRequest |
+		AssignUserRoleRequest |
+		UnassignUserRoleRequest |
+		AdminGetUserRequest |
+		AdminUpdateUserRequest |
+		AdminDeleteUserRequest |
+		AdminRestoreUserRequest |
+		AdminHardDeleteUserRequest |
 		CreateUserRequest |
 		GetUserRequest |
 		UpdateUserRequest |
 		DeleteUserRequest |
 		SearchUsersRequest |
 		ChangePasswordRequest |
-		CreateUserPostRequest
+		CreateUserPostRequest |
+		ListUserPostsRequest |
+		GetUserPostRequest |
+		GetFeedRequest |
+		GetPostRequest |
+		GetTagsRequest |
+		CreatePostCommentRequest |
+		ListPostCommentsRequest |
+		UpdatePostCommentRequest |
+		DeletePostCommentRequest |
+		ReactToPostRequest |
+		UnreactToPostRequest |
+		UpdateUserPostRequest |
+		DeleteUserPostRequest |
+		ExportUserDataRequest |
+		GetUserDataExportRequest |
+		DownloadUserDataExportRequest
 }
 
 /*---------------
@@ -34,6 +73,104 @@ type SignupRequest struct {
 type LoginRequest struct {
 	UsernameOrEmail string `json:"username_or_email"`
 	Password        string `json:"password"`
+	ClientIP        string `json:"-"`
+}
+
+/*------------------
+//    LOGIN MFA
+//----------------*/
+
+// LoginMFARequest's code can be a TOTP code or a recovery code
+type LoginMFARequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+	ClientIP       string `json:"-"`
+}
+
+/*----------------------
+//    REFRESH TOKEN
+//--------------------*/
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+/*---------------
+//    LOGOUT
+//-------------*/
+
+type LogoutRequest struct {
+	UserID         int       `json:"user_id"`
+	TokenID        string    `json:"token_id"`
+	TokenExpiresAt time.Time `json:"token_expires_at"`
+
+	// Optional, if sent it also gets revoked
+	RefreshToken string `json:"refresh_token"`
+}
+
+/*----------------------------
+//    REVOKE USER SESSIONS
+//--------------------------*/
+
+type RevokeUserSessionsRequest struct {
+	UserID int `json:"user_id"`
+}
+
+/*-----------------------
+//    FORGOT PASSWORD
+//---------------------*/
+
+type ForgotPasswordRequest struct {
+	UsernameOrEmail string `json:"username_or_email"`
+}
+
+/*----------------------
+//    RESET PASSWORD
+//--------------------*/
+
+type ResetPasswordRequest struct {
+	Token          string `json:"token"`
+	NewPassword    string `json:"new_password"`
+	RepeatPassword string `json:"repeat_password"`
+}
+
+/*--------------------
+//    VERIFY EMAIL
+//------------------*/
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type ResendVerificationEmailRequest struct {
+	UsernameOrEmail string `json:"username_or_email"`
+}
+
+/*-------------------
+//    UNLOCK USER
+//-----------------*/
+
+type UnlockUserRequest struct {
+	UserID int `json:"user_id"`
+}
+
+/*--------------------
+//    TWO FACTOR
+//------------------*/
+
+type EnrollTOTPRequest struct {
+	UserID int `json:"user_id"`
+}
+
+type ConfirmTOTPRequest struct {
+	UserID int    `json:"user_id"`
+	Code   string `json:"code"`
+}
+
+// DisableTOTPRequest's code can be a TOTP code or a recovery code
+type DisableTOTPRequest struct {
+	UserID int    `json:"user_id"`
+	Code   string `json:"code"`
 }
 
 /*---------------------
@@ -85,10 +222,16 @@ type DeleteUserRequest struct {
 //    SEARCH USERS
 //------------------*/
 
+// SearchUsersRequest works with page & per_page (offset mode) or with a cursor from a previous response.
+// Sort is a field name, prefixed with "-" for descending order. Deleted users are left out unless filtered by deleted.
 type SearchUsersRequest struct {
-	Username string `json:"username"`
-	Page     int    `json:"page"`
-	PerPage  int    `json:"per_page"`
+	Username     string       `json:"username"`
+	Filters      []UserFilter `json:"filters"`
+	Page         int          `json:"page"`
+	PerPage      int          `json:"per_page"`
+	Sort         string       `json:"sort"`
+	Cursor       *Cursor      `json:"cursor"`
+	IncludeTotal bool         `json:"total"`
 }
 
 /*-----------------------
@@ -102,12 +245,191 @@ type ChangePasswordRequest struct {
 	RepeatPassword string `json:"repeat_password"`
 }
 
+/*-----------------------
+//    PERSONAL DATA
+//---------------------*/
+
+type ExportUserDataRequest struct {
+	UserID int `json:"user_id"`
+}
+
+type GetUserDataExportRequest struct {
+	UserID   int `json:"user_id"`
+	ExportID int `json:"export_id"`
+}
+
+type DownloadUserDataExportRequest struct {
+	UserID   int `json:"user_id"`
+	ExportID int `json:"export_id"`
+}
+
+/*---------------------
+//    ADMIN USERS
+//-------------------*/
+
+// These work on any user, deleted or not, by the ID on the path
+
+type AdminGetUserRequest struct {
+	UserID int `json:"user_id"`
+}
+
+type AdminUpdateUserRequest struct {
+	UserID   int     `json:"user_id"`
+	Username string  `json:"username"`
+	Email    string  `json:"email"`
+	IsAdmin  *bool   `json:"is_admin"`
+	Password *string `json:"password"`
+
+	// User Detail
+	FirstName *string `json:"first_name"`
+	LastName  *string `json:"last_name"`
+}
+
+type AdminDeleteUserRequest struct {
+	UserID int `json:"user_id"`
+}
+
+type AdminRestoreUserRequest struct {
+	UserID int `json:"user_id"`
+}
+
+type AdminHardDeleteUserRequest struct {
+	UserID int `json:"user_id"`
+}
+
+/*-------------
+//    ROLES
+//-----------*/
+
+type GetRolesRequest struct{}
+
+type CreateRoleRequest struct {
+	Name        RoleName `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+type AssignUserRoleRequest struct {
+	UserID   int      `json:"user_id"`
+	RoleName RoleName `json:"role_name"`
+}
+
+type UnassignUserRoleRequest struct {
+	UserID   int      `json:"user_id"`
+	RoleName RoleName `json:"role_name"`
+}
+
 /*------------------------
 //    CREATE USER POST
 //----------------------*/
 
+// CreateUserPostRequest's Status is published by default. PublishAt is only for scheduled posts.
 type CreateUserPostRequest struct {
+	UserID    int        `json:"user_id"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body"`
+	Tags      []string   `json:"tags"`
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+/*----------------------
+//     USER POSTS
+//--------------------*/
+
+// ListUserPostsRequest's Status is optional
+type ListUserPostsRequest struct {
+	UserID  int    `json:"user_id"`
+	Status  string `json:"status"`
+	Page    int    `json:"page"`
+	PerPage int    `json:"per_page"`
+}
+
+/*----------------
+//     FEED
+//--------------*/
+
+// GetFeedRequest's Tag is optional, if set only posts with that tag are returned
+type GetFeedRequest struct {
+	Tag     string  `json:"tag"`
+	PerPage int     `json:"per_page"`
+	Cursor  *Cursor `json:"cursor"`
+}
+
+// GetTagsRequest returns the most used tags starting with the prefix
+type GetTagsRequest struct {
+	Prefix string `json:"prefix"`
+	Limit  int    `json:"limit"`
+}
+
+type GetPostRequest struct {
+	PostID int `json:"post_id"`
+}
+
+/*--------------------
+//     COMMENTS
+//------------------*/
+
+type CreatePostCommentRequest struct {
+	UserID   int    `json:"user_id"`
+	PostID   int    `json:"post_id"`
+	ParentID *int   `json:"parent_id"`
+	Body     string `json:"body"`
+}
+
+type ListPostCommentsRequest struct {
+	PostID  int `json:"post_id"`
+	Page    int `json:"page"`
+	PerPage int `json:"per_page"`
+}
+
+type UpdatePostCommentRequest struct {
+	UserID    int    `json:"user_id"`
+	PostID    int    `json:"post_id"`
+	CommentID int    `json:"comment_id"`
+	Body      string `json:"body"`
+}
+
+// DeletePostCommentRequest's IsAdmin lets them delete comments of other users
+type DeletePostCommentRequest struct {
+	UserID    int  `json:"user_id"`
+	PostID    int  `json:"post_id"`
+	CommentID int  `json:"comment_id"`
+	IsAdmin   bool `json:"-"`
+}
+
+/*---------------------
+//     REACTIONS
+//-------------------*/
+
+type ReactToPostRequest struct {
+	UserID int    `json:"user_id"`
+	PostID int    `json:"post_id"`
+	Kind   string `json:"kind"`
+}
+
+type UnreactToPostRequest struct {
 	UserID int    `json:"user_id"`
-	Title  string `json:"title"`
-	Body   string `json:"body"`
+	PostID int    `json:"post_id"`
+	Kind   string `json:"kind"`
+}
+
+type GetUserPostRequest struct {
+	UserID int `json:"user_id"`
+	PostID int `json:"post_id"`
+}
+
+// UpdateUserPostRequest's Tags replace the old ones, if set. Setting only PublishAt (re)schedules the post.
+type UpdateUserPostRequest struct {
+	UserID    int        `json:"user_id"`
+	PostID    int        `json:"post_id"`
+	Title     *string    `json:"title"`
+	Body      *string    `json:"body"`
+	Tags      *[]string  `json:"tags"`
+	Status    *string    `json:"status"`
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+type DeleteUserPostRequest struct {
+	UserID int `json:"user_id"`
+	PostID int `json:"post_id"`
 }
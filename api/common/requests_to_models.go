@@ -15,7 +15,6 @@ func (r *SignupRequest) ToUserModel() User {
 			FirstName: r.FirstName,
 			LastName:  r.LastName,
 		},
-		IsAdmin:   false,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -34,6 +33,17 @@ func (r *LoginRequest) ToUserModel() User {
 	return user
 }
 
+func (r *ForgotPasswordRequest) ToUserModel() User {
+	loginRequest := LoginRequest{UsernameOrEmail: r.UsernameOrEmail}
+	return loginRequest.ToUserModel()
+}
+
+func (r *ResendVerificationEmailRequest) ToUserModel() User {
+	loginRequest := LoginRequest{UsernameOrEmail: r.UsernameOrEmail}
+	return loginRequest.ToUserModel()
+}
+
+// Users created by an admin don't need to verify their email
 func (r *CreateUserRequest) ToUserModel() User {
 	return User{
 		Email:    r.Email,
@@ -44,7 +54,7 @@ func (r *CreateUserRequest) ToUserModel() User {
 			FirstName: r.FirstName,
 			LastName:  r.LastName,
 		},
-		IsAdmin:   r.IsAdmin,
+		Verified:  true,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -88,10 +98,48 @@ func (r *ChangePasswordRequest) ToUserModel() User {
 	}
 }
 
+// ToUserPostModel doesn't set the tags, they need to be found or created first
 func (r *CreateUserPostRequest) ToUserPostModel() UserPost {
-	return UserPost{
+	userPost := UserPost{
 		UserID: r.UserID,
 		Title:  r.Title,
 		Body:   r.Body,
 	}
+	userPost.SetStatus(r.Status, r.PublishAt)
+	return userPost
+}
+
+func (r *CreatePostCommentRequest) ToPostCommentModel() PostComment {
+	return PostComment{
+		PostID:   r.PostID,
+		UserID:   r.UserID,
+		ParentID: r.ParentID,
+		Body:     r.Body,
+	}
+}
+
+func (r *ReactToPostRequest) ToPostReactionModel() PostReaction {
+	return PostReaction{PostID: r.PostID, UserID: r.UserID, Kind: r.Kind}
+}
+
+func (r *UnlockUserRequest) ToUserModel() User {
+	return User{ID: r.UserID}
+}
+
+func (r *RevokeUserSessionsRequest) ToUserModel() User {
+	return User{ID: r.UserID}
+}
+
+func (r *CreateRoleRequest) ToRoleModel() Role {
+	return Role{Name: r.Name}
+}
+
+func (r *AdminUpdateUserRequest) ToUpdateUserRequest() UpdateUserRequest {
+	return UpdateUserRequest{
+		UserID:    r.UserID,
+		Username:  r.Username,
+		Email:     r.Email,
+		FirstName: r.FirstName,
+		LastName:  r.LastName,
+	}
 }
@@ -8,13 +8,50 @@ import "time"
 type AllResponses interface {
 	SignupResponse |
 		LoginResponse |
+		RefreshTokenResponse |
+		LogoutResponse |
+		RevokeUserSessionsResponse |
+		ForgotPasswordResponse |
+		ResetPasswordResponse |
+		VerifyEmailResponse |
+		ResendVerificationEmailResponse |
+		UnlockUserResponse |
+		LoginMFAResponse |
+		EnrollTOTPResponse |
+		ConfirmTOTPResponse |
+		DisableTOTPResponse |
+		GetRolesResponse |
+		CreateRoleResponse |
+		AssignUserRoleResponse |
+		UnassignUserRoleResponse |
+		AdminGetUserResponse |
+		AdminUpdateUserResponse |
+		AdminDeleteUserResponse |
+		AdminRestoreUserResponse |
+		AdminHardDeleteUserResponse |
 		CreateUserResponse |
 		GetUserResponse |
 		UpdateUserResponse |
 		DeleteUserResponse |
 		SearchUsersResponse |
 		ChangePasswordResponse |
-		CreateUserPostResponse
+		CreateUserPostResponse |
+		ListUserPostsResponse |
+		GetUserPostResponse |
+		GetFeedResponse |
+		GetPostResponse |
+		GetTagsResponse |
+		CreatePostCommentResponse |
+		ListPostCommentsResponse |
+		UpdatePostCommentResponse |
+		DeletePostCommentResponse |
+		ReactToPostResponse |
+		UnreactToPostResponse |
+		UpdateUserPostResponse |
+		DeleteUserPostResponse |
+		ExportUserDataResponse |
+		GetUserDataExportResponse |
+		DownloadUserDataExportResponse
 }
 
 /*-------------------
@@ -25,14 +62,72 @@ type SignupResponse struct {
 	User ResponseUser `json:"user"`
 }
 
+// LoginResponse has either the tokens or, if the user has 2FA enabled, a challenge token for /v1/login/mfa
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token          string `json:"token,omitempty"`
+	RefreshToken   string `json:"refresh_token,omitempty"`
+	MFARequired    bool   `json:"mfa_required,omitempty"`
+	ChallengeToken string `json:"challenge_token,omitempty"`
+}
+
+type LoginMFAResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type RefreshTokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type LogoutResponse struct {
+	LoggedOut bool `json:"logged_out"`
+}
+
+type RevokeUserSessionsResponse struct {
+	User ResponseUser `json:"user"`
+}
+
+// ForgotPasswordResponse is the same whether the user exists or not
+type ForgotPasswordResponse struct {
+	Message string `json:"message"`
+}
+
+type ResetPasswordResponse struct {
+	User ResponseUser `json:"user"`
+}
+
+type VerifyEmailResponse struct {
+	User ResponseUser `json:"user"`
+}
+
+// ResendVerificationEmailResponse is the same whether the user exists or not
+type ResendVerificationEmailResponse struct {
+	Message string `json:"message"`
+}
+
+type EnrollTOTPResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// ConfirmTOTPResponse is the only time the recovery codes are shown
+type ConfirmTOTPResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type DisableTOTPResponse struct {
+	User ResponseUser `json:"user"`
 }
 
 /*--------------------
 //      USERS
 //------------------*/
 
+type UnlockUserResponse struct {
+	User ResponseUser `json:"user"`
+}
+
 type CreateUserResponse struct {
 	User ResponseUser `json:"user"`
 }
@@ -41,6 +136,21 @@ type GetUserResponse struct {
 	User ResponseUser `json:"user"`
 }
 
+// ExportUserDataResponse has the export that is being built. Poll its status until it's ready.
+type ExportUserDataResponse struct {
+	Export ResponseDataExport `json:"export"`
+}
+
+type GetUserDataExportResponse struct {
+	Export ResponseDataExport `json:"export"`
+}
+
+// DownloadUserDataExportResponse isn't returned as JSON, the file is sent instead
+type DownloadUserDataExportResponse struct {
+	FilePath string `json:"-"`
+	FileName string `json:"-"`
+}
+
 type UpdateUserResponse struct {
 	User ResponseUser `json:"user"`
 }
@@ -49,10 +159,15 @@ type DeleteUserResponse struct {
 	User ResponseUser `json:"user"`
 }
 
+// SearchUsersResponse's Page is only meaningful in offset mode. Total is only set if it was asked for.
 type SearchUsersResponse struct {
-	Users   []ResponseUser `json:"users"`
-	Page    int            `json:"page"`
-	PerPage int            `json:"per_page"`
+	Users      []ResponseUser `json:"users"`
+	Page       int            `json:"page"`
+	PerPage    int            `json:"per_page"`
+	Sort       string         `json:"sort"`
+	Total      *int64         `json:"total,omitempty"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	PrevCursor string         `json:"prev_cursor,omitempty"`
 }
 
 type ChangePasswordResponse struct {
@@ -63,6 +178,121 @@ type CreateUserPostResponse struct {
 	UserPost ResponseUserPost `json:"user_post"`
 }
 
+type ListUserPostsResponse struct {
+	UserPosts []ResponseUserPost `json:"user_posts"`
+	Page      int                `json:"page"`
+	PerPage   int                `json:"per_page"`
+	Total     int64              `json:"total"`
+}
+
+// GetFeedResponse has the newest posts first. Use next_cursor to get older ones.
+type GetFeedResponse struct {
+	Posts      []ResponseUserPost `json:"posts"`
+	PerPage    int                `json:"per_page"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+	PrevCursor string             `json:"prev_cursor,omitempty"`
+}
+
+type GetTagsResponse struct {
+	Tags []ResponseTag `json:"tags"`
+}
+
+type GetPostResponse struct {
+	Post ResponseUserPost `json:"post"`
+}
+
+type CreatePostCommentResponse struct {
+	Comment ResponsePostComment `json:"comment"`
+}
+
+// ListPostCommentsResponse has the top-level comments, oldest first, each one with its replies
+type ListPostCommentsResponse struct {
+	Comments []ResponsePostComment `json:"comments"`
+	Page     int                   `json:"page"`
+	PerPage  int                   `json:"per_page"`
+	Total    int64                 `json:"total"`
+}
+
+type UpdatePostCommentResponse struct {
+	Comment ResponsePostComment `json:"comment"`
+}
+
+type DeletePostCommentResponse struct {
+	Comment ResponsePostComment `json:"comment"`
+}
+
+// ReactToPostResponse has the counts after the change. Reacted is how the user ended up, not if something changed.
+type ReactToPostResponse struct {
+	PostID         int            `json:"post_id"`
+	Kind           string         `json:"kind"`
+	Reacted        bool           `json:"reacted"`
+	ReactionCounts map[string]int `json:"reaction_counts"`
+}
+
+type UnreactToPostResponse struct {
+	PostID         int            `json:"post_id"`
+	Kind           string         `json:"kind"`
+	Reacted        bool           `json:"reacted"`
+	ReactionCounts map[string]int `json:"reaction_counts"`
+}
+
+type GetUserPostResponse struct {
+	UserPost ResponseUserPost `json:"user_post"`
+}
+
+type UpdateUserPostResponse struct {
+	UserPost ResponseUserPost `json:"user_post"`
+}
+
+type DeleteUserPostResponse struct {
+	UserPost ResponseUserPost `json:"user_post"`
+}
+
+/*--------------------
+//    ADMIN USERS
+//------------------*/
+
+type AdminGetUserResponse struct {
+	User ResponseUser `json:"user"`
+}
+
+type AdminUpdateUserResponse struct {
+	User ResponseUser `json:"user"`
+}
+
+type AdminDeleteUserResponse struct {
+	User ResponseUser `json:"user"`
+}
+
+type AdminRestoreUserResponse struct {
+	User ResponseUser `json:"user"`
+}
+
+// AdminHardDeleteUserResponse has the user as it was before being deleted
+type AdminHardDeleteUserResponse struct {
+	User ResponseUser `json:"user"`
+}
+
+/*--------------------
+//      ROLES
+//------------------*/
+
+type GetRolesResponse struct {
+	Roles []ResponseRole `json:"roles"`
+}
+
+type CreateRoleResponse struct {
+	Role ResponseRole `json:"role"`
+}
+
+type AssignUserRoleResponse struct {
+	User ResponseUser `json:"user"`
+}
+
+type UnassignUserRoleResponse struct {
+	User ResponseUser `json:"user"`
+}
+
 /*-----------------------
 //    RESPONSE MODELS
 //---------------------*/
@@ -72,20 +302,71 @@ type ResponseUser struct {
 	Username  string             `json:"username"`
 	Email     string             `json:"email"`
 	IsAdmin   bool               `json:"is_admin,omitempty"`
+	Roles     []RoleName         `json:"roles,omitempty"`
+	Verified  bool               `json:"verified"`
+	TwoFactor bool               `json:"two_factor"`
 	Details   ResponseUserDetail `json:"details"`
 	Posts     []ResponseUserPost `json:"posts"`
 	Deleted   bool               `json:"deleted,omitempty"`
+	DeletedAt *time.Time         `json:"deleted_at,omitempty"`
 	CreatedAt time.Time          `json:"created_at,omitempty"`
 	UpdatedAt time.Time          `json:"updated_at,omitempty"`
 }
 
+type ResponseDataExport struct {
+	ID          int        `json:"id"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+type ResponseRole struct {
+	ID          int      `json:"id"`
+	Name        RoleName `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+// ResponsePostComment's replies are only set on top-level comments
+type ResponsePostComment struct {
+	ID        int                   `json:"id"`
+	PostID    int                   `json:"post_id"`
+	ParentID  *int                  `json:"parent_id,omitempty"`
+	Author    *ResponseAuthor       `json:"author,omitempty"`
+	Body      string                `json:"body"`
+	Replies   []ResponsePostComment `json:"replies,omitempty"`
+	CreatedAt time.Time             `json:"created_at"`
+	UpdatedAt time.Time             `json:"updated_at"`
+}
+
+// ResponseTag's PostCount only counts the posts that can be seen on the feed
+type ResponseTag struct {
+	Name      string `json:"name"`
+	PostCount int    `json:"post_count"`
+}
+
+// ResponseAuthor is what anyone can see about a user. No email here.
+type ResponseAuthor struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+}
+
 type ResponseUserDetail struct {
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
 }
 
 type ResponseUserPost struct {
-	ID    int    `json:"id"`
-	Title string `json:"title"`
-	Body  string `json:"body"`
+	ID             int             `json:"id"`
+	Title          string          `json:"title"`
+	Body           string          `json:"body"`
+	Author         *ResponseAuthor `json:"author,omitempty"`
+	Tags           []string        `json:"tags"`
+	Status         string          `json:"status"`
+	PublishAt      *time.Time      `json:"publish_at,omitempty"`
+	CommentCount   int             `json:"comment_count"`
+	ReactionCounts map[string]int  `json:"reaction_counts"`
+	Deleted        bool            `json:"deleted,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
 }
@@ -0,0 +1,72 @@
+package common
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RevocationStore keeps track of the access tokens that shouldn't be accepted anymore,
+// either one by one (by their jti) or all of a user's tokens issued before a point in time.
+type RevocationStore interface {
+	RevokeToken(tokenID string, expiresAt time.Time) error
+	IsTokenRevoked(tokenID string) (bool, error)
+	RevokeAllUserTokens(userID int) error
+	GetUserTokensRevokedAt(userID int) (*time.Time, error)
+}
+
+func NewRevocationStore(db *gorm.DB) *revocationStore {
+	return &revocationStore{db: db}
+}
+
+type revocationStore struct {
+	db *gorm.DB
+}
+
+func (s *revocationStore) RevokeToken(tokenID string, expiresAt time.Time) error {
+
+	// Once a token has expired there's no point in remembering it
+	s.db.Where("expires_at < ?", time.Now()).Delete(&RevokedToken{})
+
+	revokedToken := RevokedToken{TokenID: tokenID, ExpiresAt: expiresAt}
+	if err := s.db.Create(&revokedToken).Error; err != nil {
+		return Wrap(err.Error(), ErrRevokingToken)
+	}
+	return nil
+}
+
+func (s *revocationStore) IsTokenRevoked(tokenID string) (bool, error) {
+	var count int64
+	if err := s.db.Model(&RevokedToken{}).Where("token_id = ?", tokenID).Count(&count).Error; err != nil {
+		return false, Wrap(err.Error(), ErrGettingRevokedToken)
+	}
+	return count > 0, nil
+}
+
+// RevokeAllUserTokens invalidates every access token issued to the user until now, and all of their refresh tokens
+func (s *revocationStore) RevokeAllUserTokens(userID int) error {
+	now := time.Now()
+
+	// JWTs only have second precision, the tokens issued during this second are revoked too
+	if err := s.db.Model(&User{}).Where("id = ?", userID).Update("tokens_revoked_at", now.Truncate(time.Second)).Error; err != nil {
+		return Wrap(err.Error(), ErrRevokingToken)
+	}
+
+	if err := s.db.Model(&RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", userID).Update("revoked_at", now).Error; err != nil {
+		return Wrap(err.Error(), ErrRevokingToken)
+	}
+
+	return nil
+}
+
+func (s *revocationStore) GetUserTokensRevokedAt(userID int) (*time.Time, error) {
+	var user User
+	if err := s.db.Select("id", "tokens_revoked_at").Where("id = ?", userID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, Wrap(err.Error(), ErrUserNotFound)
+		}
+		return nil, Wrap(err.Error(), ErrGettingRevokedToken)
+	}
+	return user.TokensRevokedAt, nil
+}
@@ -0,0 +1,174 @@
+package common
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+/*-------------------------
+//  ROLES & PERMISSIONS
+//-----------------------*/
+
+// Permissions are what the endpoints check. Roles are just named groups of them, assigned to users.
+
+const (
+	PermissionSearchUsers  = "users:search"
+	PermissionCreateUsers  = "users:create"
+	PermissionManageUsers  = "users:manage"
+	PermissionManageRoles  = "roles:manage"
+	PermissionManageAdmins = "admins:manage"
+)
+
+var AllPermissions = []string{
+	PermissionSearchUsers,
+	PermissionCreateUsers,
+	PermissionManageUsers,
+	PermissionManageRoles,
+	PermissionManageAdmins,
+}
+
+// defaultRoles are the roles the memory repositories start with. On the database, the roles migration creates them.
+var defaultRoles = map[RoleName][]string{
+	UserRole:  {},
+	AdminRole: AllPermissions,
+}
+
+// initialRoles are the permissions and roles the roles migration creates. Like the initial schema, they're frozen:
+// new permissions, or new roles, go in new migrations. The checksum of the migration is made from them.
+func initialRoles() (permissions []string, roles []Role) {
+	permissions = []string{"users:search", "users:create", "users:manage", "roles:manage", "admins:manage"}
+
+	adminPermissions := []Permission{}
+	for _, name := range permissions {
+		adminPermissions = append(adminPermissions, Permission{Name: name})
+	}
+
+	return permissions, []Role{{Name: "user"}, {Name: "admin", Permissions: adminPermissions}}
+}
+
+func initialRolesChecksum() string {
+	permissions, roles := initialRoles()
+	description := fmt.Sprintf("permissions %v\n", permissions)
+	for _, role := range roles {
+		description += fmt.Sprintf("role %s %v\n", role.Name, role.Permissions)
+	}
+	return checksum(description)
+}
+
+// createInitialRoles creates the initial permissions and roles. The ones that already exist are left as they are,
+// they were created on startup before this was a migration.
+func createInitialRoles(tx *gorm.DB) error {
+	permissionNames, roles := initialRoles()
+
+	// Permissions
+	for _, name := range permissionNames {
+		if err := tx.Where(Permission{Name: name}).FirstOrCreate(&Permission{}).Error; err != nil {
+			return err
+		}
+	}
+
+	// Roles
+	for _, initialRole := range roles {
+		var role Role
+		if err := tx.Where(Role{Name: initialRole.Name}).FirstOrCreate(&role).Error; err != nil {
+			return err
+		}
+
+		// Only set the permissions of newly created roles
+		if tx.Model(&role).Association("Permissions").Count() > 0 || len(initialRole.Permissions) == 0 {
+			continue
+		}
+
+		names := []string{}
+		for _, permission := range initialRole.Permissions {
+			names = append(names, permission.Name)
+		}
+
+		var permissions []Permission
+		if err := tx.Where("name IN ?", names).Find(&permissions).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&role).Association("Permissions").Append(&permissions); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dropInitialRoles takes the initial roles away from the users, and deletes them and the initial permissions
+func dropInitialRoles(tx *gorm.DB) error {
+	permissionNames, roles := initialRoles()
+
+	roleNames := []RoleName{}
+	for _, role := range roles {
+		roleNames = append(roleNames, role.Name)
+	}
+
+	roleIDs := tx.Table("roles").Select("id").Where("name IN ?", roleNames)
+	permissionIDs := tx.Table("permissions").Select("id").Where("name IN ?", permissionNames)
+
+	if err := tx.Exec("DELETE FROM user_roles WHERE role_id IN (?)", roleIDs).Error; err != nil {
+		return err
+	}
+	if err := tx.Exec("DELETE FROM role_permissions WHERE role_id IN (?) OR permission_id IN (?)", roleIDs, permissionIDs).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("name IN ?", roleNames).Delete(&Role{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("name IN ?", permissionNames).Delete(&Permission{}).Error
+}
+
+// isAdminUser has the is_admin column that was replaced by the admin role. Databases from back then still have it.
+// Its table is the one of the users.
+type isAdminUser struct {
+	IsAdmin bool `gorm:"not null;default:false"`
+}
+
+func (isAdminUser) TableName() string { return "users" }
+
+const restoreIsAdminSQL = "UPDATE users SET is_admin = true WHERE id IN (SELECT user_roles.user_id FROM user_roles JOIN roles ON roles.id = user_roles.role_id WHERE roles.name = 'admin')"
+
+// moveIsAdminToRoles gives the admin role to the users flagged with the old is_admin column, then drops it
+func moveIsAdminToRoles(tx *gorm.DB) error {
+	if !tx.Migrator().HasColumn(&User{}, "is_admin") {
+		return nil
+	}
+
+	var adminRole Role
+	if err := tx.Where("name = ?", AdminRole).First(&adminRole).Error; err != nil {
+		return err
+	}
+
+	var adminIDs []int
+	if err := tx.Table("users").Where("is_admin = ?", true).Pluck("id", &adminIDs).Error; err != nil {
+		return err
+	}
+
+	userRoles := []map[string]interface{}{}
+	for _, userID := range adminIDs {
+		userRoles = append(userRoles, map[string]interface{}{"user_id": userID, "role_id": adminRole.ID})
+	}
+
+	if len(userRoles) > 0 {
+		if err := tx.Table("user_roles").Clauses(clause.OnConflict{DoNothing: true}).Create(userRoles).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Migrator().DropColumn(&User{}, "is_admin")
+}
+
+// restoreIsAdmin adds the is_admin column back, set for the users with the admin role
+func restoreIsAdmin(tx *gorm.DB) error {
+	if tx.Migrator().HasColumn(&isAdminUser{}, "IsAdmin") {
+		return nil
+	}
+	if err := tx.Migrator().AddColumn(&isAdminUser{}, "IsAdmin"); err != nil {
+		return err
+	}
+	return tx.Exec(restoreIsAdminSQL).Error
+}
@@ -0,0 +1,249 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+/*-------------------------
+//         ADMIN
+//-----------------------*/
+
+// BootstrapAdmin creates the admin of the config if there's no user with its username or email.
+// It can run on every startup, an existing admin is left as it is, password included.
+// A user that has the username or email but isn't an admin doesn't get promoted, and a deleted one isn't
+// restored, there's only a warning.
+func BootstrapAdmin(config Admin, repos Repositories, hasher PasswordHasher, logger *logrus.Logger) error {
+	if config.Username == "" {
+		return nil
+	}
+
+	password, err := config.GetPassword()
+	if err != nil {
+		return err
+	}
+	if config.Email == "" || password == "" {
+		return fmt.Errorf("the admin needs an email and a password too")
+	}
+
+	if exists, err := checkExistingAdmin(config, repos, logger); err != nil || exists {
+		return err
+	}
+
+	roles, err := repos.Roles.GetByNames(UserRole, AdminRole)
+	if err != nil {
+		return Wrap("BootstrapAdmin: repos.Roles.GetByNames", err)
+	}
+
+	admin := User{
+		Username:  config.Username,
+		Email:     config.Email,
+		Password:  password,
+		Roles:     roles,
+		Verified:  true,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := admin.HashPassword(hasher); err != nil {
+		return Wrap("BootstrapAdmin: admin.HashPassword", err)
+	}
+
+	if err := repos.Users.Create(&admin); err != nil {
+
+		// Another instance created it first, or a deleted user still has the username or email
+		if errors.Is(err, ErrUsernameOrEmailAlreadyInUse) {
+			exists, err := checkExistingAdmin(config, repos, logger)
+			if err == nil && !exists {
+				logger.WithField("username", config.Username).Warn("Admin not created, its username or email belongs to a deleted user. Restore them, or wait until they're purged")
+			}
+			return err
+		}
+		return Wrap("BootstrapAdmin: repos.Users.Create", err)
+	}
+
+	logger.WithField("username", admin.Username).Info("Admin created")
+	return nil
+}
+
+// checkExistingAdmin is true if a user has the username or email of the admin, whether they are an admin or not
+func checkExistingAdmin(config Admin, repos Repositories, logger *logrus.Logger) (bool, error) {
+	user, err := repos.Users.GetActiveByUsernameOrEmail(config.Username, config.Email)
+	if errors.Is(err, ErrUserNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, Wrap("checkExistingAdmin: repos.Users.GetActiveByUsernameOrEmail", err)
+	}
+
+	if user, err = repos.Users.GetWithPermissions(user.ID); err != nil {
+		return false, Wrap("checkExistingAdmin: repos.Users.GetWithPermissions", err)
+	}
+	if !user.HasRole(AdminRole) {
+		logger.WithField("user_id", user.ID).Warn("Admin not created, its username or email belongs to a user that isn't an admin")
+	}
+	return true, nil
+}
+
+/*-------------------------
+//        FIXTURES
+//-----------------------*/
+
+// Fixtures are demo users with their details and posts, see seed_example.yaml
+type Fixtures struct {
+	Users []UserFixture `json:"users" yaml:"users"`
+}
+
+// UserFixture always has the user role, Roles are the ones on top of it
+type UserFixture struct {
+	Username  string        `json:"username" yaml:"username"`
+	Email     string        `json:"email" yaml:"email"`
+	Password  string        `json:"password" yaml:"password"`
+	FirstName string        `json:"first_name" yaml:"first_name"`
+	LastName  string        `json:"last_name" yaml:"last_name"`
+	Verified  bool          `json:"verified" yaml:"verified"`
+	Roles     []RoleName    `json:"roles" yaml:"roles"`
+	Posts     []PostFixture `json:"posts" yaml:"posts"`
+}
+
+// PostFixture is published if it has no status. Scheduled ones need a PublishAt.
+type PostFixture struct {
+	Title     string     `json:"title" yaml:"title"`
+	Body      string     `json:"body" yaml:"body"`
+	Tags      []string   `json:"tags" yaml:"tags"`
+	Status    string     `json:"status" yaml:"status"`
+	PublishAt *time.Time `json:"publish_at" yaml:"publish_at"`
+}
+
+// LoadFixtures tells JSON from YAML by the file extension
+func LoadFixtures(path string) (Fixtures, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Fixtures{}, fmt.Errorf("error reading fixtures: %w", err)
+	}
+
+	var fixtures Fixtures
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(content, &fixtures)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &fixtures)
+	default:
+		return Fixtures{}, fmt.Errorf("fixtures file %q must be .json, .yaml or .yml", path)
+	}
+	if err != nil {
+		return Fixtures{}, fmt.Errorf("error parsing fixtures: %w", err)
+	}
+
+	return fixtures, nil
+}
+
+// SeedFixtures creates each user with their posts in a transaction, and returns how many were created.
+// Users whose username or email is already taken are skipped with their posts, so seeding twice is harmless.
+func SeedFixtures(fixtures Fixtures, repos Repositories, hasher PasswordHasher) (int, error) {
+	created := 0
+	for i, fixture := range fixtures.Users {
+		if fixture.Username == "" || fixture.Email == "" || fixture.Password == "" {
+			return created, fmt.Errorf("user %d of the fixtures needs a username, an email and a password", i+1)
+		}
+
+		user, posts, err := fixture.toModels(repos, hasher)
+		if err != nil {
+			return created, Wrap(fmt.Sprintf("SeedFixtures: user %s", fixture.Username), err)
+		}
+
+		err = repos.UnitOfWork.Do(func(repos Repositories) error {
+			user := user // A copy, in case it's a retry
+			if err := repos.Users.Create(&user); err != nil {
+				return Wrap("repos.Users.Create", err)
+			}
+
+			for _, post := range posts {
+				tags, err := repos.Posts.FindOrCreateTags(post.tags)
+				if err != nil {
+					return Wrap("repos.Posts.FindOrCreateTags", err)
+				}
+
+				userPost := post.userPost
+				userPost.UserID, userPost.Tags = user.ID, tags
+				if err := repos.Posts.Create(&userPost); err != nil {
+					return Wrap("repos.Posts.Create", err)
+				}
+			}
+			return nil
+		})
+		if errors.Is(err, ErrUsernameOrEmailAlreadyInUse) {
+			continue
+		}
+		if err != nil {
+			return created, Wrap(fmt.Sprintf("SeedFixtures: user %s: repos.UnitOfWork.Do", fixture.Username), err)
+		}
+		created++
+	}
+	return created, nil
+}
+
+type postFixtureModel struct {
+	userPost UserPost
+	tags     []string
+}
+
+// toModels validates the fixture, and hashes the password before the transaction starts
+func (fixture UserFixture) toModels(repos Repositories, hasher PasswordHasher) (User, []postFixtureModel, error) {
+	roleNames := []RoleName{UserRole}
+	for _, name := range fixture.Roles {
+		if name != UserRole {
+			roleNames = append(roleNames, name)
+		}
+	}
+	roles, err := repos.Roles.GetByNames(roleNames...)
+	if err != nil {
+		return User{}, nil, Wrap("repos.Roles.GetByNames", err)
+	}
+	if len(roles) != len(roleNames) {
+		return User{}, nil, Wrap(fmt.Sprintf("roles %v", fixture.Roles), ErrRoleNotFound)
+	}
+
+	user := User{
+		Username:  fixture.Username,
+		Email:     fixture.Email,
+		Password:  fixture.Password,
+		Roles:     roles,
+		Verified:  fixture.Verified,
+		Details:   UserDetail{FirstName: fixture.FirstName, LastName: fixture.LastName},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := user.HashPassword(hasher); err != nil {
+		return User{}, nil, Wrap("user.HashPassword", err)
+	}
+
+	posts := []postFixtureModel{}
+	for _, postFixture := range fixture.Posts {
+		status := postFixture.Status
+		if status == "" {
+			status = PostStatusPublished
+		}
+		if !IsValidPostStatus(status) || (status == PostStatusScheduled) != (postFixture.PublishAt != nil) {
+			return User{}, nil, Wrap(fmt.Sprintf("post %q", postFixture.Title), ErrInvalidValue("status"))
+		}
+
+		tags, err := NormalizeTags(postFixture.Tags)
+		if err != nil {
+			return User{}, nil, Wrap(fmt.Sprintf("post %q: NormalizeTags", postFixture.Title), err)
+		}
+
+		userPost := UserPost{Title: postFixture.Title, Body: postFixture.Body}
+		userPost.SetStatus(status, postFixture.PublishAt)
+		posts = append(posts, postFixtureModel{userPost: userPost, tags: tags})
+	}
+
+	return user, posts, nil
+}
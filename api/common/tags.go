@@ -0,0 +1,48 @@
+package common
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Tags are stored lowercased and trimmed. They go in URLs, so only letters, numbers and dashes are allowed.
+
+const (
+	MaxTagsPerPost = 5
+	tagMaxLength   = 32
+)
+
+var validTagRegex = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// NormalizeTag returns the tag as it's stored, or an error if it isn't valid
+func NormalizeTag(tag string) (string, error) {
+	normalized := strings.ToLower(strings.TrimSpace(tag))
+	if len(normalized) > tagMaxLength || !validTagRegex.MatchString(normalized) {
+		return "", ErrInvalidTag(tagMaxLength)
+	}
+	return normalized, nil
+}
+
+// NormalizeTags also removes duplicates, keeping the order
+func NormalizeTags(tags []string) ([]string, error) {
+	var (
+		normalizedTags = []string{}
+		seen           = map[string]bool{}
+	)
+	for _, tag := range tags {
+		normalized, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[normalized] {
+			seen[normalized] = true
+			normalizedTags = append(normalizedTags, normalized)
+		}
+	}
+
+	if len(normalizedTags) > MaxTagsPerPost {
+		return nil, ErrTooManyTags(MaxTagsPerPost)
+	}
+
+	return normalizedTags, nil
+}
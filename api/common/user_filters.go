@@ -0,0 +1,154 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// User filters come as "field:operator:value,field:operator:value", e.g. "email:eq:x@y.com,created_at:gte:2024-01-01".
+// Values can't have commas. Only the fields and operators listed here are allowed.
+
+type UserFilter struct {
+	Field    string
+	Operator string
+	Value    interface{}
+}
+
+type userFilterField struct {
+	operators []string
+	parse     func(value string) (interface{}, error)
+}
+
+var (
+	stringOperators = []string{"eq", "ne", "like"}
+	boolOperators   = []string{"eq"}
+	timeOperators   = []string{"eq", "gt", "gte", "lt", "lte"}
+
+	userFilterFields = map[string]userFilterField{
+		"username":   {stringOperators, parseFilterString},
+		"email":      {stringOperators, parseFilterString},
+		"first_name": {stringOperators, parseFilterString},
+		"last_name":  {stringOperators, parseFilterString},
+		"is_admin":   {boolOperators, parseFilterBool},
+		"deleted":    {boolOperators, parseFilterBool},
+		"created_at": {timeOperators, parseFilterTime},
+		"updated_at": {timeOperators, parseFilterTime},
+	}
+
+	filterConditionsSQL = map[string]string{"eq": "= ?", "ne": "<> ?", "like": "LIKE ? ESCAPE '!'", "gt": "> ?", "gte": ">= ?", "lt": "< ?", "lte": "<= ?"}
+
+	// likeEscaper makes the wildcards of a LIKE value match themselves. The escape character isn't a backslash,
+	// as MySQL and PostgreSQL would read it as an escape too, while SQLite wouldn't.
+	likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+)
+
+func ParseUserFilters(raw string) ([]UserFilter, error) {
+	filters := []UserFilter{}
+	if raw == "" {
+		return filters, nil
+	}
+
+	for _, part := range strings.Split(raw, ",") {
+		pieces := strings.SplitN(part, ":", 3)
+		if len(pieces) != 3 {
+			return nil, ErrInvalidValue("filter")
+		}
+
+		field, operator, value := pieces[0], pieces[1], pieces[2]
+		filterField, ok := userFilterFields[field]
+		if !ok {
+			return nil, ErrInvalidValue("filter." + field)
+		}
+
+		if !containsString(filterField.operators, operator) {
+			return nil, ErrInvalidValue("filter." + field)
+		}
+
+		parsedValue, err := filterField.parse(value)
+		if err != nil {
+			return nil, ErrInvalidValue("filter." + field)
+		}
+
+		filters = append(filters, UserFilter{Field: field, Operator: operator, Value: parsedValue})
+	}
+
+	return filters, nil
+}
+
+// ApplyUserFilters adds a parameterized WHERE for each filter. Field and operator names come from the allowlist, never from the user.
+func ApplyUserFilters(db *gorm.DB, filters []UserFilter) *gorm.DB {
+	for _, filter := range filters {
+		condition := filterConditionsSQL[filter.Operator]
+		value := filter.Value
+		if filter.Operator == "like" {
+			value = EscapeLike(fmt.Sprint(value))
+		}
+
+		switch filter.Field {
+		case "first_name", "last_name":
+			db = db.Where(fmt.Sprintf("id IN (SELECT user_id FROM user_details WHERE %s %s)", filter.Field, condition), value)
+		case "is_admin":
+			subquery := "SELECT user_roles.user_id FROM user_roles JOIN roles ON roles.id = user_roles.role_id WHERE roles.name = ?"
+			if value == true {
+				db = db.Where("id IN ("+subquery+")", AdminRole)
+			} else {
+				db = db.Where("id NOT IN ("+subquery+")", AdminRole)
+			}
+		default:
+			db = db.Where(fmt.Sprintf("%s %s", filter.Field, condition), value)
+		}
+	}
+	return db
+}
+
+// EscapeLike returns the pattern of a LIKE ? ESCAPE '!' that has value anywhere, taken literally
+func EscapeLike(value string) string {
+	return "%" + likeEscaper.Replace(value) + "%"
+}
+
+// EscapeLikePrefix is like EscapeLike, but value has to be at the start
+func EscapeLikePrefix(value string) string {
+	return likeEscaper.Replace(value) + "%"
+}
+
+// HasUserFilter is true if any of the filters is on the field
+func HasUserFilter(filters []UserFilter, field string) bool {
+	for _, filter := range filters {
+		if filter.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+func parseFilterString(value string) (interface{}, error) {
+	if value == "" {
+		return nil, fmt.Errorf("empty value")
+	}
+	return value, nil
+}
+
+func parseFilterBool(value string) (interface{}, error) {
+	return strconv.ParseBool(value)
+}
+
+// parseFilterTime accepts dates or full RFC 3339 timestamps
+func parseFilterTime(value string) (interface{}, error) {
+	if date, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return date, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package common
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// UserPurger hard deletes the users that were soft deleted more than the grace period ago.
+// After that their username and email can be used again. It also removes the files of expired data exports.
+type UserPurger struct {
+	config Deletion
+	repos  Repositories
+	logger *logrus.Logger
+}
+
+func NewUserPurger(config Deletion, repos Repositories, logger *logrus.Logger) *UserPurger {
+	return &UserPurger{config: config, repos: repos, logger: logger}
+}
+
+// Run purges once and then every PurgeIntervalMinutes. It never returns, call it on a goroutine.
+func (p *UserPurger) Run() {
+	ticker := time.NewTicker(time.Minute * time.Duration(p.config.PurgeIntervalMinutes))
+	defer ticker.Stop()
+
+	for {
+		if purged, err := p.Purge(); err != nil {
+			p.logger.WithField("purged", purged).Error("User Purger: " + err.Error())
+		} else if purged > 0 {
+			p.logger.WithField("purged", purged).Info("User Purger: users purged")
+		}
+		<-ticker.C
+	}
+}
+
+// Purge returns how many users were hard deleted
+func (p *UserPurger) Purge() (int, error) {
+
+	// Expired data exports. They're just files, failing to remove them doesn't stop the purge
+	if err := p.removeExpiredDataExports(); err != nil {
+		p.logger.Error("User Purger: " + err.Error())
+	}
+
+	// Users deleted before we kept track of when start their grace period now
+	if err := p.repos.Users.StartMissingGracePeriods(); err != nil {
+		return 0, Wrap("Purge: repos.Users.StartMissingGracePeriods", err)
+	}
+
+	deletedBefore := time.Now().Add(-time.Hour * 24 * time.Duration(p.config.GracePeriodDays))
+	userIDs, err := p.repos.Users.ListDeletedBefore(deletedBefore)
+	if err != nil {
+		return 0, Wrap("Purge: repos.Users.ListDeletedBefore", err)
+	}
+
+	for i, userID := range userIDs {
+		exportFilePaths, err := p.repos.Users.HardDelete(userID)
+		if err != nil {
+			return i, Wrap("Purge: repos.Users.HardDelete", err)
+		}
+
+		// The user is already gone, a file that can't be removed doesn't stop the purge
+		if err := RemoveDataExportFiles(exportFilePaths); err != nil {
+			p.logger.WithField("user_id", userID).Error("User Purger: " + err.Error())
+		}
+	}
+
+	return len(userIDs), nil
+}
+
+// removeExpiredDataExports removes the files but keeps the rows, so downloading them says they expired
+func (p *UserPurger) removeExpiredDataExports() error {
+	exports, err := p.repos.Exports.ListExpired(time.Now())
+	if err != nil {
+		return Wrap("removeExpiredDataExports: repos.Exports.ListExpired", err)
+	}
+
+	for _, export := range exports {
+		if err := RemoveDataExportFiles([]string{export.FilePath}); err != nil {
+			return Wrap("removeExpiredDataExports: RemoveDataExportFiles", err)
+		}
+		if err := p.repos.Exports.UpdateFields(export.ID, map[string]interface{}{"file_path": ""}); err != nil {
+			return Wrap("removeExpiredDataExports: repos.Exports.UpdateFields", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package endpoints
+
+import (
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) AdminDeleteUser(c *gin.Context) {
+	HandleRequest(c, h.makeAdminDeleteUserRequest, h.adminDeleteUser)
+}
+
+func (h *handler) makeAdminDeleteUserRequest(c *gin.Context) (req common.AdminDeleteUserRequest, err error) {
+	req.UserID = getIntFromPath(c, pathUserIDKey)
+	if req.UserID == 0 {
+		return common.AdminDeleteUserRequest{}, common.ErrInvalidValue(pathUserIDKey)
+	}
+
+	return req, nil
+}
+
+// adminDeleteUser soft deletes the user, just like when they delete themselves
+func (h *handler) adminDeleteUser(c *gin.Context, request common.AdminDeleteUserRequest) (common.AdminDeleteUserResponse, error) {
+	if err := h.checkCanManageUser(c, request.UserID); err != nil {
+		return common.AdminDeleteUserResponse{}, common.Wrap("adminDeleteUser: checkCanManageUser", err)
+	}
+
+	response, err := h.deleteUser(c, common.DeleteUserRequest{UserID: request.UserID})
+	if err != nil {
+		return common.AdminDeleteUserResponse{}, common.Wrap("adminDeleteUser: deleteUser", err)
+	}
+
+	return common.AdminDeleteUserResponse{User: response.User}, nil
+}
@@ -0,0 +1,32 @@
+package endpoints
+
+import (
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) AdminGetUser(c *gin.Context) {
+	HandleRequest(c, h.makeAdminGetUserRequest, h.adminGetUser)
+}
+
+func (h *handler) makeAdminGetUserRequest(c *gin.Context) (req common.AdminGetUserRequest, err error) {
+	req.UserID = getIntFromPath(c, pathUserIDKey)
+	if req.UserID == 0 {
+		return common.AdminGetUserRequest{}, common.ErrInvalidValue(pathUserIDKey)
+	}
+
+	return req, nil
+}
+
+// adminGetUser is like getUser, but deleted users are also returned
+func (h *handler) adminGetUser(c *gin.Context, request common.AdminGetUserRequest) (common.AdminGetUserResponse, error) {
+
+	// Get user
+	user, err := h.repos.Users.GetWithDetails(request.UserID)
+	if err != nil {
+		return common.AdminGetUserResponse{}, common.Wrap("adminGetUser: repos.Users.GetWithDetails", err)
+	}
+
+	return common.AdminGetUserResponse{User: user.ToResponseModel()}, nil
+}
@@ -0,0 +1,48 @@
+package endpoints
+
+import (
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) AdminHardDeleteUser(c *gin.Context) {
+	HandleRequest(c, h.makeAdminHardDeleteUserRequest, h.adminHardDeleteUser)
+}
+
+func (h *handler) makeAdminHardDeleteUserRequest(c *gin.Context) (req common.AdminHardDeleteUserRequest, err error) {
+	req.UserID = getIntFromPath(c, pathUserIDKey)
+	if req.UserID == 0 {
+		return common.AdminHardDeleteUserRequest{}, common.ErrInvalidValue(pathUserIDKey)
+	}
+
+	return req, nil
+}
+
+// adminHardDeleteUser removes the user and all of their data. There's no going back.
+func (h *handler) adminHardDeleteUser(c *gin.Context, request common.AdminHardDeleteUserRequest) (common.AdminHardDeleteUserResponse, error) {
+
+	// Check we can manage them
+	if err := h.checkCanManageUser(c, request.UserID); err != nil {
+		return common.AdminHardDeleteUserResponse{}, common.Wrap("adminHardDeleteUser: checkCanManageUser", err)
+	}
+
+	// Get user, also to return it
+	response, err := h.adminGetUser(c, common.AdminGetUserRequest{UserID: request.UserID})
+	if err != nil {
+		return common.AdminHardDeleteUserResponse{}, common.Wrap("adminHardDeleteUser: adminGetUser", err)
+	}
+
+	// Delete everything
+	exportFilePaths, err := h.repos.Users.HardDelete(request.UserID)
+	if err != nil {
+		return common.AdminHardDeleteUserResponse{}, common.Wrap("adminHardDeleteUser: repos.Users.HardDelete", err)
+	}
+
+	// Only now that the rows are gone, their files go too. The user is already deleted if this fails
+	if err := common.RemoveDataExportFiles(exportFilePaths); err != nil {
+		h.logger.WithField("user_id", request.UserID).Error("adminHardDeleteUser: common.RemoveDataExportFiles: " + err.Error())
+	}
+
+	return common.AdminHardDeleteUserResponse{User: response.User}, nil
+}
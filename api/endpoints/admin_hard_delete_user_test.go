@@ -0,0 +1,43 @@
+package endpoints
+
+import (
+	"os"
+	"testing"
+
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminHardDeleteUser(t *testing.T) {
+	h, _ := newTestHandler(t)
+	alice := signupTestUser(t, h, "alice", "alice@example.com")
+	bob := signupTestUser(t, h, "bob", "bob@example.com")
+
+	post, err := h.createUserPost(newTestContext(alice.ID), common.CreateUserPostRequest{UserID: alice.ID, Title: "Hello", Body: "World"})
+	require.NoError(t, err)
+	_, err = h.createPostComment(newTestContext(bob.ID), common.CreatePostCommentRequest{UserID: bob.ID, PostID: post.UserPost.ID, Body: "Hi"})
+	require.NoError(t, err)
+
+	export := common.DataExport{UserID: alice.ID, Status: common.DataExportPending}
+	require.NoError(t, h.repos.Exports.Create(&export))
+	data, err := h.repos.Exports.CollectUserData(export)
+	require.NoError(t, err)
+	filePath, err := common.WriteUserDataExport(h.config.Exports.Path, export, data)
+	require.NoError(t, err)
+	require.NoError(t, h.repos.Exports.UpdateFields(export.ID, map[string]interface{}{"status": common.DataExportReady, "file_path": filePath}))
+
+	_, err = h.adminHardDeleteUser(newTestContext(0), common.AdminHardDeleteUserRequest{UserID: alice.ID})
+	require.NoError(t, err)
+
+	_, err = h.repos.Users.Get(alice.ID)
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+	_, err = h.repos.Posts.GetPublic(post.UserPost.ID)
+	assert.ErrorIs(t, err, common.ErrUserPostNotFound)
+	_, err = os.Stat(filePath)
+	assert.True(t, os.IsNotExist(err))
+
+	// The username can be used again
+	signupTestUser(t, h, "alice", "alice@example.com")
+}
@@ -0,0 +1,45 @@
+package endpoints
+
+import (
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) AdminRestoreUser(c *gin.Context) {
+	HandleRequest(c, h.makeAdminRestoreUserRequest, h.adminRestoreUser)
+}
+
+func (h *handler) makeAdminRestoreUserRequest(c *gin.Context) (req common.AdminRestoreUserRequest, err error) {
+	req.UserID = getIntFromPath(c, pathUserIDKey)
+	if req.UserID == 0 {
+		return common.AdminRestoreUserRequest{}, common.ErrInvalidValue(pathUserIDKey)
+	}
+
+	return req, nil
+}
+
+func (h *handler) adminRestoreUser(c *gin.Context, request common.AdminRestoreUserRequest) (common.AdminRestoreUserResponse, error) {
+
+	// Check we can manage them
+	if err := h.checkCanManageUser(c, request.UserID); err != nil {
+		return common.AdminRestoreUserResponse{}, common.Wrap("adminRestoreUser: checkCanManageUser", err)
+	}
+
+	// Get user
+	user, err := h.repos.Users.Get(request.UserID)
+	if err != nil {
+		return common.AdminRestoreUserResponse{}, common.Wrap("adminRestoreUser: repos.Users.Get", err)
+	}
+
+	if !user.Deleted {
+		return common.AdminRestoreUserResponse{}, common.Wrap("adminRestoreUser: !user.Deleted", common.ErrUserNotDeleted)
+	}
+
+	// Restore user
+	if err := h.repos.Users.Restore(&user); err != nil {
+		return common.AdminRestoreUserResponse{}, common.Wrap("adminRestoreUser: repos.Users.Restore", err)
+	}
+
+	return common.AdminRestoreUserResponse{User: user.ToResponseModel()}, nil
+}
@@ -0,0 +1,108 @@
+package endpoints
+
+import (
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) AdminUpdateUser(c *gin.Context) {
+	HandleRequest(c, h.makeAdminUpdateUserRequest, h.adminUpdateUser)
+}
+
+func (h *handler) makeAdminUpdateUserRequest(c *gin.Context) (req common.AdminUpdateUserRequest, err error) {
+
+	if err = c.ShouldBindJSON(&req); err != nil {
+		return common.AdminUpdateUserRequest{}, common.Wrap(err.Error(), common.ErrBindingRequest)
+	}
+
+	req.UserID = getIntFromPath(c, pathUserIDKey)
+	if req.UserID == 0 {
+		return common.AdminUpdateUserRequest{}, common.ErrInvalidValue(pathUserIDKey)
+	}
+
+	if req.Email == "" && req.Username == "" && req.FirstName == nil && req.LastName == nil && req.IsAdmin == nil && req.Password == nil {
+		return common.AdminUpdateUserRequest{}, common.ErrAllFieldsRequired
+	}
+
+	if err = validateOptionalUsernameAndEmail(req.Username, req.Email); err != nil {
+		return common.AdminUpdateUserRequest{}, common.Wrap("makeAdminUpdateUserRequest", err)
+	}
+
+	if req.Password != nil {
+		if err = validatePassword(*req.Password); err != nil {
+			return common.AdminUpdateUserRequest{}, common.Wrap("makeAdminUpdateUserRequest", err)
+		}
+	}
+
+	if req.IsAdmin != nil && !hasPermission(c, common.PermissionManageAdmins) {
+		return common.AdminUpdateUserRequest{}, common.Wrap("makeAdminUpdateUserRequest: !hasPermission", common.ErrForbidden)
+	}
+
+	return req, nil
+}
+
+// adminUpdateUser goes through the same logic as the user's own updates, role assignments and password changes.
+// All of the changes are saved, or none of them.
+func (h *handler) adminUpdateUser(c *gin.Context, request common.AdminUpdateUserRequest) (common.AdminUpdateUserResponse, error) {
+
+	// Check user exists, and that we can manage them
+	if err := h.checkCanManageUser(c, request.UserID); err != nil {
+		return common.AdminUpdateUserResponse{}, common.Wrap("adminUpdateUser: checkCanManageUser", err)
+	}
+
+	err := h.repos.UnitOfWork.Do(func(repos common.Repositories) error {
+		txHandler := h.withRepos(repos)
+
+		// Username, email & details
+		updateUserRequest := request.ToUpdateUserRequest()
+		if updateUserRequest.Username != "" || updateUserRequest.Email != "" || updateUserRequest.FirstName != nil || updateUserRequest.LastName != nil {
+			if _, err := txHandler.updateUser(c, updateUserRequest); err != nil {
+				return common.Wrap("updateUser", err)
+			}
+		}
+
+		// Password
+		if request.Password != nil {
+			user := common.User{ID: request.UserID, Password: *request.Password}
+			if err := user.HashPassword(h.hasher); err != nil {
+				return common.Wrap("user.HashPassword", err)
+			}
+			if err := repos.Users.UpdatePassword(user.ID, user.Password); err != nil {
+				return common.Wrap("repos.Users.UpdatePassword", err)
+			}
+		}
+
+		// Admin role
+		if request.IsAdmin != nil {
+			var err error
+			if *request.IsAdmin {
+				_, err = txHandler.assignUserRole(c, common.AssignUserRoleRequest{UserID: request.UserID, RoleName: common.AdminRole})
+			} else {
+				_, err = txHandler.unassignUserRole(c, common.UnassignUserRoleRequest{UserID: request.UserID, RoleName: common.AdminRole})
+			}
+			if err != nil {
+				return common.Wrap("admin role", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return common.AdminUpdateUserResponse{}, common.Wrap("adminUpdateUser: repos.UnitOfWork.Do", err)
+	}
+
+	// With a new password, their sessions are revoked
+	if request.Password != nil {
+		if err := h.auth.RevokeAllUserTokens(request.UserID); err != nil {
+			return common.AdminUpdateUserResponse{}, common.Wrap("adminUpdateUser: auth.RevokeAllUserTokens", err)
+		}
+	}
+
+	// Return the updated user
+	response, err := h.adminGetUser(c, common.AdminGetUserRequest{UserID: request.UserID})
+	if err != nil {
+		return common.AdminUpdateUserResponse{}, common.Wrap("adminUpdateUser: adminGetUser", err)
+	}
+
+	return common.AdminUpdateUserResponse{User: response.User}, nil
+}
@@ -0,0 +1,37 @@
+package endpoints
+
+import (
+	"testing"
+
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Only those who can manage admins can change an admin, e.g. set their password
+func TestAdminUpdateUser_AdminNeedsManageAdmins(t *testing.T) {
+	h, _ := newTestHandler(t)
+	admin := signupTestUser(t, h, "alice", "alice@example.com")
+
+	adminRole, err := h.repos.Roles.GetByName(common.AdminRole)
+	require.NoError(t, err)
+	require.NoError(t, h.repos.Users.AddRoles(&common.User{ID: admin.ID}, []common.Role{adminRole}))
+
+	password := "taken-over"
+	c := newTestContext(0)
+	c.Set(contextClaimsKey, &common.CustomClaims{Permissions: []string{common.PermissionManageUsers}})
+	_, err = h.adminUpdateUser(c, common.AdminUpdateUserRequest{UserID: admin.ID, Password: &password})
+	assert.ErrorIs(t, err, common.ErrForbidden)
+
+	_, err = h.adminDeleteUser(c, common.AdminDeleteUserRequest{UserID: admin.ID})
+	assert.ErrorIs(t, err, common.ErrForbidden)
+
+	_, err = h.adminHardDeleteUser(c, common.AdminHardDeleteUserRequest{UserID: admin.ID})
+	assert.ErrorIs(t, err, common.ErrForbidden)
+
+	c.Set(contextClaimsKey, &common.CustomClaims{Permissions: []string{common.PermissionManageUsers, common.PermissionManageAdmins}})
+	response, err := h.adminUpdateUser(c, common.AdminUpdateUserRequest{UserID: admin.ID, Username: "alice2"})
+	require.NoError(t, err)
+	assert.Equal(t, "alice2", response.User.Username)
+}
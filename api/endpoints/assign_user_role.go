@@ -0,0 +1,53 @@
+package endpoints
+
+import (
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) AssignUserRole(c *gin.Context) {
+	HandleRequest(c, h.makeAssignUserRoleRequest, h.assignUserRole)
+}
+
+func (h *handler) makeAssignUserRoleRequest(c *gin.Context) (req common.AssignUserRoleRequest, err error) {
+	req.UserID = getIntFromPath(c, pathUserIDKey)
+	req.RoleName = common.RoleName(c.Param(pathRoleNameKey))
+	if req.UserID == 0 || req.RoleName == "" {
+		return common.AssignUserRoleRequest{}, common.ErrAllFieldsRequired
+	}
+
+	if req.RoleName == common.AdminRole && !hasPermission(c, common.PermissionManageAdmins) {
+		return common.AssignUserRoleRequest{}, common.Wrap("makeAssignUserRoleRequest: !hasPermission", common.ErrForbidden)
+	}
+
+	return req, nil
+}
+
+// assignUserRole is idempotent. The user gets the new permissions when their token is refreshed.
+func (h *handler) assignUserRole(c *gin.Context, request common.AssignUserRoleRequest) (common.AssignUserRoleResponse, error) {
+
+	// Get user
+	user, err := h.repos.Users.Get(request.UserID)
+	if err != nil {
+		return common.AssignUserRoleResponse{}, common.Wrap("assignUserRole: repos.Users.Get", err)
+	}
+
+	// Get role
+	roles, err := h.getRolesByName(request.RoleName)
+	if err != nil {
+		return common.AssignUserRoleResponse{}, common.Wrap("assignUserRole: getRolesByName", err)
+	}
+
+	// Check we have all of its permissions
+	if err := checkCanHandOutRoles(c, roles); err != nil {
+		return common.AssignUserRoleResponse{}, common.Wrap("assignUserRole: checkCanHandOutRoles", err)
+	}
+
+	// Assign it
+	if err := h.repos.Users.AddRoles(&user, roles); err != nil {
+		return common.AssignUserRoleResponse{}, common.Wrap("assignUserRole: repos.Users.AddRoles", err)
+	}
+
+	return common.AssignUserRoleResponse{User: user.ToResponseModel()}, nil
+}
@@ -0,0 +1,32 @@
+package endpoints
+
+import (
+	"testing"
+
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A role can't be used to hand out permissions the caller doesn't have, e.g. to themselves
+func TestAssignUserRole_PermissionsTheCallerDoesntHave(t *testing.T) {
+	h, _ := newTestHandler(t)
+	alice := signupTestUser(t, h, "alice", "alice@example.com")
+
+	c := newTestContext(alice.ID)
+	c.Set(contextClaimsKey, &common.CustomClaims{Permissions: []string{common.PermissionManageRoles, common.PermissionManageUsers}})
+	_, err := h.createRole(c, common.CreateRoleRequest{Name: "support", Permissions: []string{common.PermissionManageUsers}})
+	require.NoError(t, err)
+
+	c.Set(contextClaimsKey, &common.CustomClaims{Permissions: []string{common.PermissionManageRoles}})
+	_, err = h.assignUserRole(c, common.AssignUserRoleRequest{UserID: alice.ID, RoleName: "support"})
+	assert.ErrorIs(t, err, common.ErrForbidden)
+	_, err = h.unassignUserRole(c, common.UnassignUserRoleRequest{UserID: alice.ID, RoleName: "support"})
+	assert.ErrorIs(t, err, common.ErrForbidden)
+
+	c.Set(contextClaimsKey, &common.CustomClaims{Permissions: []string{common.PermissionManageRoles, common.PermissionManageUsers}})
+	response, err := h.assignUserRole(c, common.AssignUserRoleRequest{UserID: alice.ID, RoleName: "support"})
+	require.NoError(t, err)
+	assert.Contains(t, response.User.Roles, common.RoleName("support"))
+}
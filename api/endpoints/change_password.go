@@ -1,12 +1,9 @@
 package endpoints
 
 import (
-	"errors"
-
 	"github.com/gilperopiola/go-rest-example-small/api/common"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 func (h *handler) ChangePassword(c *gin.Context) {
@@ -24,16 +21,12 @@ func (h *handler) makeChangePasswordRequest(c *gin.Context) (req common.ChangePa
 		return common.ChangePasswordRequest{}, common.ErrAllFieldsRequired
 	}
 
-	if req.OldPassword == "" || req.NewPassword == "" || req.RepeatPassword == "" {
+	if req.OldPassword == "" {
 		return common.ChangePasswordRequest{}, common.ErrAllFieldsRequired
 	}
 
-	if len(req.NewPassword) < passwordMinLength || len(req.NewPassword) > passwordMaxLength {
-		return common.ChangePasswordRequest{}, common.ErrInvalidPasswordLength(passwordMinLength, passwordMaxLength)
-	}
-
-	if req.NewPassword != req.RepeatPassword {
-		return common.ChangePasswordRequest{}, common.ErrPasswordsDontMatch
+	if err = validateNewPassword(req.NewPassword, req.RepeatPassword); err != nil {
+		return common.ChangePasswordRequest{}, common.Wrap("makeChangePasswordRequest", err)
 	}
 
 	return req, nil
@@ -43,25 +36,25 @@ func (h *handler) changePassword(c *gin.Context, request common.ChangePasswordRe
 	user := request.ToUserModel()
 
 	// Get user
-	query := "(id = ? OR username = ? OR email = ?) AND deleted = false"
-	if err := h.db.Where(query, user.ID, user.Username, user.Email).First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return common.ChangePasswordResponse{}, common.Wrap(err.Error(), common.ErrUserNotFound)
-		}
-		return common.ChangePasswordResponse{}, common.Wrap(err.Error(), common.ErrGettingUser)
+	user, err := h.repos.Users.GetActiveByID(user.ID)
+	if err != nil {
+		return common.ChangePasswordResponse{}, common.Wrap("changePassword: repos.Users.GetActiveByID", err)
 	}
 
 	// Check if old password matches
-	if user.Password != common.Hash(request.OldPassword, h.config.HashSalt) {
-		return common.ChangePasswordResponse{}, common.Wrap("changePassword: user.Password != common.Hash", common.ErrWrongPassword)
+	if matches, _ := user.PasswordMatches(request.OldPassword, h.hasher); !matches {
+		return common.ChangePasswordResponse{}, common.Wrap("changePassword: !user.PasswordMatches", common.ErrWrongPassword)
 	}
 
 	// Generate new hashed password
-	newPassword := common.Hash(request.NewPassword, h.config.HashSalt)
+	user.Password = request.NewPassword
+	if err := user.HashPassword(h.hasher); err != nil {
+		return common.ChangePasswordResponse{}, common.Wrap("changePassword: user.HashPassword", err)
+	}
 
 	// Update password
-	if err := h.db.Model(&common.User{}).Where("id = ?", user.ID).Update("password", newPassword).Error; err != nil {
-		return common.ChangePasswordResponse{}, common.Wrap(err.Error(), common.ErrUpdatingUser)
+	if err := h.repos.Users.UpdatePassword(user.ID, user.Password); err != nil {
+		return common.ChangePasswordResponse{}, common.Wrap("changePassword: repos.Users.UpdatePassword", err)
 	}
 
 	return common.ChangePasswordResponse{User: user.ToResponseModel()}, nil
@@ -0,0 +1,40 @@
+package endpoints
+
+import (
+	"testing"
+
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangePassword(t *testing.T) {
+	h, _ := newTestHandler(t)
+	alice := signupTestUser(t, h, "alice", "alice@example.com")
+	bob := signupTestUser(t, h, "bob", "bob@example.com")
+
+	request := common.ChangePasswordRequest{UserID: bob.ID, OldPassword: "bob-password", NewPassword: "new-password", RepeatPassword: "new-password"}
+	_, err := h.changePassword(newTestContext(bob.ID), request)
+	require.NoError(t, err)
+
+	stored, err := h.repos.Users.Get(bob.ID)
+	require.NoError(t, err)
+	matches, _ := stored.PasswordMatches("new-password", h.hasher)
+	assert.True(t, matches)
+
+	// Nobody else's password changes
+	stored, err = h.repos.Users.Get(alice.ID)
+	require.NoError(t, err)
+	matches, _ = stored.PasswordMatches("alice-password", h.hasher)
+	assert.True(t, matches)
+}
+
+func TestChangePassword_WrongOldPassword(t *testing.T) {
+	h, _ := newTestHandler(t)
+	user := signupTestUser(t, h, "alice", "alice@example.com")
+
+	request := common.ChangePasswordRequest{UserID: user.ID, OldPassword: "wrong-password", NewPassword: "new-password", RepeatPassword: "new-password"}
+	_, err := h.changePassword(newTestContext(user.ID), request)
+	assert.ErrorIs(t, err, common.ErrWrongPassword)
+}
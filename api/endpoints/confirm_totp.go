@@ -0,0 +1,105 @@
+package endpoints
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) ConfirmTOTP(c *gin.Context) {
+	HandleRequest(c, h.makeConfirmTOTPRequest, h.confirmTOTP)
+}
+
+func (h *handler) makeConfirmTOTPRequest(c *gin.Context) (req common.ConfirmTOTPRequest, err error) {
+
+	if err = c.ShouldBindJSON(&req); err != nil {
+		return common.ConfirmTOTPRequest{}, common.Wrap(err.Error(), common.ErrBindingRequest)
+	}
+
+	req.UserID = c.GetInt(contextUserIDKey)
+	if req.UserID == 0 || req.Code == "" {
+		return common.ConfirmTOTPRequest{}, common.ErrAllFieldsRequired
+	}
+
+	return req, nil
+}
+
+func (h *handler) confirmTOTP(c *gin.Context, request common.ConfirmTOTPRequest) (common.ConfirmTOTPResponse, error) {
+
+	// Get user
+	user, err := h.repos.Users.GetActive(request.UserID)
+	if err != nil {
+		return common.ConfirmTOTPResponse{}, common.Wrap("confirmTOTP: repos.Users.GetActive", err)
+	}
+
+	if user.TOTPEnabled {
+		return common.ConfirmTOTPResponse{}, common.Wrap("confirmTOTP: user.TOTPEnabled", common.ErrTOTPAlreadyEnabled)
+	}
+	if user.TOTPSecret == "" {
+		return common.ConfirmTOTPResponse{}, common.Wrap("confirmTOTP: user.TOTPSecret == \"\"", common.ErrTOTPNotEnrolled)
+	}
+
+	// Codes are short, so they're throttled just like on login
+	userKey := common.UserThrottleKey(user.ID)
+	if err := h.checkLoginThrottle(c, userKey); err != nil {
+		return common.ConfirmTOTPResponse{}, common.Wrap("confirmTOTP: checkLoginThrottle", err)
+	}
+
+	// Check code. Recovery codes don't count here
+	step, ok := common.ValidateTOTPCode(user.TOTPSecret, strings.TrimSpace(request.Code), time.Now())
+	if !ok {
+		if err := h.loginThrottler.RegisterFailure(userKey); err != nil {
+			return common.ConfirmTOTPResponse{}, common.Wrap("confirmTOTP: loginThrottler.RegisterFailure", err)
+		}
+		return common.ConfirmTOTPResponse{}, common.Wrap("confirmTOTP: !common.ValidateTOTPCode", common.ErrInvalidMFACode)
+	}
+
+	if err := h.loginThrottler.Reset(userKey); err != nil {
+		return common.ConfirmTOTPResponse{}, common.Wrap("confirmTOTP: loginThrottler.Reset", err)
+	}
+
+	// Enable 2FA and generate recovery codes, replacing any old ones
+	var recoveryCodes []string
+	err = h.repos.UnitOfWork.Do(func(repos common.Repositories) error {
+		updates := map[string]interface{}{"totp_enabled": true, "totp_last_used_step": step}
+		if err := repos.Users.UpdateFields(&user, updates); err != nil {
+			return common.Wrap("repos.Users.UpdateFields", err)
+		}
+
+		var err error
+		if recoveryCodes, err = replaceRecoveryCodes(repos, user.ID); err != nil {
+			return common.Wrap("replaceRecoveryCodes", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return common.ConfirmTOTPResponse{}, common.Wrap("confirmTOTP: repos.UnitOfWork.Do", err)
+	}
+
+	return common.ConfirmTOTPResponse{RecoveryCodes: recoveryCodes}, nil
+}
+
+/*-----------------------
+//       HELPERS
+//---------------------*/
+
+func replaceRecoveryCodes(repos common.Repositories, userID int) ([]string, error) {
+	codes, err := common.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, common.Wrap(err.Error(), common.ErrCreatingRecoveryCodes)
+	}
+
+	recoveryCodes := []common.RecoveryCode{}
+	for _, code := range codes {
+		recoveryCodes = append(recoveryCodes, common.RecoveryCode{UserID: userID, CodeHash: common.HashRecoveryCode(code)})
+	}
+
+	if err := repos.Tokens.ReplaceRecoveryCodes(userID, recoveryCodes); err != nil {
+		return nil, common.Wrap("replaceRecoveryCodes: repos.Tokens.ReplaceRecoveryCodes", err)
+	}
+
+	return codes, nil
+}
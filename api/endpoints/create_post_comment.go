@@ -0,0 +1,72 @@
+package endpoints
+
+import (
+	"errors"
+
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) CreatePostComment(c *gin.Context) {
+	HandleRequest(c, h.makeCreatePostCommentRequest, h.createPostComment)
+}
+
+func (h *handler) makeCreatePostCommentRequest(c *gin.Context) (req common.CreatePostCommentRequest, err error) {
+
+	if err = c.ShouldBindJSON(&req); err != nil {
+		return common.CreatePostCommentRequest{}, common.Wrap(err.Error(), common.ErrBindingRequest)
+	}
+
+	req.UserID = c.GetInt(contextUserIDKey)
+	if req.UserID == 0 {
+		return common.CreatePostCommentRequest{}, common.ErrAllFieldsRequired
+	}
+
+	req.PostID = getIntFromPath(c, pathPostIDKey)
+	if req.PostID == 0 {
+		return common.CreatePostCommentRequest{}, common.ErrInvalidValue(pathPostIDKey)
+	}
+
+	if req.Body == "" || len(req.Body) > commentMaxLength {
+		return common.CreatePostCommentRequest{}, common.ErrInvalidCommentLength(commentMaxLength)
+	}
+
+	return req, nil
+}
+
+func (h *handler) createPostComment(c *gin.Context, request common.CreatePostCommentRequest) (common.CreatePostCommentResponse, error) {
+
+	// Check the post is visible
+	if _, err := h.getPost(c, common.GetPostRequest{PostID: request.PostID}); err != nil {
+		return common.CreatePostCommentResponse{}, common.Wrap("createPostComment: getPost", err)
+	}
+
+	// Replies only go to top-level comments of the same post
+	if request.ParentID != nil {
+		parent, err := h.repos.Comments.Get(request.PostID, *request.ParentID)
+		if err != nil {
+			if errors.Is(err, common.ErrPostCommentNotFound) {
+				return common.CreatePostCommentResponse{}, common.Wrap("createPostComment: repos.Comments.Get", common.ErrInvalidParentComment)
+			}
+			return common.CreatePostCommentResponse{}, common.Wrap("createPostComment: repos.Comments.Get", err)
+		}
+		if parent.ParentID != nil {
+			return common.CreatePostCommentResponse{}, common.Wrap("createPostComment: parent.ParentID != nil", common.ErrInvalidParentComment)
+		}
+	}
+
+	// Create comment
+	comment := request.ToPostCommentModel()
+	if err := h.repos.Comments.Create(&comment); err != nil {
+		return common.CreatePostCommentResponse{}, common.Wrap("createPostComment: repos.Comments.Create", err)
+	}
+
+	// Return it with its author
+	comment, err := h.repos.Comments.Get(comment.PostID, comment.ID)
+	if err != nil {
+		return common.CreatePostCommentResponse{}, common.Wrap("createPostComment: repos.Comments.Get", err)
+	}
+
+	return common.CreatePostCommentResponse{Comment: comment.ToResponseModel()}, nil
+}
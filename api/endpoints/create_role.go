@@ -0,0 +1,65 @@
+package endpoints
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) CreateRole(c *gin.Context) {
+	HandleRequest(c, h.makeCreateRoleRequest, h.createRole)
+}
+
+func (h *handler) makeCreateRoleRequest(c *gin.Context) (req common.CreateRoleRequest, err error) {
+
+	if err = c.ShouldBindJSON(&req); err != nil {
+		return common.CreateRoleRequest{}, common.Wrap(err.Error(), common.ErrBindingRequest)
+	}
+
+	req.Name = common.RoleName(strings.ToLower(strings.TrimSpace(string(req.Name))))
+	if req.Name == "" || req.Name == common.AnyRole {
+		return common.CreateRoleRequest{}, common.ErrInvalidValue("name")
+	}
+
+	// Nobody can hand out permissions they don't have
+	for _, permission := range req.Permissions {
+		if !hasPermission(c, permission) {
+			return common.CreateRoleRequest{}, common.Wrap("makeCreateRoleRequest: !hasPermission", common.ErrForbidden)
+		}
+	}
+
+	return req, nil
+}
+
+func (h *handler) createRole(c *gin.Context, request common.CreateRoleRequest) (common.CreateRoleResponse, error) {
+	role := request.ToRoleModel()
+
+	// Check it doesn't exist
+	if _, err := h.repos.Roles.GetByName(role.Name); err == nil {
+		return common.CreateRoleResponse{}, common.Wrap("createRole: role exists", common.ErrRoleAlreadyExists)
+	} else if !errors.Is(err, common.ErrRoleNotFound) {
+		return common.CreateRoleResponse{}, common.Wrap("createRole: repos.Roles.GetByName", err)
+	}
+
+	// Get permissions
+	if len(request.Permissions) > 0 {
+		permissions, err := h.repos.Roles.GetPermissionsByNames(request.Permissions)
+		if err != nil {
+			return common.CreateRoleResponse{}, common.Wrap("createRole: repos.Roles.GetPermissionsByNames", err)
+		}
+		role.Permissions = permissions
+		if len(role.Permissions) != len(request.Permissions) {
+			return common.CreateRoleResponse{}, common.Wrap("createRole: len(role.Permissions) != len(request.Permissions)", common.ErrPermissionNotFound)
+		}
+	}
+
+	// Create role
+	if err := h.repos.Roles.Create(&role); err != nil {
+		return common.CreateRoleResponse{}, common.Wrap("createRole: repos.Roles.Create", err)
+	}
+
+	return common.CreateRoleResponse{Role: role.ToResponseModel()}, nil
+}
@@ -1,8 +1,6 @@
 package endpoints
 
 import (
-	"strings"
-
 	"github.com/gilperopiola/go-rest-example-small/api/common"
 
 	"github.com/gin-gonic/gin"
@@ -22,20 +20,33 @@ func (h *handler) makeCreateUserRequest(c *gin.Context) (req common.CreateUserRe
 		return common.CreateUserRequest{}, common.Wrap("makeCreateUserRequest", err)
 	}
 
+	if req.IsAdmin && !hasPermission(c, common.PermissionManageAdmins) {
+		return common.CreateUserRequest{}, common.Wrap("makeCreateUserRequest: !hasPermission", common.ErrForbidden)
+	}
+
 	return req, nil
 }
 
 func (h *handler) createUser(c *gin.Context, request common.CreateUserRequest) (common.CreateUserResponse, error) {
 	user := request.ToUserModel()
-	user.Password = common.Hash(user.Password, h.config.HashSalt) // TODO this can be inside of the .ToUserModel fn?
+	if err := user.HashPassword(h.hasher); err != nil {
+		return common.CreateUserResponse{}, common.Wrap("createUser: user.HashPassword", err)
+	}
+
+	// Assign roles
+	roleNames := []common.RoleName{common.UserRole}
+	if request.IsAdmin {
+		roleNames = append(roleNames, common.AdminRole)
+	}
+	roles, err := h.getRolesByName(roleNames...)
+	if err != nil {
+		return common.CreateUserResponse{}, common.Wrap("createUser: getRolesByName", err)
+	}
+	user.Roles = roles
 
 	// Create user
-	if err := h.db.Create(&user).Error; err != nil {
-		errStr := err.Error()
-		if strings.Contains(errStr, "Error 1062") { // Duplicate entry for key
-			return common.CreateUserResponse{}, common.Wrap(errStr, common.ErrUsernameOrEmailAlreadyInUse)
-		}
-		return common.CreateUserResponse{}, common.Wrap(errStr, common.ErrCreatingUser)
+	if err := h.repos.Users.Create(&user); err != nil {
+		return common.CreateUserResponse{}, common.Wrap("createUser: repos.Users.Create", err)
 	}
 
 	return common.CreateUserResponse{User: user.ToResponseModel()}, nil
@@ -21,14 +21,42 @@ func (h *handler) makeCreateUserPostRequest(c *gin.Context) (req common.CreateUs
 		return common.CreateUserPostRequest{}, common.ErrAllFieldsRequired
 	}
 
+	if req.Status == "" {
+		req.Status = common.PostStatusPublished
+	}
+
+	if err = validatePostStatus(req.Status, req.PublishAt); err != nil {
+		return common.CreateUserPostRequest{}, common.Wrap("makeCreateUserPostRequest: validatePostStatus", err)
+	}
+
+	if req.Tags, err = common.NormalizeTags(req.Tags); err != nil {
+		return common.CreateUserPostRequest{}, common.Wrap("makeCreateUserPostRequest: NormalizeTags", err)
+	}
+
 	return req, nil
 }
 
 func (h *handler) createUserPost(c *gin.Context, request common.CreateUserPostRequest) (common.CreateUserPostResponse, error) {
-	userPost := request.ToUserPostModel()
-
-	if err := h.db.Create(&userPost).Error; err != nil {
-		return common.CreateUserPostResponse{}, common.Wrap(err.Error(), common.ErrCreatingUserPost)
+	var userPost common.UserPost
+
+	err := h.repos.UnitOfWork.Do(func(repos common.Repositories) error {
+		userPost = request.ToUserPostModel() // From scratch, in case it's a retry
+
+		// Tags
+		tags, err := repos.Posts.FindOrCreateTags(request.Tags)
+		if err != nil {
+			return common.Wrap("repos.Posts.FindOrCreateTags", err)
+		}
+		userPost.Tags = tags
+
+		// The tags are linked to the post when it's created
+		if err := repos.Posts.Create(&userPost); err != nil {
+			return common.Wrap("repos.Posts.Create", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return common.CreateUserPostResponse{}, common.Wrap("createUserPost: repos.UnitOfWork.Do", err)
 	}
 
 	return common.CreateUserPostResponse{UserPost: userPost.ToResponseModel()}, nil
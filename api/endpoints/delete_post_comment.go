@@ -0,0 +1,53 @@
+package endpoints
+
+import (
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) DeletePostComment(c *gin.Context) {
+	HandleRequest(c, h.makeDeletePostCommentRequest, h.deletePostComment)
+}
+
+func (h *handler) makeDeletePostCommentRequest(c *gin.Context) (req common.DeletePostCommentRequest, err error) {
+	req.UserID = c.GetInt(contextUserIDKey)
+	if req.UserID == 0 {
+		return common.DeletePostCommentRequest{}, common.ErrAllFieldsRequired
+	}
+
+	req.PostID = getIntFromPath(c, pathPostIDKey)
+	if req.PostID == 0 {
+		return common.DeletePostCommentRequest{}, common.ErrInvalidValue(pathPostIDKey)
+	}
+
+	req.CommentID = getIntFromPath(c, pathCommentIDKey)
+	if req.CommentID == 0 {
+		return common.DeletePostCommentRequest{}, common.ErrInvalidValue(pathCommentIDKey)
+	}
+
+	req.IsAdmin = hasRole(c, common.AdminRole)
+
+	return req, nil
+}
+
+// deletePostComment can be done by the author or by an admin. The replies go away with the comment.
+func (h *handler) deletePostComment(c *gin.Context, request common.DeletePostCommentRequest) (common.DeletePostCommentResponse, error) {
+
+	// Get comment
+	comment, err := h.repos.Comments.Get(request.PostID, request.CommentID)
+	if err != nil {
+		return common.DeletePostCommentResponse{}, common.Wrap("deletePostComment: repos.Comments.Get", err)
+	}
+
+	if comment.UserID != request.UserID && !request.IsAdmin {
+		return common.DeletePostCommentResponse{}, common.Wrap("deletePostComment: comment.UserID != request.UserID", common.ErrForbidden)
+	}
+
+	// Delete comment & replies
+	if err := h.repos.Comments.SoftDeleteWithReplies(comment.ID); err != nil {
+		return common.DeletePostCommentResponse{}, common.Wrap("deletePostComment: repos.Comments.SoftDeleteWithReplies", err)
+	}
+
+	return common.DeletePostCommentResponse{Comment: comment.ToResponseModel()}, nil
+}
@@ -1,12 +1,9 @@
 package endpoints
 
 import (
-	"errors"
-
 	"github.com/gilperopiola/go-rest-example-small/api/common"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 func (h *handler) DeleteUser(c *gin.Context) {
@@ -26,12 +23,9 @@ func (h *handler) deleteUser(c *gin.Context, request common.DeleteUserRequest) (
 	user := request.ToUserModel()
 
 	// Get user
-	query := "(id = ?)"
-	if err := h.db.Where(query, user.ID).First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return common.DeleteUserResponse{}, common.Wrap(err.Error(), common.ErrUserNotFound)
-		}
-		return common.DeleteUserResponse{}, common.Wrap(err.Error(), common.ErrGettingUser)
+	user, err := h.repos.Users.Get(user.ID)
+	if err != nil {
+		return common.DeleteUserResponse{}, common.Wrap("deleteUser: repos.Users.Get", err)
 	}
 
 	// If already deleted
@@ -39,9 +33,14 @@ func (h *handler) deleteUser(c *gin.Context, request common.DeleteUserRequest) (
 		return common.DeleteUserResponse{}, common.Wrap("deleteUser: user.Deleted", common.ErrUserAlreadyDeleted)
 	}
 
-	// Delete user
-	if err := h.db.Model(&user).Update("deleted", true).Error; err != nil {
-		return common.DeleteUserResponse{}, common.Wrap(err.Error(), common.ErrDeletingUser)
+	// Delete user. It can be restored until it gets purged
+	if err := h.repos.Users.SoftDelete(&user); err != nil {
+		return common.DeleteUserResponse{}, common.Wrap("deleteUser: repos.Users.SoftDelete", err)
+	}
+
+	// Tokens they already hold are no longer valid
+	if err := h.auth.RevokeAllUserTokens(user.ID); err != nil {
+		return common.DeleteUserResponse{}, common.Wrap("deleteUser: auth.RevokeAllUserTokens", err)
 	}
 
 	return common.DeleteUserResponse{User: user.ToResponseModel()}, nil
@@ -0,0 +1,41 @@
+package endpoints
+
+import (
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) DeleteUserPost(c *gin.Context) {
+	HandleRequest(c, h.makeDeleteUserPostRequest, h.deleteUserPost)
+}
+
+func (h *handler) makeDeleteUserPostRequest(c *gin.Context) (req common.DeleteUserPostRequest, err error) {
+	req.UserID = c.GetInt(contextUserIDKey)
+	if req.UserID == 0 {
+		return common.DeleteUserPostRequest{}, common.ErrAllFieldsRequired
+	}
+
+	req.PostID = getIntFromPath(c, pathPostIDKey)
+	if req.PostID == 0 {
+		return common.DeleteUserPostRequest{}, common.ErrInvalidValue(pathPostIDKey)
+	}
+
+	return req, nil
+}
+
+func (h *handler) deleteUserPost(c *gin.Context, request common.DeleteUserPostRequest) (common.DeleteUserPostResponse, error) {
+
+	// Get post
+	userPost, err := h.repos.Posts.GetUserPost(request.UserID, request.PostID)
+	if err != nil {
+		return common.DeleteUserPostResponse{}, common.Wrap("deleteUserPost: repos.Posts.GetUserPost", err)
+	}
+
+	// Delete post
+	if err := h.repos.Posts.SoftDelete(&userPost); err != nil {
+		return common.DeleteUserPostResponse{}, common.Wrap("deleteUserPost: repos.Posts.SoftDelete", err)
+	}
+
+	return common.DeleteUserPostResponse{UserPost: userPost.ToResponseModel()}, nil
+}
@@ -0,0 +1,77 @@
+package endpoints
+
+import (
+	"errors"
+
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) DisableTOTP(c *gin.Context) {
+	HandleRequest(c, h.makeDisableTOTPRequest, h.disableTOTP)
+}
+
+func (h *handler) makeDisableTOTPRequest(c *gin.Context) (req common.DisableTOTPRequest, err error) {
+
+	if err = c.ShouldBindJSON(&req); err != nil {
+		return common.DisableTOTPRequest{}, common.Wrap(err.Error(), common.ErrBindingRequest)
+	}
+
+	req.UserID = c.GetInt(contextUserIDKey)
+	if req.UserID == 0 || req.Code == "" {
+		return common.DisableTOTPRequest{}, common.ErrAllFieldsRequired
+	}
+
+	return req, nil
+}
+
+func (h *handler) disableTOTP(c *gin.Context, request common.DisableTOTPRequest) (common.DisableTOTPResponse, error) {
+
+	// Get user
+	user, err := h.repos.Users.GetActive(request.UserID)
+	if err != nil {
+		return common.DisableTOTPResponse{}, common.Wrap("disableTOTP: repos.Users.GetActive", err)
+	}
+
+	if !user.TOTPEnabled {
+		return common.DisableTOTPResponse{}, common.Wrap("disableTOTP: !user.TOTPEnabled", common.ErrTOTPNotEnabled)
+	}
+
+	// Codes are short, so they're throttled just like on login
+	userKey := common.UserThrottleKey(user.ID)
+	if err := h.checkLoginThrottle(c, userKey); err != nil {
+		return common.DisableTOTPResponse{}, common.Wrap("disableTOTP: checkLoginThrottle", err)
+	}
+
+	// A stolen access token alone isn't enough to disable it
+	if err := h.verifySecondFactor(user, request.Code); err != nil {
+		if errors.Is(err, common.ErrInvalidMFACode) {
+			if err := h.loginThrottler.RegisterFailure(userKey); err != nil {
+				return common.DisableTOTPResponse{}, common.Wrap("disableTOTP: loginThrottler.RegisterFailure", err)
+			}
+		}
+		return common.DisableTOTPResponse{}, common.Wrap("disableTOTP: verifySecondFactor", err)
+	}
+
+	if err := h.loginThrottler.Reset(userKey); err != nil {
+		return common.DisableTOTPResponse{}, common.Wrap("disableTOTP: loginThrottler.Reset", err)
+	}
+
+	// Disable 2FA, without leaving recovery codes behind
+	err = h.repos.UnitOfWork.Do(func(repos common.Repositories) error {
+		updates := map[string]interface{}{"totp_enabled": false, "totp_secret": "", "totp_last_used_step": 0}
+		if err := repos.Users.UpdateFields(&user, updates); err != nil {
+			return common.Wrap("repos.Users.UpdateFields", err)
+		}
+		if err := repos.Tokens.DeleteRecoveryCodes(user.ID); err != nil {
+			return common.Wrap("repos.Tokens.DeleteRecoveryCodes", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return common.DisableTOTPResponse{}, common.Wrap("disableTOTP: repos.UnitOfWork.Do", err)
+	}
+
+	return common.DisableTOTPResponse{User: user.ToResponseModel()}, nil
+}
@@ -0,0 +1,60 @@
+package endpoints
+
+import (
+	"fmt"
+
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DownloadUserDataExport doesn't go through HandleRequest, as it returns a zip file instead of JSON
+func (h *handler) DownloadUserDataExport(c *gin.Context) {
+	request, err := h.makeDownloadUserDataExportRequest(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response, err := h.downloadUserDataExport(c, request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.FileAttachment(response.FilePath, response.FileName)
+}
+
+func (h *handler) makeDownloadUserDataExportRequest(c *gin.Context) (req common.DownloadUserDataExportRequest, err error) {
+	req.UserID = c.GetInt(contextUserIDKey)
+	if req.UserID == 0 {
+		return common.DownloadUserDataExportRequest{}, common.ErrAllFieldsRequired
+	}
+
+	req.ExportID = getIntFromPath(c, pathExportIDKey)
+	if req.ExportID == 0 {
+		return common.DownloadUserDataExportRequest{}, common.ErrInvalidValue(pathExportIDKey)
+	}
+
+	return req, nil
+}
+
+func (h *handler) downloadUserDataExport(c *gin.Context, request common.DownloadUserDataExportRequest) (common.DownloadUserDataExportResponse, error) {
+	export, err := h.getDataExport(request.UserID, request.ExportID)
+	if err != nil {
+		return common.DownloadUserDataExportResponse{}, common.Wrap("downloadUserDataExport: getDataExport", err)
+	}
+
+	if export.Status != common.DataExportReady {
+		return common.DownloadUserDataExportResponse{}, common.Wrap("downloadUserDataExport: export.Status", common.ErrDataExportNotReady)
+	}
+
+	if export.IsExpired() {
+		return common.DownloadUserDataExportResponse{}, common.Wrap("downloadUserDataExport: export.IsExpired", common.ErrDataExportExpired)
+	}
+
+	return common.DownloadUserDataExportResponse{
+		FilePath: export.FilePath,
+		FileName: fmt.Sprintf("user-%d-export-%d.zip", export.UserID, export.ID),
+	}, nil
+}
@@ -0,0 +1,48 @@
+package endpoints
+
+import (
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) EnrollTOTP(c *gin.Context) {
+	HandleRequest(c, h.makeEnrollTOTPRequest, h.enrollTOTP)
+}
+
+func (h *handler) makeEnrollTOTPRequest(c *gin.Context) (req common.EnrollTOTPRequest, err error) {
+	req.UserID = c.GetInt(contextUserIDKey)
+	if req.UserID == 0 {
+		return common.EnrollTOTPRequest{}, common.ErrAllFieldsRequired
+	}
+
+	return req, nil
+}
+
+func (h *handler) enrollTOTP(c *gin.Context, request common.EnrollTOTPRequest) (common.EnrollTOTPResponse, error) {
+
+	// Get user
+	user, err := h.repos.Users.GetActive(request.UserID)
+	if err != nil {
+		return common.EnrollTOTPResponse{}, common.Wrap("enrollTOTP: repos.Users.GetActive", err)
+	}
+
+	if user.TOTPEnabled {
+		return common.EnrollTOTPResponse{}, common.Wrap("enrollTOTP: user.TOTPEnabled", common.ErrTOTPAlreadyEnabled)
+	}
+
+	// Generate secret. It isn't enabled until the user confirms it with a code
+	secret, err := common.GenerateTOTPSecret()
+	if err != nil {
+		return common.EnrollTOTPResponse{}, common.Wrap(err.Error(), common.ErrUpdatingUser)
+	}
+
+	if err := h.repos.Users.UpdateFields(&user, map[string]interface{}{"totp_secret": secret}); err != nil {
+		return common.EnrollTOTPResponse{}, common.Wrap("enrollTOTP: repos.Users.UpdateFields", err)
+	}
+
+	return common.EnrollTOTPResponse{
+		Secret: secret,
+		URI:    common.TOTPURI(h.config.MFA.Issuer, user.Username, secret),
+	}, nil
+}
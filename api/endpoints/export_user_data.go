@@ -0,0 +1,88 @@
+package endpoints
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Exports still pending after this long are considered lost (e.g. the server restarted while building them)
+const dataExportBuildTimeout = time.Hour
+
+func (h *handler) ExportUserData(c *gin.Context) {
+	HandleRequest(c, h.makeExportUserDataRequest, h.exportUserData)
+}
+
+func (h *handler) makeExportUserDataRequest(c *gin.Context) (req common.ExportUserDataRequest, err error) {
+	req.UserID = c.GetInt(contextUserIDKey)
+	if req.UserID == 0 {
+		return common.ExportUserDataRequest{}, common.ErrAllFieldsRequired
+	}
+
+	return req, nil
+}
+
+// exportUserData starts building the export in the background. If there's one already being built, that one is returned.
+func (h *handler) exportUserData(c *gin.Context, request common.ExportUserDataRequest) (common.ExportUserDataResponse, error) {
+
+	// Check user exists
+	if _, err := h.getUser(c, common.GetUserRequest{UserID: request.UserID}); err != nil {
+		return common.ExportUserDataResponse{}, common.Wrap("exportUserData: getUser", err)
+	}
+
+	// Get pending export
+	export, err := h.repos.Exports.GetPending(request.UserID, time.Now().Add(-dataExportBuildTimeout))
+	if err == nil {
+		return common.ExportUserDataResponse{Export: export.ToResponseModel()}, nil
+	}
+	if !errors.Is(err, common.ErrDataExportNotFound) {
+		return common.ExportUserDataResponse{}, common.Wrap("exportUserData: repos.Exports.GetPending", err)
+	}
+
+	// Create a new one
+	export = common.DataExport{UserID: request.UserID, Status: common.DataExportPending}
+	if err := h.repos.Exports.Create(&export); err != nil {
+		return common.ExportUserDataResponse{}, common.Wrap("exportUserData: repos.Exports.Create", err)
+	}
+
+	go h.buildUserDataExport(export)
+
+	return common.ExportUserDataResponse{Export: export.ToResponseModel()}, nil
+}
+
+// buildUserDataExport runs outside of the request, so it can't use the gin context.
+// Its errors are only logged, and a panic marks the export as failed instead of taking the server down.
+func (h *handler) buildUserDataExport(export common.DataExport) {
+	now := time.Now()
+	updates := map[string]interface{}{"status": common.DataExportFailed, "completed_at": now}
+	logger := h.logger.WithField("export_id", export.ID)
+
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("buildUserDataExport: panic: %v", r)
+			updates = map[string]interface{}{"status": common.DataExportFailed, "completed_at": now}
+		}
+		if err := h.repos.Exports.UpdateFields(export.ID, updates); err != nil {
+			logger.Error("buildUserDataExport: repos.Exports.UpdateFields: " + err.Error())
+		}
+	}()
+
+	data, err := h.repos.Exports.CollectUserData(export)
+	if err != nil {
+		logger.Error("buildUserDataExport: repos.Exports.CollectUserData: " + err.Error())
+		return
+	}
+
+	filePath, err := common.WriteUserDataExport(h.config.Exports.Path, export, data)
+	if err != nil {
+		logger.Error("buildUserDataExport: common.WriteUserDataExport: " + err.Error())
+		return
+	}
+
+	updates["status"] = common.DataExportReady
+	updates["file_path"] = filePath
+	updates["expires_at"] = now.Add(time.Hour * time.Duration(h.config.Exports.ExpirationHours))
+}
@@ -0,0 +1,73 @@
+package endpoints
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) ForgotPassword(c *gin.Context) {
+	HandleRequest(c, h.makeForgotPasswordRequest, h.forgotPassword)
+}
+
+func (h *handler) makeForgotPasswordRequest(c *gin.Context) (req common.ForgotPasswordRequest, err error) {
+
+	if err = c.ShouldBindJSON(&req); err != nil {
+		return common.ForgotPasswordRequest{}, common.Wrap(err.Error(), common.ErrBindingRequest)
+	}
+
+	if req.UsernameOrEmail == "" {
+		return common.ForgotPasswordRequest{}, common.Wrap("makeForgotPasswordRequest", common.ErrAllFieldsRequired)
+	}
+
+	return req, nil
+}
+
+func (h *handler) forgotPassword(c *gin.Context, request common.ForgotPasswordRequest) (common.ForgotPasswordResponse, error) {
+	user := request.ToUserModel()
+
+	// We don't tell the caller if the user exists or not
+	response := common.ForgotPasswordResponse{Message: "if the user exists, an email has been sent"}
+
+	// Get user
+	user, err := h.repos.Users.GetActiveByUsernameOrEmail(user.Username, user.Email)
+	if err != nil {
+		if errors.Is(err, common.ErrUserNotFound) {
+			return response, nil
+		}
+		return common.ForgotPasswordResponse{}, common.Wrap("forgotPassword: repos.Users.GetActiveByUsernameOrEmail", err)
+	}
+
+	// Previous tokens are no longer valid
+	if err := h.repos.Tokens.InvalidatePasswordResetTokens(user.ID); err != nil {
+		return common.ForgotPasswordResponse{}, common.Wrap("forgotPassword: repos.Tokens.InvalidatePasswordResetTokens", err)
+	}
+
+	// Generate token and save it hashed
+	tokenString, err := common.GenerateOpaqueToken()
+	if err != nil {
+		return common.ForgotPasswordResponse{}, common.Wrap(err.Error(), common.ErrCreatingPasswordResetToken)
+	}
+
+	resetToken := common.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: common.HashOpaqueToken(tokenString),
+		ExpiresAt: time.Now().Add(time.Minute * time.Duration(h.config.Passwords.ResetTokenMinutes)),
+	}
+	if err := h.repos.Tokens.CreatePasswordResetToken(&resetToken); err != nil {
+		return common.ForgotPasswordResponse{}, common.Wrap("forgotPassword: repos.Tokens.CreatePasswordResetToken", err)
+	}
+
+	// Send it
+	body := fmt.Sprintf("Hi %s! Use this token to reset your password, it expires in %d minutes:\n\n%s",
+		user.Username, h.config.Passwords.ResetTokenMinutes, tokenString)
+	if err := h.mailer.Send(user.Email, "Reset your password", body); err != nil {
+		return common.ForgotPasswordResponse{}, common.Wrap("forgotPassword: mailer.Send", err)
+	}
+
+	return response, nil
+}
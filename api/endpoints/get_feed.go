@@ -0,0 +1,93 @@
+package endpoints
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) GetFeed(c *gin.Context) {
+	HandleRequest(c, h.makeGetFeedRequest, h.getFeed)
+}
+
+// The feed is always newest first, its cursors are keyed on (publish_at, id).
+// Scheduled posts go on top when they're published, not back when they were written.
+const feedSort = "-publish_at"
+
+func (h *handler) makeGetFeedRequest(c *gin.Context) (req common.GetFeedRequest, err error) {
+
+	req.PerPage, err = strconv.Atoi(c.DefaultQuery("per_page", defaultPerPage))
+	if err != nil || req.PerPage <= 0 {
+		return common.GetFeedRequest{}, common.ErrInvalidValue("per_page")
+	}
+
+	if req.PerPage > maxPerPage {
+		req.PerPage = maxPerPage
+	}
+
+	if encodedCursor := c.Query("cursor"); encodedCursor != "" {
+		cursor, err := common.DecodeCursor(encodedCursor)
+		if err != nil || cursor.Sort != feedSort {
+			return common.GetFeedRequest{}, common.Wrap("makeGetFeedRequest", common.ErrInvalidCursor)
+		}
+		req.Cursor = &cursor
+	}
+
+	return req, nil
+}
+
+func (h *handler) getFeed(c *gin.Context, request common.GetFeedRequest) (common.GetFeedResponse, error) {
+	var (
+		perPage  = request.PerPage
+		backward = request.Cursor != nil && request.Cursor.Backward
+		response = common.GetFeedResponse{PerPage: perPage}
+	)
+
+	// Going backward (to newer posts), the order is flipped and the results are reversed afterwards.
+	// One more than needed, to know if there are more.
+	feed := common.PostFeed{Tag: request.Tag, Descending: !backward, Limit: perPage + 1}
+	if request.Cursor != nil {
+		publishAt, err := time.Parse(time.RFC3339Nano, request.Cursor.Value)
+		if err != nil {
+			return common.GetFeedResponse{}, common.Wrap("getFeed: time.Parse", common.ErrInvalidCursor)
+		}
+		feed.After = &common.KeysetPosition{Value: publishAt, ID: request.Cursor.ID}
+	}
+
+	posts, err := h.repos.Posts.ListPublic(feed)
+	if err != nil {
+		return common.GetFeedResponse{}, common.Wrap("getFeed: repos.Posts.ListPublic", err)
+	}
+
+	hasMore := len(posts) > perPage
+	if hasMore {
+		posts = posts[:perPage]
+	}
+	if backward {
+		for i, j := 0, len(posts)-1; i < j; i, j = i+1, j-1 {
+			posts[i], posts[j] = posts[j], posts[i]
+		}
+	}
+
+	// Cursors
+	if len(posts) > 0 {
+		first, last := posts[0], posts[len(posts)-1]
+
+		if (!backward && hasMore) || backward {
+			response.NextCursor = common.Cursor{Sort: feedSort, Value: last.PublishedAt().Format(time.RFC3339Nano), ID: last.ID}.Encode()
+		}
+		if (!backward && request.Cursor != nil) || (backward && hasMore) {
+			response.PrevCursor = common.Cursor{Sort: feedSort, Value: first.PublishedAt().Format(time.RFC3339Nano), ID: first.ID, Backward: true}.Encode()
+		}
+	}
+
+	if err := h.repos.Posts.LoadCounts(posts); err != nil {
+		return common.GetFeedResponse{}, common.Wrap("getFeed: repos.Posts.LoadCounts", err)
+	}
+
+	response.Posts = posts.ToResponseModel()
+	return response, nil
+}
@@ -0,0 +1,46 @@
+package endpoints
+
+import (
+	"testing"
+
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Posts from before there were post states are published without a publish_at, they still go through the feed
+func TestGetFeed_LegacyPostWithoutPublishAt(t *testing.T) {
+	h, _ := newTestHandler(t)
+	alice := signupTestUser(t, h, "alice", "alice@example.com")
+
+	legacyPost := common.UserPost{UserID: alice.ID, Title: "Legacy", Status: common.PostStatusPublished}
+	require.NoError(t, h.repos.Posts.Create(&legacyPost))
+
+	postIDs := []int{}
+	for _, title := range []string{"First", "Second"} {
+		response, err := h.createUserPost(newTestContext(alice.ID), common.CreateUserPostRequest{UserID: alice.ID, Title: title, Body: "Body", Status: common.PostStatusPublished})
+		require.NoError(t, err)
+		postIDs = append([]int{response.UserPost.ID}, postIDs...)
+	}
+	postIDs = append(postIDs, legacyPost.ID)
+
+	// One post per page, following the cursors
+	seen := []int{}
+	request := common.GetFeedRequest{PerPage: 1}
+	for {
+		response, err := h.getFeed(newTestContext(0), request)
+		require.NoError(t, err)
+		require.Len(t, response.Posts, 1)
+		seen = append(seen, response.Posts[0].ID)
+
+		if response.NextCursor == "" {
+			break
+		}
+		cursor, err := common.DecodeCursor(response.NextCursor)
+		require.NoError(t, err)
+		request.Cursor = &cursor
+	}
+
+	assert.Equal(t, postIDs, seen)
+}
@@ -0,0 +1,36 @@
+package endpoints
+
+import (
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) GetPost(c *gin.Context) {
+	HandleRequest(c, h.makeGetPostRequest, h.getPost)
+}
+
+func (h *handler) makeGetPostRequest(c *gin.Context) (req common.GetPostRequest, err error) {
+	req.PostID = getIntFromPath(c, pathPostIDKey)
+	if req.PostID == 0 {
+		return common.GetPostRequest{}, common.ErrInvalidValue(pathPostIDKey)
+	}
+
+	return req, nil
+}
+
+func (h *handler) getPost(c *gin.Context, request common.GetPostRequest) (common.GetPostResponse, error) {
+
+	// Get post
+	post, err := h.repos.Posts.GetPublic(request.PostID)
+	if err != nil {
+		return common.GetPostResponse{}, common.Wrap("getPost: repos.Posts.GetPublic", err)
+	}
+
+	posts := common.UserPosts{post}
+	if err := h.repos.Posts.LoadCounts(posts); err != nil {
+		return common.GetPostResponse{}, common.Wrap("getPost: repos.Posts.LoadCounts", err)
+	}
+
+	return common.GetPostResponse{Post: posts[0].ToResponseModel()}, nil
+}
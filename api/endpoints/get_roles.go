@@ -0,0 +1,29 @@
+package endpoints
+
+import (
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) GetRoles(c *gin.Context) {
+	HandleRequest(c, h.makeGetRolesRequest, h.getRoles)
+}
+
+func (h *handler) makeGetRolesRequest(c *gin.Context) (req common.GetRolesRequest, err error) {
+	return req, nil
+}
+
+func (h *handler) getRoles(c *gin.Context, request common.GetRolesRequest) (common.GetRolesResponse, error) {
+	roles, err := h.repos.Roles.GetAll()
+	if err != nil {
+		return common.GetRolesResponse{}, common.Wrap("getRoles: repos.Roles.GetAll", err)
+	}
+
+	responseRoles := []common.ResponseRole{}
+	for _, role := range roles {
+		responseRoles = append(responseRoles, role.ToResponseModel())
+	}
+
+	return common.GetRolesResponse{Roles: responseRoles}, nil
+}
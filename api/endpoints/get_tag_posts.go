@@ -0,0 +1,24 @@
+package endpoints
+
+import (
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTagPosts is the feed, but only with the posts of a tag
+func (h *handler) GetTagPosts(c *gin.Context) {
+	HandleRequest(c, h.makeGetTagPostsRequest, h.getFeed)
+}
+
+func (h *handler) makeGetTagPostsRequest(c *gin.Context) (req common.GetFeedRequest, err error) {
+	if req, err = h.makeGetFeedRequest(c); err != nil {
+		return common.GetFeedRequest{}, err
+	}
+
+	if req.Tag, err = common.NormalizeTag(c.Param(pathTagKey)); err != nil {
+		return common.GetFeedRequest{}, common.ErrInvalidValue(pathTagKey)
+	}
+
+	return req, nil
+}
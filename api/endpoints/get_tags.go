@@ -0,0 +1,51 @@
+package endpoints
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) GetTags(c *gin.Context) {
+	HandleRequest(c, h.makeGetTagsRequest, h.getTags)
+}
+
+const (
+	getTagsDefaultLimit = "10"
+	getTagsMaxLimit     = 50
+)
+
+func (h *handler) makeGetTagsRequest(c *gin.Context) (req common.GetTagsRequest, err error) {
+
+	// The prefix can be empty, to get the most used tags
+	req.Prefix = strings.ToLower(strings.TrimSpace(c.Query("prefix")))
+	if req.Prefix != "" {
+		if _, err := common.NormalizeTag(strings.TrimSuffix(req.Prefix, "-")); err != nil {
+			return common.GetTagsRequest{}, common.ErrInvalidValue("prefix")
+		}
+	}
+
+	req.Limit, err = strconv.Atoi(c.DefaultQuery("limit", getTagsDefaultLimit))
+	if err != nil || req.Limit <= 0 {
+		return common.GetTagsRequest{}, common.ErrInvalidValue("limit")
+	}
+
+	if req.Limit > getTagsMaxLimit {
+		req.Limit = getTagsMaxLimit
+	}
+
+	return req, nil
+}
+
+// getTags is for autocompletion. Tags that aren't on any visible post are left out.
+func (h *handler) getTags(c *gin.Context, request common.GetTagsRequest) (common.GetTagsResponse, error) {
+	tags, err := h.repos.Posts.SearchTags(request.Prefix, request.Limit)
+	if err != nil {
+		return common.GetTagsResponse{}, common.Wrap("getTags: repos.Posts.SearchTags", err)
+	}
+
+	return common.GetTagsResponse{Tags: tags}, nil
+}
@@ -1,12 +1,9 @@
 package endpoints
 
 import (
-	"errors"
-
 	"github.com/gilperopiola/go-rest-example-small/api/common"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 func (h *handler) GetUser(c *gin.Context) {
@@ -26,12 +23,9 @@ func (h *handler) getUser(c *gin.Context, request common.GetUserRequest) (common
 	user := request.ToUserModel()
 
 	// Get user
-	query := "(id = ?)"
-	if err := h.db.Preload("Details").Preload("Posts").Where(query, user.ID).First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return common.GetUserResponse{}, common.Wrap(err.Error(), common.ErrUserNotFound)
-		}
-		return common.GetUserResponse{}, common.Wrap(err.Error(), common.ErrGettingUser)
+	user, err := h.repos.Users.GetWithDetails(user.ID)
+	if err != nil {
+		return common.GetUserResponse{}, common.Wrap("getUser: repos.Users.GetWithDetails", err)
 	}
 
 	// If deleted
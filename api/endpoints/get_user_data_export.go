@@ -0,0 +1,43 @@
+package endpoints
+
+import (
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) GetUserDataExport(c *gin.Context) {
+	HandleRequest(c, h.makeGetUserDataExportRequest, h.getUserDataExport)
+}
+
+func (h *handler) makeGetUserDataExportRequest(c *gin.Context) (req common.GetUserDataExportRequest, err error) {
+	req.UserID = c.GetInt(contextUserIDKey)
+	if req.UserID == 0 {
+		return common.GetUserDataExportRequest{}, common.ErrAllFieldsRequired
+	}
+
+	req.ExportID = getIntFromPath(c, pathExportIDKey)
+	if req.ExportID == 0 {
+		return common.GetUserDataExportRequest{}, common.ErrInvalidValue(pathExportIDKey)
+	}
+
+	return req, nil
+}
+
+func (h *handler) getUserDataExport(c *gin.Context, request common.GetUserDataExportRequest) (common.GetUserDataExportResponse, error) {
+	export, err := h.getDataExport(request.UserID, request.ExportID)
+	if err != nil {
+		return common.GetUserDataExportResponse{}, common.Wrap("getUserDataExport: getDataExport", err)
+	}
+
+	return common.GetUserDataExportResponse{Export: export.ToResponseModel()}, nil
+}
+
+// getDataExport only returns exports of the given user
+func (h *handler) getDataExport(userID, exportID int) (common.DataExport, error) {
+	export, err := h.repos.Exports.Get(userID, exportID)
+	if err != nil {
+		return common.DataExport{}, common.Wrap("getDataExport: repos.Exports.Get", err)
+	}
+	return export, nil
+}
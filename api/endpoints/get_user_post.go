@@ -0,0 +1,39 @@
+package endpoints
+
+import (
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) GetUserPost(c *gin.Context) {
+	HandleRequest(c, h.makeGetUserPostRequest, h.getUserPost)
+}
+
+func (h *handler) makeGetUserPostRequest(c *gin.Context) (req common.GetUserPostRequest, err error) {
+	req.UserID = c.GetInt(contextUserIDKey)
+	if req.UserID == 0 {
+		return common.GetUserPostRequest{}, common.ErrAllFieldsRequired
+	}
+
+	req.PostID = getIntFromPath(c, pathPostIDKey)
+	if req.PostID == 0 {
+		return common.GetUserPostRequest{}, common.ErrInvalidValue(pathPostIDKey)
+	}
+
+	return req, nil
+}
+
+func (h *handler) getUserPost(c *gin.Context, request common.GetUserPostRequest) (common.GetUserPostResponse, error) {
+	userPost, err := h.repos.Posts.GetUserPost(request.UserID, request.PostID)
+	if err != nil {
+		return common.GetUserPostResponse{}, common.Wrap("getUserPost: repos.Posts.GetUserPost", err)
+	}
+
+	userPosts := common.UserPosts{userPost}
+	if err := h.repos.Posts.LoadCounts(userPosts); err != nil {
+		return common.GetUserPostResponse{}, common.Wrap("getUserPost: repos.Posts.LoadCounts", err)
+	}
+
+	return common.GetUserPostResponse{UserPost: userPosts[0].ToResponseModel()}, nil
+}
@@ -3,17 +3,22 @@ package endpoints
 import (
 	"net/http"
 	"regexp"
+	"strconv"
+	"time"
 
 	"github.com/gilperopiola/go-rest-example-small/api/common"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
+	"github.com/sirupsen/logrus"
 )
 
 type Handler interface {
 	HealthCheck(c *gin.Context)
+	JWKS(c *gin.Context)
 	Signup(c *gin.Context)
 	Login(c *gin.Context)
+	RefreshToken(c *gin.Context)
+	Logout(c *gin.Context)
 	CreateUser(c *gin.Context)
 	GetUser(c *gin.Context)
 	UpdateUser(c *gin.Context)
@@ -21,19 +26,66 @@ type Handler interface {
 	SearchUsers(c *gin.Context)
 	ChangePassword(c *gin.Context)
 	CreateUserPost(c *gin.Context)
+	RevokeUserSessions(c *gin.Context)
+	ForgotPassword(c *gin.Context)
+	ResetPassword(c *gin.Context)
+	VerifyEmail(c *gin.Context)
+	ResendVerificationEmail(c *gin.Context)
+	UnlockUser(c *gin.Context)
+	LoginMFA(c *gin.Context)
+	EnrollTOTP(c *gin.Context)
+	ConfirmTOTP(c *gin.Context)
+	DisableTOTP(c *gin.Context)
+	GetRoles(c *gin.Context)
+	CreateRole(c *gin.Context)
+	AssignUserRole(c *gin.Context)
+	UnassignUserRole(c *gin.Context)
+	AdminGetUser(c *gin.Context)
+	AdminUpdateUser(c *gin.Context)
+	AdminDeleteUser(c *gin.Context)
+	AdminRestoreUser(c *gin.Context)
+	AdminHardDeleteUser(c *gin.Context)
+	ExportUserData(c *gin.Context)
+	GetUserDataExport(c *gin.Context)
+	DownloadUserDataExport(c *gin.Context)
+	ListUserPosts(c *gin.Context)
+	GetUserPost(c *gin.Context)
+	UpdateUserPost(c *gin.Context)
+	DeleteUserPost(c *gin.Context)
+	GetFeed(c *gin.Context)
+	GetPost(c *gin.Context)
+	GetTags(c *gin.Context)
+	GetTagPosts(c *gin.Context)
+	CreatePostComment(c *gin.Context)
+	ListPostComments(c *gin.Context)
+	UpdatePostComment(c *gin.Context)
+	DeletePostComment(c *gin.Context)
+	ReactToPost(c *gin.Context)
+	UnreactToPost(c *gin.Context)
 }
 
 type handler struct {
 	config *common.Config
-	db     *gorm.DB
+	repos  common.Repositories
 	auth   *common.Auth
+	hasher common.PasswordHasher
+	mailer common.Mailer
+
+	loginThrottler common.LoginThrottler
+
+	// logger is for what happens outside of the request, or doesn't make it fail
+	logger *logrus.Logger
 }
 
-func NewHandler(config *common.Config, db *gorm.DB, auth *common.Auth) *handler {
+func NewHandler(config *common.Config, repos common.Repositories, auth *common.Auth, hasher common.PasswordHasher, mailer common.Mailer, loginThrottler common.LoginThrottler, logger *logrus.Logger) *handler {
 	return &handler{
-		db:     db,
-		config: config,
-		auth:   auth,
+		repos:          repos,
+		config:         config,
+		auth:           auth,
+		hasher:         hasher,
+		mailer:         mailer,
+		loginThrottler: loginThrottler,
+		logger:         logger,
 	}
 }
 
@@ -65,7 +117,24 @@ func HandleRequest[req common.AllRequests, resp common.AllResponses](c *gin.Cont
 //---------------------*/
 
 var (
-	contextUserIDKey = "UserID"
+	contextUserIDKey         = "UserID"
+	contextTokenIDKey        = "TokenID"
+	contextTokenExpiresAtKey = "TokenExpiresAt"
+	contextClaimsKey         = "Claims"
+
+	pathUserIDKey    = "user_id"
+	pathRoleNameKey  = "role_name"
+	pathExportIDKey  = "export_id"
+	pathPostIDKey    = "post_id"
+	pathCommentIDKey = "comment_id"
+	pathKindKey      = "kind"
+	pathTagKey       = "tag"
+
+	commentMaxLength = 2000
+
+	defaultPage    = "0"
+	defaultPerPage = "10"
+	maxPerPage     = 100
 
 	usernameMinLength = 4
 	usernameMaxLength = 32
@@ -87,6 +156,39 @@ func validateUsernameEmailAndPassword(username, email, password string) error {
 		return common.ErrInvalidUsernameLength(usernameMinLength, usernameMaxLength)
 	}
 
+	return validatePassword(password)
+}
+
+// validateOptionalUsernameAndEmail only validates the fields that aren't empty
+func validateOptionalUsernameAndEmail(username, email string) error {
+	if email != "" && !validEmailRegex.MatchString(email) {
+		return common.ErrInvalidEmailFormat
+	}
+
+	if username != "" && (len(username) < usernameMinLength || len(username) > usernameMaxLength) {
+		return common.ErrInvalidUsernameLength(usernameMinLength, usernameMaxLength)
+	}
+
+	return nil
+}
+
+func validateNewPassword(newPassword, repeatPassword string) error {
+	if newPassword == "" || repeatPassword == "" {
+		return common.ErrAllFieldsRequired
+	}
+
+	if err := validatePassword(newPassword); err != nil {
+		return err
+	}
+
+	if newPassword != repeatPassword {
+		return common.ErrPasswordsDontMatch
+	}
+
+	return nil
+}
+
+func validatePassword(password string) error {
 	if len(password) < passwordMinLength || len(password) > passwordMaxLength {
 		return common.ErrInvalidPasswordLength(passwordMinLength, passwordMaxLength)
 	}
@@ -94,6 +196,126 @@ func validateUsernameEmailAndPassword(username, email, password string) error {
 	return nil
 }
 
+// validatePostStatus checks that only scheduled posts have a publish_at, and that it's in the future
+func validatePostStatus(status string, publishAt *time.Time) error {
+	if !common.IsValidPostStatus(status) {
+		return common.ErrInvalidValue("status")
+	}
+
+	if (status == common.PostStatusScheduled) != (publishAt != nil) {
+		return common.ErrInvalidValue("publish_at")
+	}
+
+	if publishAt != nil && !publishAt.After(time.Now()) {
+		return common.ErrInvalidValue("publish_at")
+	}
+
+	return nil
+}
+
+// getRolesByName fails if any of the roles doesn't exist
+func (h *handler) getRolesByName(names ...common.RoleName) ([]common.Role, error) {
+	roles, err := h.repos.Roles.GetByNames(names...)
+	if err != nil {
+		return nil, common.Wrap("getRolesByName: repos.Roles.GetByNames", err)
+	}
+	if len(roles) != len(names) {
+		return nil, common.Wrap("getRolesByName: len(roles) != len(names)", common.ErrRoleNotFound)
+	}
+	return roles, nil
+}
+
+// checkCanHandOutRoles fails if the roles have any permission the caller doesn't have.
+// Nobody can hand out, or take away, permissions they don't have.
+func checkCanHandOutRoles(c *gin.Context, roles []common.Role) error {
+	for _, role := range roles {
+		for _, permission := range role.Permissions {
+			if !hasPermission(c, permission.Name) {
+				return common.Wrap("checkCanHandOutRoles: !hasPermission", common.ErrForbidden)
+			}
+		}
+	}
+	return nil
+}
+
+// checkCanManageUser fails if the user is an admin and the caller can't manage admins.
+// Otherwise anyone who can manage users could take over an admin account.
+func (h *handler) checkCanManageUser(c *gin.Context, userID int) error {
+	user, err := h.repos.Users.GetWithPermissions(userID)
+	if err != nil {
+		return common.Wrap("checkCanManageUser: repos.Users.GetWithPermissions", err)
+	}
+	if user.HasRole(common.AdminRole) && !hasPermission(c, common.PermissionManageAdmins) {
+		return common.Wrap("checkCanManageUser: !hasPermission", common.ErrForbidden)
+	}
+	return nil
+}
+
+// withRepos returns a copy of the handler that uses other repositories, like the ones of a unit of work
+func (h *handler) withRepos(repos common.Repositories) *handler {
+	txHandler := *h
+	txHandler.repos = repos
+	return &txHandler
+}
+
+// getClaims returns the claims that ValidateToken set on the context, or nil
+func getClaims(c *gin.Context) *common.CustomClaims {
+	claims, ok := c.Get(contextClaimsKey)
+	if !ok {
+		return nil
+	}
+	customClaims, _ := claims.(*common.CustomClaims)
+	return customClaims
+}
+
+func hasRole(c *gin.Context, role common.RoleName) bool {
+	customClaims := getClaims(c)
+	return customClaims != nil && customClaims.HasRole(role)
+}
+
+func hasPermission(c *gin.Context, permission string) bool {
+	customClaims := getClaims(c)
+	if customClaims == nil {
+		return false
+	}
+	for _, claimsPermission := range customClaims.Permissions {
+		if claimsPermission == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// getPaginationFromQuery reads page & per_page. per_page is capped at maxPerPage.
+func getPaginationFromQuery(c *gin.Context) (page, perPage int, err error) {
+	if page, err = strconv.Atoi(c.DefaultQuery("page", defaultPage)); err != nil {
+		return 0, 0, common.ErrInvalidValue("page")
+	}
+
+	if perPage, err = strconv.Atoi(c.DefaultQuery("per_page", defaultPerPage)); err != nil {
+		return 0, 0, common.ErrInvalidValue("per_page")
+	}
+
+	if page < 0 || perPage <= 0 {
+		return 0, 0, common.ErrAllFieldsRequired
+	}
+
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+
+	return page, perPage, nil
+}
+
+// getIntFromPath returns 0 if the param isn't there or isn't a number
+func getIntFromPath(c *gin.Context, key string) int {
+	value, err := strconv.Atoi(c.Param(key))
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
 /*--------------------
 //       MISC
 //-----------------*/
@@ -104,3 +326,8 @@ func (h handler) HealthCheck(c *gin.Context) {
 		Content: "service is up and running :)",
 	})
 }
+
+// JWKS publishes the public keys used to sign our tokens, in the standard format (not wrapped in a HTTPResponse)
+func (h handler) JWKS(c *gin.Context) {
+	c.JSON(http.StatusOK, h.auth.JWKS())
+}
@@ -0,0 +1,83 @@
+package endpoints
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// The handler tests run the service functions against the in-memory repositories
+
+type testMail struct {
+	To, Subject, Body string
+}
+
+type testMailer struct {
+	sent []testMail
+}
+
+func (m *testMailer) Send(to, subject, body string) error {
+	m.sent = append(m.sent, testMail{to, subject, body})
+	return nil
+}
+
+func newTestHandler(t *testing.T) (*handler, *testMailer) {
+	secret, err := common.GenerateOpaqueToken()
+	require.NoError(t, err)
+
+	config := &common.Config{
+		General:   common.General{JWTSecret: secret},
+		Sessions:  common.Sessions{AccessTokenMinutes: 15, RefreshTokenDays: 30, SigningMethod: "HS256"},
+		Passwords: common.Passwords{HashAlgorithm: common.BcryptAlgorithm, BcryptCost: 4, ResetTokenMinutes: 30},
+		Emails:    common.Emails{VerificationTokenHours: 24},
+		Lockout:   common.Lockout{FreeAttempts: 3, BackoffBaseSeconds: 1, BackoffMaxSeconds: 60, MaxFailedAttempts: 10, LockoutMinutes: 15},
+		Exports:   common.Exports{Path: t.TempDir(), ExpirationHours: 24},
+	}
+	repos := common.NewMemoryRepositories()
+	auth := common.NewAuth(common.NewKeySet(config), config.Sessions.AccessTokenMinutes, false, common.NewMemoryRevocationStore(repos))
+	hasher := common.NewPasswordHasher(config.Passwords, "")
+	mailer := &testMailer{}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewHandler(config, repos, auth, hasher, mailer, common.NewMemoryLoginThrottler(config.Lockout, repos), logger), mailer
+}
+
+func newTestContext(userID int) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	if userID != 0 {
+		c.Set(contextUserIDKey, userID)
+	}
+	return c
+}
+
+func signupTestUser(t *testing.T, h *handler, username, email string) common.ResponseUser {
+	request := common.SignupRequest{Username: username, Email: email, Password: username + "-password", RepeatPassword: username + "-password"}
+	response, err := h.signup(newTestContext(0), request)
+	require.NoError(t, err)
+	return response.User
+}
+
+func loginTestUser(t *testing.T, h *handler, username string) common.LoginResponse {
+	request := common.LoginRequest{UsernameOrEmail: username, Password: username + "-password", ClientIP: "127.0.0.1"}
+	response, err := h.login(newTestContext(0), request)
+	require.NoError(t, err)
+	return response
+}
+
+// validateTestToken runs the access token through the auth middleware, returning the error it sets
+func validateTestToken(h *handler, token string) error {
+	c := newTestContext(0)
+	c.Request.Header.Set("Authorization", "Bearer "+token)
+	h.auth.ValidateToken(common.AnyRole, false)(c)
+	if c.IsAborted() {
+		return c.Errors.Last().Err
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package endpoints
+
+import (
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) ListPostComments(c *gin.Context) {
+	HandleRequest(c, h.makeListPostCommentsRequest, h.listPostComments)
+}
+
+func (h *handler) makeListPostCommentsRequest(c *gin.Context) (req common.ListPostCommentsRequest, err error) {
+	req.PostID = getIntFromPath(c, pathPostIDKey)
+	if req.PostID == 0 {
+		return common.ListPostCommentsRequest{}, common.ErrInvalidValue(pathPostIDKey)
+	}
+
+	if req.Page, req.PerPage, err = getPaginationFromQuery(c); err != nil {
+		return common.ListPostCommentsRequest{}, err
+	}
+
+	return req, nil
+}
+
+// listPostComments paginates the top-level comments. Their replies come along, all of them.
+func (h *handler) listPostComments(c *gin.Context, request common.ListPostCommentsRequest) (common.ListPostCommentsResponse, error) {
+	page, perPage := request.Page, request.PerPage
+
+	// Check the post is visible
+	if _, err := h.getPost(c, common.GetPostRequest{PostID: request.PostID}); err != nil {
+		return common.ListPostCommentsResponse{}, common.Wrap("listPostComments: getPost", err)
+	}
+
+	comments, total, err := h.repos.Comments.ListTopLevel(request.PostID, page*perPage, perPage)
+	if err != nil {
+		return common.ListPostCommentsResponse{}, common.Wrap("listPostComments: repos.Comments.ListTopLevel", err)
+	}
+
+	return common.ListPostCommentsResponse{
+		Comments: comments.ToResponseModel(),
+		Page:     page,
+		PerPage:  perPage,
+		Total:    total,
+	}, nil
+}
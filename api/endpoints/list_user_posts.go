@@ -0,0 +1,50 @@
+package endpoints
+
+import (
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) ListUserPosts(c *gin.Context) {
+	HandleRequest(c, h.makeListUserPostsRequest, h.listUserPosts)
+}
+
+func (h *handler) makeListUserPostsRequest(c *gin.Context) (req common.ListUserPostsRequest, err error) {
+	req.UserID = c.GetInt(contextUserIDKey)
+	if req.UserID == 0 {
+		return common.ListUserPostsRequest{}, common.ErrAllFieldsRequired
+	}
+
+	req.Status = c.Query("status")
+	if req.Status != "" && !common.IsValidPostStatus(req.Status) {
+		return common.ListUserPostsRequest{}, common.ErrInvalidValue("status")
+	}
+
+	if req.Page, req.PerPage, err = getPaginationFromQuery(c); err != nil {
+		return common.ListUserPostsRequest{}, err
+	}
+
+	return req, nil
+}
+
+// listUserPosts returns the newest posts first, in any status. Only the owner gets here.
+func (h *handler) listUserPosts(c *gin.Context, request common.ListUserPostsRequest) (common.ListUserPostsResponse, error) {
+	page, perPage := request.Page, request.PerPage
+
+	userPosts, total, err := h.repos.Posts.ListUserPosts(request.UserID, request.Status, page*perPage, perPage)
+	if err != nil {
+		return common.ListUserPostsResponse{}, common.Wrap("listUserPosts: repos.Posts.ListUserPosts", err)
+	}
+
+	if err := h.repos.Posts.LoadCounts(userPosts); err != nil {
+		return common.ListUserPostsResponse{}, common.Wrap("listUserPosts: repos.Posts.LoadCounts", err)
+	}
+
+	return common.ListUserPostsResponse{
+		UserPosts: userPosts.ToResponseModel(),
+		Page:      page,
+		PerPage:   perPage,
+		Total:     total,
+	}, nil
+}
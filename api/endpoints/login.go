@@ -2,11 +2,12 @@ package endpoints
 
 import (
 	"errors"
+	"fmt"
+	"math"
 
 	"github.com/gilperopiola/go-rest-example-small/api/common"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 func (h *handler) Login(c *gin.Context) {
@@ -23,31 +24,99 @@ func (h *handler) makeLoginRequest(c *gin.Context) (req common.LoginRequest, err
 		return common.LoginRequest{}, common.Wrap("makeLoginRequest", common.ErrAllFieldsRequired)
 	}
 
+	req.ClientIP = c.ClientIP()
+
 	return req, nil
 }
 
 func (h *handler) login(c *gin.Context, request common.LoginRequest) (common.LoginResponse, error) {
 	user := request.ToUserModel()
+	ipKey := common.IPThrottleKey(request.ClientIP)
+
+	// Check this IP isn't throttled
+	if err := h.checkLoginThrottle(c, ipKey); err != nil {
+		return common.LoginResponse{}, common.Wrap("login: checkLoginThrottle", err)
+	}
 
 	// Get user
-	query := "(username = ? OR email = ?) AND deleted = false"
-	if err := h.db.Where(query, user.Username, user.Email).First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return common.LoginResponse{}, common.Wrap(err.Error(), common.ErrUserNotFound)
+	user, err := h.repos.Users.GetActiveByUsernameOrEmail(user.Username, user.Email)
+	if err != nil {
+		if errors.Is(err, common.ErrUserNotFound) {
+			if err := h.loginThrottler.RegisterFailure(ipKey); err != nil {
+				return common.LoginResponse{}, common.Wrap("login: loginThrottler.RegisterFailure", err)
+			}
 		}
-		return common.LoginResponse{}, common.Wrap(err.Error(), common.ErrGettingUser)
+		return common.LoginResponse{}, common.Wrap("login: repos.Users.GetActiveByUsernameOrEmail", err)
+	}
+
+	// Check this account isn't throttled
+	userKey := common.UserThrottleKey(user.ID)
+	if err := h.checkLoginThrottle(c, userKey); err != nil {
+		return common.LoginResponse{}, common.Wrap("login: checkLoginThrottle", err)
 	}
 
 	// Check password
-	if user.Password != common.Hash(request.Password, h.config.HashSalt) {
-		return common.LoginResponse{}, common.Wrap("login: user.Password != common.Hash", common.ErrWrongPassword)
+	matches, needsRehash := user.PasswordMatches(request.Password, h.hasher)
+	if !matches {
+		if err := h.loginThrottler.RegisterFailure(ipKey, userKey); err != nil {
+			return common.LoginResponse{}, common.Wrap("login: loginThrottler.RegisterFailure", err)
+		}
+		return common.LoginResponse{}, common.Wrap("login: !user.PasswordMatches", common.ErrWrongPassword)
+	}
+
+	// Successful login, the user's counter starts over. The IP one is left to expire
+	// on its own, or a valid account would let an attacker keep spraying passwords
+	if err := h.loginThrottler.Reset(userKey); err != nil {
+		return common.LoginResponse{}, common.Wrap("login: loginThrottler.Reset", err)
+	}
+
+	// Check email is verified
+	if h.config.Emails.VerificationRequired && !user.Verified {
+		return common.LoginResponse{}, common.Wrap("login: !user.Verified", common.ErrEmailNotVerified)
+	}
+
+	// Upgrade outdated hashes now that we have the plain password.
+	// Not critical, if it fails it will be retried on the next login.
+	if needsRehash {
+		h.rehashPassword(user, request.Password)
+	}
+
+	// With 2FA, the tokens are only given on /v1/login/mfa
+	if user.TOTPEnabled {
+		challengeToken, err := h.auth.GenerateChallengeToken(user.ID)
+		if err != nil {
+			return common.LoginResponse{}, common.Wrap("login: auth.GenerateChallengeToken", common.ErrUnauthorized)
+		}
+		return common.LoginResponse{MFARequired: true, ChallengeToken: challengeToken}, nil
 	}
 
-	// Generate token
-	tokenString, err := h.auth.GenerateToken(user.ID, user.Username, user.Email, user.GetRole())
+	// Generate access & refresh tokens
+	tokenString, refreshTokenString, err := h.generateSessionTokens(user, "", false)
 	if err != nil {
-		return common.LoginResponse{}, common.Wrap("login: auth.GenerateToken", common.ErrUnauthorized)
+		return common.LoginResponse{}, common.Wrap("login: generateSessionTokens", err)
+	}
+
+	return common.LoginResponse{Token: tokenString, RefreshToken: refreshTokenString}, nil
+}
+
+// checkLoginThrottle sets the Retry-After header if the key is throttled
+func (h *handler) checkLoginThrottle(c *gin.Context, key string) error {
+	retryAfter, err := h.loginThrottler.Check(key)
+	if err != nil && retryAfter > 0 {
+		c.Header("Retry-After", fmt.Sprint(int(math.Ceil(retryAfter.Seconds()))))
 	}
+	return err
+}
 
-	return common.LoginResponse{Token: tokenString}, nil
+func (h *handler) rehashPassword(user common.User, password string) {
+	logger := h.logger.WithField("user_id", user.ID)
+
+	user.Password = password
+	if err := user.HashPassword(h.hasher); err != nil {
+		logger.Error("rehashPassword: user.HashPassword: " + err.Error())
+		return
+	}
+	if err := h.repos.Users.UpdatePassword(user.ID, user.Password); err != nil {
+		logger.Error("rehashPassword: repos.Users.UpdatePassword: " + err.Error())
+	}
 }
@@ -0,0 +1,120 @@
+package endpoints
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) LoginMFA(c *gin.Context) {
+	HandleRequest(c, h.makeLoginMFARequest, h.loginMFA)
+}
+
+func (h *handler) makeLoginMFARequest(c *gin.Context) (req common.LoginMFARequest, err error) {
+
+	if err = c.ShouldBindJSON(&req); err != nil {
+		return common.LoginMFARequest{}, common.Wrap(err.Error(), common.ErrBindingRequest)
+	}
+
+	if req.ChallengeToken == "" || req.Code == "" {
+		return common.LoginMFARequest{}, common.Wrap("makeLoginMFARequest", common.ErrAllFieldsRequired)
+	}
+
+	req.ClientIP = c.ClientIP()
+
+	return req, nil
+}
+
+func (h *handler) loginMFA(c *gin.Context, request common.LoginMFARequest) (common.LoginMFAResponse, error) {
+
+	// Validate challenge
+	claims, err := h.auth.ValidateChallengeToken(request.ChallengeToken)
+	if err != nil {
+		return common.LoginMFAResponse{}, common.Wrap("loginMFA: auth.ValidateChallengeToken", err)
+	}
+	userID, _ := strconv.Atoi(claims.Subject)
+
+	// Codes are short, so they're throttled just like passwords
+	ipKey, userKey := common.IPThrottleKey(request.ClientIP), common.UserThrottleKey(userID)
+	if err := h.checkLoginThrottle(c, ipKey); err != nil {
+		return common.LoginMFAResponse{}, common.Wrap("loginMFA: checkLoginThrottle", err)
+	}
+	if err := h.checkLoginThrottle(c, userKey); err != nil {
+		return common.LoginMFAResponse{}, common.Wrap("loginMFA: checkLoginThrottle", err)
+	}
+
+	// Get user
+	user, err := h.repos.Users.GetActive(userID)
+	if err != nil {
+		return common.LoginMFAResponse{}, common.Wrap("loginMFA: repos.Users.GetActive", err)
+	}
+
+	if !user.TOTPEnabled {
+		return common.LoginMFAResponse{}, common.Wrap("loginMFA: !user.TOTPEnabled", common.ErrTOTPNotEnabled)
+	}
+
+	// Check code
+	if err := h.verifySecondFactor(user, request.Code); err != nil {
+		if errors.Is(err, common.ErrInvalidMFACode) {
+			if err := h.loginThrottler.RegisterFailure(ipKey, userKey); err != nil {
+				return common.LoginMFAResponse{}, common.Wrap("loginMFA: loginThrottler.RegisterFailure", err)
+			}
+		}
+		return common.LoginMFAResponse{}, common.Wrap("loginMFA: verifySecondFactor", err)
+	}
+
+	// Challenges can only be used once
+	if err := h.auth.RevokeToken(claims.ID, claims.ExpiresAt.Time); err != nil {
+		return common.LoginMFAResponse{}, common.Wrap("loginMFA: auth.RevokeToken", err)
+	}
+
+	// Only the user's counter, same as on login
+	if err := h.loginThrottler.Reset(userKey); err != nil {
+		return common.LoginMFAResponse{}, common.Wrap("loginMFA: loginThrottler.Reset", err)
+	}
+
+	// Generate access & refresh tokens
+	tokenString, refreshTokenString, err := h.generateSessionTokens(user, "", true)
+	if err != nil {
+		return common.LoginMFAResponse{}, common.Wrap("loginMFA: generateSessionTokens", err)
+	}
+
+	return common.LoginMFAResponse{Token: tokenString, RefreshToken: refreshTokenString}, nil
+}
+
+/*-----------------------
+//       HELPERS
+//---------------------*/
+
+// verifySecondFactor accepts either a TOTP code or an unused recovery code. Both can only be used once.
+func (h *handler) verifySecondFactor(user common.User, code string) error {
+	code = strings.TrimSpace(code)
+
+	// TOTP code
+	if step, ok := common.ValidateTOTPCode(user.TOTPSecret, code, time.Now()); ok {
+		used, err := h.repos.Users.UseTOTPStep(user.ID, step)
+		if err != nil {
+			return common.Wrap("verifySecondFactor: repos.Users.UseTOTPStep", err)
+		}
+		if !used {
+			return common.Wrap("verifySecondFactor: code already used", common.ErrInvalidMFACode)
+		}
+		return nil
+	}
+
+	// Recovery code
+	used, err := h.repos.Tokens.UseRecoveryCode(user.ID, common.HashRecoveryCode(code))
+	if err != nil {
+		return common.Wrap("verifySecondFactor: repos.Tokens.UseRecoveryCode", err)
+	}
+	if !used {
+		return common.Wrap("verifySecondFactor: invalid code", common.ErrInvalidMFACode)
+	}
+
+	return nil
+}
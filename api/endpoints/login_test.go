@@ -0,0 +1,37 @@
+package endpoints
+
+import (
+	"testing"
+
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogin_SuccessDoesntResetIPThrottle(t *testing.T) {
+	h, _ := newTestHandler(t)
+	signupTestUser(t, h, "alice", "alice@example.com")
+	signupTestUser(t, h, "bob", "bob@example.com")
+
+	// All of the free attempts from the IP are spent on bob, then the attacker logs into their own account
+	wrongPassword := common.LoginRequest{UsernameOrEmail: "bob", Password: "wrong-password", ClientIP: "10.0.0.1"}
+	for i := 0; i < h.config.Lockout.FreeAttempts; i++ {
+		_, err := h.login(newTestContext(0), wrongPassword)
+		require.ErrorIs(t, err, common.ErrWrongPassword)
+	}
+
+	_, err := h.login(newTestContext(0), common.LoginRequest{UsernameOrEmail: "alice", Password: "alice-password", ClientIP: "10.0.0.1"})
+	require.NoError(t, err)
+
+	// The IP keeps its count, so the next failure makes it wait
+	_, err = h.login(newTestContext(0), wrongPassword)
+	require.ErrorIs(t, err, common.ErrWrongPassword)
+
+	_, err = h.login(newTestContext(0), common.LoginRequest{UsernameOrEmail: "alice", Password: "alice-password", ClientIP: "10.0.0.1"})
+	assert.ErrorIs(t, err, common.ErrTooManyLoginAttempts)
+
+	// Other IPs aren't affected
+	_, err = h.login(newTestContext(0), common.LoginRequest{UsernameOrEmail: "alice", Password: "alice-password", ClientIP: "10.0.0.2"})
+	assert.NoError(t, err)
+}
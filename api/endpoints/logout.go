@@ -0,0 +1,55 @@
+package endpoints
+
+import (
+	"errors"
+	"io"
+
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) Logout(c *gin.Context) {
+	HandleRequest(c, h.makeLogoutRequest, h.logout)
+}
+
+func (h *handler) makeLogoutRequest(c *gin.Context) (req common.LogoutRequest, err error) {
+
+	// Body is optional
+	if err = c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		return common.LogoutRequest{}, common.Wrap(err.Error(), common.ErrBindingRequest)
+	}
+
+	req.UserID = c.GetInt(contextUserIDKey)
+	req.TokenID = c.GetString(contextTokenIDKey)
+	req.TokenExpiresAt = c.GetTime(contextTokenExpiresAtKey)
+	if req.UserID == 0 || req.TokenID == "" {
+		return common.LogoutRequest{}, common.ErrAllFieldsRequired
+	}
+
+	return req, nil
+}
+
+func (h *handler) logout(c *gin.Context, request common.LogoutRequest) (common.LogoutResponse, error) {
+
+	// Revoke access token
+	if err := h.auth.RevokeToken(request.TokenID, request.TokenExpiresAt); err != nil {
+		return common.LogoutResponse{}, common.Wrap("logout: auth.RevokeToken", err)
+	}
+
+	if request.RefreshToken == "" {
+		return common.LogoutResponse{LoggedOut: true}, nil
+	}
+
+	// Revoke refresh token family, only if it belongs to this user
+	refreshToken, err := h.repos.Tokens.GetUserRefreshToken(common.HashOpaqueToken(request.RefreshToken), request.UserID)
+	if err != nil {
+		return common.LogoutResponse{}, common.Wrap("logout: repos.Tokens.GetUserRefreshToken", err)
+	}
+
+	if err := h.revokeRefreshTokenFamily(refreshToken.FamilyID); err != nil {
+		return common.LogoutResponse{}, common.Wrap("logout: revokeRefreshTokenFamily", err)
+	}
+
+	return common.LogoutResponse{LoggedOut: true}, nil
+}
@@ -0,0 +1,67 @@
+package endpoints
+
+import (
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) ReactToPost(c *gin.Context) {
+	HandleRequest(c, h.makeReactToPostRequest, h.reactToPost)
+}
+
+func (h *handler) makeReactToPostRequest(c *gin.Context) (req common.ReactToPostRequest, err error) {
+	req.UserID = c.GetInt(contextUserIDKey)
+	if req.UserID == 0 {
+		return common.ReactToPostRequest{}, common.ErrAllFieldsRequired
+	}
+
+	req.PostID = getIntFromPath(c, pathPostIDKey)
+	if req.PostID == 0 {
+		return common.ReactToPostRequest{}, common.ErrInvalidValue(pathPostIDKey)
+	}
+
+	req.Kind = c.Param(pathKindKey)
+	if !isValidReactionKind(req.Kind) {
+		return common.ReactToPostRequest{}, common.ErrInvalidValue(pathKindKey)
+	}
+
+	return req, nil
+}
+
+// reactToPost doesn't fail if the user already reacted. The unique index takes care of concurrent requests.
+func (h *handler) reactToPost(c *gin.Context, request common.ReactToPostRequest) (common.ReactToPostResponse, error) {
+
+	// Check the post is visible
+	if _, err := h.getPost(c, common.GetPostRequest{PostID: request.PostID}); err != nil {
+		return common.ReactToPostResponse{}, common.Wrap("reactToPost: getPost", err)
+	}
+
+	// Create reaction
+	reaction := request.ToPostReactionModel()
+	if err := h.repos.Posts.React(&reaction); err != nil {
+		return common.ReactToPostResponse{}, common.Wrap("reactToPost: repos.Posts.React", err)
+	}
+
+	// Return the new counts
+	posts := common.UserPosts{{ID: request.PostID}}
+	if err := h.repos.Posts.LoadCounts(posts); err != nil {
+		return common.ReactToPostResponse{}, common.Wrap("reactToPost: repos.Posts.LoadCounts", err)
+	}
+
+	return common.ReactToPostResponse{
+		PostID:         request.PostID,
+		Kind:           request.Kind,
+		Reacted:        true,
+		ReactionCounts: posts[0].ReactionCounts,
+	}, nil
+}
+
+func isValidReactionKind(kind string) bool {
+	for _, reactionKind := range common.ReactionKinds {
+		if kind == reactionKind {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package endpoints
+
+import (
+	"time"
+
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) RefreshToken(c *gin.Context) {
+	HandleRequest(c, h.makeRefreshTokenRequest, h.refreshToken)
+}
+
+func (h *handler) makeRefreshTokenRequest(c *gin.Context) (req common.RefreshTokenRequest, err error) {
+
+	if err = c.ShouldBindJSON(&req); err != nil {
+		return common.RefreshTokenRequest{}, common.Wrap(err.Error(), common.ErrBindingRequest)
+	}
+
+	if req.RefreshToken == "" {
+		return common.RefreshTokenRequest{}, common.Wrap("makeRefreshTokenRequest", common.ErrAllFieldsRequired)
+	}
+
+	return req, nil
+}
+
+func (h *handler) refreshToken(c *gin.Context, request common.RefreshTokenRequest) (common.RefreshTokenResponse, error) {
+
+	// Get refresh token
+	refreshToken, err := h.repos.Tokens.GetRefreshToken(common.HashOpaqueToken(request.RefreshToken))
+	if err != nil {
+		return common.RefreshTokenResponse{}, common.Wrap("refreshToken: repos.Tokens.GetRefreshToken", err)
+	}
+
+	// If it was already used, someone is replaying it. Revoke the whole family
+	if refreshToken.WasUsed() {
+		if err := h.revokeRefreshTokenFamily(refreshToken.FamilyID); err != nil {
+			return common.RefreshTokenResponse{}, common.Wrap("refreshToken: revokeRefreshTokenFamily", err)
+		}
+		return common.RefreshTokenResponse{}, common.Wrap("refreshToken: refreshToken.WasUsed", common.ErrRefreshTokenReused)
+	}
+
+	if refreshToken.IsExpired() {
+		return common.RefreshTokenResponse{}, common.Wrap("refreshToken: refreshToken.IsExpired", common.ErrRefreshTokenExpired)
+	}
+
+	// Mark it as used. Only one concurrent request can win this update
+	used, err := h.repos.Tokens.UseRefreshToken(refreshToken.ID)
+	if err != nil {
+		return common.RefreshTokenResponse{}, common.Wrap("refreshToken: repos.Tokens.UseRefreshToken", err)
+	}
+	if !used {
+		if err := h.revokeRefreshTokenFamily(refreshToken.FamilyID); err != nil {
+			return common.RefreshTokenResponse{}, common.Wrap("refreshToken: revokeRefreshTokenFamily", err)
+		}
+		return common.RefreshTokenResponse{}, common.Wrap("refreshToken: !used", common.ErrRefreshTokenReused)
+	}
+
+	// Get user
+	user, err := h.repos.Users.GetActive(refreshToken.UserID)
+	if err != nil {
+		return common.RefreshTokenResponse{}, common.Wrap("refreshToken: repos.Users.GetActive", err)
+	}
+
+	// Generate new pair of tokens, same family
+	accessToken, newRefreshToken, err := h.generateSessionTokens(user, refreshToken.FamilyID, refreshToken.MFA)
+	if err != nil {
+		return common.RefreshTokenResponse{}, common.Wrap("refreshToken: generateSessionTokens", err)
+	}
+
+	return common.RefreshTokenResponse{Token: accessToken, RefreshToken: newRefreshToken}, nil
+}
+
+/*-----------------------
+//       HELPERS
+//---------------------*/
+
+// generateSessionTokens returns a new access token and a new refresh token for the user.
+// If familyID is empty, a new family is started (e.g. on login). mfa is kept along the whole family.
+func (h *handler) generateSessionTokens(user common.User, familyID string, mfa bool) (string, string, error) {
+
+	// Roles and permissions go inside of the access token
+	user, err := h.repos.Users.GetWithPermissions(user.ID)
+	if err != nil {
+		return "", "", common.Wrap("generateSessionTokens: repos.Users.GetWithPermissions", err)
+	}
+
+	// Generate access token
+	accessToken, err := user.GenerateTokenString(h.auth, mfa)
+	if err != nil {
+		return "", "", common.Wrap("generateSessionTokens: auth.GenerateToken", common.ErrUnauthorized)
+	}
+
+	// Generate refresh token
+	refreshTokenString, err := common.GenerateOpaqueToken()
+	if err != nil {
+		return "", "", common.Wrap(err.Error(), common.ErrCreatingRefreshToken)
+	}
+
+	if familyID == "" {
+		if familyID, err = common.GenerateOpaqueToken(); err != nil {
+			return "", "", common.Wrap(err.Error(), common.ErrCreatingRefreshToken)
+		}
+	}
+
+	// Save it hashed
+	refreshToken := common.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: common.HashOpaqueToken(refreshTokenString),
+		FamilyID:  familyID,
+		MFA:       mfa,
+		ExpiresAt: time.Now().Add(time.Hour * 24 * time.Duration(h.config.Sessions.RefreshTokenDays)),
+	}
+	if err := h.repos.Tokens.CreateRefreshToken(&refreshToken); err != nil {
+		return "", "", common.Wrap("generateSessionTokens: repos.Tokens.CreateRefreshToken", err)
+	}
+
+	return accessToken, refreshTokenString, nil
+}
+
+func (h *handler) revokeRefreshTokenFamily(familyID string) error {
+	if err := h.repos.Tokens.RevokeRefreshTokenFamily(familyID); err != nil {
+		return common.Wrap("revokeRefreshTokenFamily: repos.Tokens.RevokeRefreshTokenFamily", err)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package endpoints
+
+import (
+	"testing"
+
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshToken_ReuseRevokesFamily(t *testing.T) {
+	h, _ := newTestHandler(t)
+	signupTestUser(t, h, "alice", "alice@example.com")
+	session := loginTestUser(t, h, "alice")
+	otherSession := loginTestUser(t, h, "alice")
+
+	rotated, err := h.refreshToken(newTestContext(0), common.RefreshTokenRequest{RefreshToken: session.RefreshToken})
+	require.NoError(t, err)
+
+	// Replaying the old one fails, and so does the one it was rotated into
+	_, err = h.refreshToken(newTestContext(0), common.RefreshTokenRequest{RefreshToken: session.RefreshToken})
+	assert.ErrorIs(t, err, common.ErrRefreshTokenReused)
+
+	_, err = h.refreshToken(newTestContext(0), common.RefreshTokenRequest{RefreshToken: rotated.RefreshToken})
+	assert.ErrorIs(t, err, common.ErrRefreshTokenReused)
+
+	// Other families are left alone
+	_, err = h.refreshToken(newTestContext(0), common.RefreshTokenRequest{RefreshToken: otherSession.RefreshToken})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,52 @@
+package endpoints
+
+import (
+	"errors"
+
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) ResendVerificationEmail(c *gin.Context) {
+	HandleRequest(c, h.makeResendVerificationEmailRequest, h.resendVerificationEmail)
+}
+
+func (h *handler) makeResendVerificationEmailRequest(c *gin.Context) (req common.ResendVerificationEmailRequest, err error) {
+
+	if err = c.ShouldBindJSON(&req); err != nil {
+		return common.ResendVerificationEmailRequest{}, common.Wrap(err.Error(), common.ErrBindingRequest)
+	}
+
+	if req.UsernameOrEmail == "" {
+		return common.ResendVerificationEmailRequest{}, common.Wrap("makeResendVerificationEmailRequest", common.ErrAllFieldsRequired)
+	}
+
+	return req, nil
+}
+
+func (h *handler) resendVerificationEmail(c *gin.Context, request common.ResendVerificationEmailRequest) (common.ResendVerificationEmailResponse, error) {
+	user := request.ToUserModel()
+
+	// We don't tell the caller if the user exists or is already verified
+	response := common.ResendVerificationEmailResponse{Message: "if the user exists and isn't verified, an email has been sent"}
+
+	// Get user
+	user, err := h.repos.Users.GetActiveByUsernameOrEmail(user.Username, user.Email)
+	if err != nil {
+		if errors.Is(err, common.ErrUserNotFound) {
+			return response, nil
+		}
+		return common.ResendVerificationEmailResponse{}, common.Wrap("resendVerificationEmail: repos.Users.GetActiveByUsernameOrEmail", err)
+	}
+
+	if user.Verified {
+		return response, nil
+	}
+
+	if err := h.sendVerificationEmail(user); err != nil {
+		return common.ResendVerificationEmailResponse{}, common.Wrap("resendVerificationEmail: sendVerificationEmail", err)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,73 @@
+package endpoints
+
+import (
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) ResetPassword(c *gin.Context) {
+	HandleRequest(c, h.makeResetPasswordRequest, h.resetPassword)
+}
+
+func (h *handler) makeResetPasswordRequest(c *gin.Context) (req common.ResetPasswordRequest, err error) {
+
+	if err = c.ShouldBindJSON(&req); err != nil {
+		return common.ResetPasswordRequest{}, common.Wrap(err.Error(), common.ErrBindingRequest)
+	}
+
+	if req.Token == "" {
+		return common.ResetPasswordRequest{}, common.ErrAllFieldsRequired
+	}
+
+	if err = validateNewPassword(req.NewPassword, req.RepeatPassword); err != nil {
+		return common.ResetPasswordRequest{}, common.Wrap("makeResetPasswordRequest", err)
+	}
+
+	return req, nil
+}
+
+func (h *handler) resetPassword(c *gin.Context, request common.ResetPasswordRequest) (common.ResetPasswordResponse, error) {
+
+	// Get token
+	resetToken, err := h.repos.Tokens.GetPasswordResetToken(common.HashOpaqueToken(request.Token))
+	if err != nil {
+		return common.ResetPasswordResponse{}, common.Wrap("resetPassword: repos.Tokens.GetPasswordResetToken", err)
+	}
+
+	if !resetToken.IsUsable() {
+		return common.ResetPasswordResponse{}, common.Wrap("resetPassword: !resetToken.IsUsable", common.ErrInvalidPasswordResetToken)
+	}
+
+	// Mark it as used. Only one concurrent request can win this update
+	used, err := h.repos.Tokens.UsePasswordResetToken(resetToken.ID)
+	if err != nil {
+		return common.ResetPasswordResponse{}, common.Wrap("resetPassword: repos.Tokens.UsePasswordResetToken", err)
+	}
+	if !used {
+		return common.ResetPasswordResponse{}, common.Wrap("resetPassword: !used", common.ErrInvalidPasswordResetToken)
+	}
+
+	// Get user
+	user, err := h.repos.Users.GetActive(resetToken.UserID)
+	if err != nil {
+		return common.ResetPasswordResponse{}, common.Wrap("resetPassword: repos.Users.GetActive", err)
+	}
+
+	// Hash and update password
+	user.Password = request.NewPassword
+	if err := user.HashPassword(h.hasher); err != nil {
+		return common.ResetPasswordResponse{}, common.Wrap("resetPassword: user.HashPassword", err)
+	}
+
+	if err := h.repos.Users.UpdatePassword(user.ID, user.Password); err != nil {
+		return common.ResetPasswordResponse{}, common.Wrap("resetPassword: repos.Users.UpdatePassword", err)
+	}
+
+	// Whoever had the old password shouldn't keep their sessions
+	if err := h.auth.RevokeAllUserTokens(user.ID); err != nil {
+		return common.ResetPasswordResponse{}, common.Wrap("resetPassword: auth.RevokeAllUserTokens", err)
+	}
+
+	return common.ResetPasswordResponse{User: user.ToResponseModel()}, nil
+}
@@ -0,0 +1,33 @@
+package endpoints
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResetPassword_RevokesSessions(t *testing.T) {
+	h, mailer := newTestHandler(t)
+	signupTestUser(t, h, "alice", "alice@example.com")
+	session := loginTestUser(t, h, "alice")
+	require.NoError(t, validateTestToken(h, session.Token))
+
+	_, err := h.forgotPassword(newTestContext(0), common.ForgotPasswordRequest{UsernameOrEmail: "alice"})
+	require.NoError(t, err)
+	lines := strings.Split(mailer.sent[len(mailer.sent)-1].Body, "\n")
+	resetToken := lines[len(lines)-1]
+
+	request := common.ResetPasswordRequest{Token: resetToken, NewPassword: "new-password", RepeatPassword: "new-password"}
+	_, err = h.resetPassword(newTestContext(0), request)
+	require.NoError(t, err)
+
+	// The token was issued in the same second as the revocation at most, which still counts as before it
+	assert.ErrorIs(t, validateTestToken(h, session.Token), common.ErrTokenRevoked)
+
+	_, err = h.refreshToken(newTestContext(0), common.RefreshTokenRequest{RefreshToken: session.RefreshToken})
+	assert.Error(t, err)
+}
@@ -0,0 +1,42 @@
+package endpoints
+
+import (
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) RevokeUserSessions(c *gin.Context) {
+	HandleRequest(c, h.makeRevokeUserSessionsRequest, h.revokeUserSessions)
+}
+
+func (h *handler) makeRevokeUserSessionsRequest(c *gin.Context) (req common.RevokeUserSessionsRequest, err error) {
+	req.UserID = getIntFromPath(c, pathUserIDKey)
+	if req.UserID == 0 {
+		return common.RevokeUserSessionsRequest{}, common.ErrInvalidValue(pathUserIDKey)
+	}
+
+	return req, nil
+}
+
+func (h *handler) revokeUserSessions(c *gin.Context, request common.RevokeUserSessionsRequest) (common.RevokeUserSessionsResponse, error) {
+	user := request.ToUserModel()
+
+	// Check we can manage them
+	if err := h.checkCanManageUser(c, user.ID); err != nil {
+		return common.RevokeUserSessionsResponse{}, common.Wrap("revokeUserSessions: checkCanManageUser", err)
+	}
+
+	// Get user
+	user, err := h.repos.Users.Get(user.ID)
+	if err != nil {
+		return common.RevokeUserSessionsResponse{}, common.Wrap("revokeUserSessions: repos.Users.Get", err)
+	}
+
+	// Revoke all of their tokens
+	if err := h.auth.RevokeAllUserTokens(user.ID); err != nil {
+		return common.RevokeUserSessionsResponse{}, common.Wrap("revokeUserSessions: auth.RevokeAllUserTokens", err)
+	}
+
+	return common.RevokeUserSessionsResponse{User: user.ToResponseModel()}, nil
+}
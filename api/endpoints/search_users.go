@@ -2,6 +2,8 @@ package endpoints
 
 import (
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gilperopiola/go-rest-example-small/api/common"
 
@@ -12,45 +14,124 @@ func (h *handler) SearchUsers(c *gin.Context) {
 	HandleRequest(c, h.makeSearchUsersRequest, h.searchUsers)
 }
 
-func (h *handler) makeSearchUsersRequest(c *gin.Context) (req common.SearchUsersRequest, err error) {
+const searchUsersDefaultSort = "created_at"
+
+// searchUsersSortFields are the only fields users can be sorted by. They return the value that goes in the cursor.
+var searchUsersSortFields = map[string]func(user common.User) string{
+	"id":         func(user common.User) string { return strconv.Itoa(user.ID) },
+	"username":   func(user common.User) string { return user.Username },
+	"email":      func(user common.User) string { return user.Email },
+	"created_at": func(user common.User) string { return user.CreatedAt.Format(time.RFC3339Nano) },
+	"updated_at": func(user common.User) string { return user.UpdatedAt.Format(time.RFC3339Nano) },
+}
 
-	defaultPage := "0"
-	defaultPerPage := "10"
+func (h *handler) makeSearchUsersRequest(c *gin.Context) (req common.SearchUsersRequest, err error) {
 
 	req.Username = c.Query("username")
 
-	req.Page, err = strconv.Atoi(c.DefaultQuery("page", defaultPage))
-	if err != nil {
-		return common.SearchUsersRequest{}, common.ErrInvalidValue("page")
+	if req.Filters, err = common.ParseUserFilters(c.Query("filter")); err != nil {
+		return common.SearchUsersRequest{}, common.Wrap("makeSearchUsersRequest: ParseUserFilters", err)
 	}
 
-	req.PerPage, err = strconv.Atoi(c.DefaultQuery("per_page", defaultPerPage))
-	if err != nil {
-		return common.SearchUsersRequest{}, common.ErrInvalidValue("per_page")
+	if req.Page, req.PerPage, err = getPaginationFromQuery(c); err != nil {
+		return common.SearchUsersRequest{}, err
 	}
 
-	if req.Page < 0 || req.PerPage <= 0 {
-		return common.SearchUsersRequest{}, common.ErrAllFieldsRequired
+	if req.IncludeTotal, err = strconv.ParseBool(c.DefaultQuery("total", "false")); err != nil {
+		return common.SearchUsersRequest{}, common.ErrInvalidValue("total")
+	}
+
+	// The cursor brings its own sort. If another one is asked for, the cursor is no good
+	req.Sort = c.Query("sort")
+	if encodedCursor := c.Query("cursor"); encodedCursor != "" {
+		cursor, err := common.DecodeCursor(encodedCursor)
+		if err != nil || (req.Sort != "" && req.Sort != cursor.Sort) {
+			return common.SearchUsersRequest{}, common.Wrap("makeSearchUsersRequest", common.ErrInvalidCursor)
+		}
+		req.Cursor, req.Sort = &cursor, cursor.Sort
+	}
+
+	if req.Sort == "" {
+		req.Sort = searchUsersDefaultSort
+	}
+	if _, ok := searchUsersSortFields[strings.TrimPrefix(req.Sort, "-")]; !ok {
+		return common.SearchUsersRequest{}, common.ErrInvalidValue("sort")
 	}
 
 	return req, nil
 }
 
+// searchUsers uses keyset pagination on (sort field, id) if there's a cursor, and offset pagination if there isn't
 func (h *handler) searchUsers(c *gin.Context, request common.SearchUsersRequest) (common.SearchUsersResponse, error) {
 	var (
-		users   common.Users
-		page    = request.Page
-		perPage = request.PerPage
+		page     = request.Page
+		perPage  = request.PerPage
+		field    = strings.TrimPrefix(request.Sort, "-")
+		desc     = strings.HasPrefix(request.Sort, "-")
+		backward = request.Cursor != nil && request.Cursor.Backward
+		response = common.SearchUsersResponse{Page: page, PerPage: perPage, Sort: request.Sort}
 	)
 
-	query := h.db.Preload("Details").Where("username LIKE ?", "%"+request.Username+"%")
-	if err := query.Offset(page * perPage).Limit(perPage).Find(&users).Error; err != nil {
-		return common.SearchUsersResponse{}, common.Wrap(err.Error(), common.ErrSearchingUsers)
+	// Going backward, the order is flipped and the results are reversed afterwards.
+	// One more than needed, to know if there are more.
+	search := common.UserSearch{
+		Username:     request.Username,
+		Filters:      request.Filters,
+		IncludeTotal: request.IncludeTotal,
+		SortField:    field,
+		Descending:   desc != backward,
+		Offset:       page * perPage,
+		Limit:        perPage + 1,
+	}
+	if request.Cursor != nil {
+		value, err := parseSearchUsersCursorValue(field, request.Cursor.Value)
+		if err != nil {
+			return common.SearchUsersResponse{}, common.Wrap("searchUsers: parseSearchUsersCursorValue", common.ErrInvalidCursor)
+		}
+		search.After = &common.KeysetPosition{Value: value, ID: request.Cursor.ID}
+	}
+
+	users, total, err := h.repos.Users.Search(search)
+	if err != nil {
+		return common.SearchUsersResponse{}, common.Wrap("searchUsers: repos.Users.Search", err)
+	}
+	response.Total = total
+
+	hasMore := len(users) > perPage
+	if hasMore {
+		users = users[:perPage]
+	}
+	if backward {
+		for i, j := 0, len(users)-1; i < j; i, j = i+1, j-1 {
+			users[i], users[j] = users[j], users[i]
+		}
+	}
+
+	// Cursors
+	if len(users) > 0 {
+		cursorValue := searchUsersSortFields[field]
+		first, last := users[0], users[len(users)-1]
+
+		if (!backward && hasMore) || backward {
+			response.NextCursor = common.Cursor{Sort: request.Sort, Value: cursorValue(last), ID: last.ID}.Encode()
+		}
+		if (!backward && (request.Cursor != nil || page > 0)) || (backward && hasMore) {
+			response.PrevCursor = common.Cursor{Sort: request.Sort, Value: cursorValue(first), ID: first.ID, Backward: true}.Encode()
+		}
 	}
 
-	return common.SearchUsersResponse{
-		Users:   users.ToResponseModel(),
-		Page:    page,
-		PerPage: perPage,
-	}, nil
+	response.Users = users.ToResponseModel()
+	return response, nil
+}
+
+// parseSearchUsersCursorValue converts the cursor's value back to the type of the field
+func parseSearchUsersCursorValue(field, value string) (interface{}, error) {
+	switch field {
+	case "id":
+		return strconv.Atoi(value)
+	case "created_at", "updated_at":
+		return time.Parse(time.RFC3339Nano, value)
+	default:
+		return value, nil
+	}
 }
@@ -1,8 +1,6 @@
 package endpoints
 
 import (
-	"strings"
-
 	"github.com/gilperopiola/go-rest-example-small/api/common"
 
 	"github.com/gin-gonic/gin"
@@ -31,14 +29,25 @@ func (h *handler) makeSignupRequest(c *gin.Context) (req common.SignupRequest, e
 
 func (h *handler) signup(c *gin.Context, request common.SignupRequest) (common.SignupResponse, error) {
 	user := request.ToUserModel()
-	user.HashPassword(h.config.HashSalt)
+	if err := user.HashPassword(h.hasher); err != nil {
+		return common.SignupResponse{}, common.Wrap("signup: user.HashPassword", err)
+	}
+
+	// Everyone starts as a user
+	roles, err := h.getRolesByName(common.UserRole)
+	if err != nil {
+		return common.SignupResponse{}, common.Wrap("signup: getRolesByName", err)
+	}
+	user.Roles = roles
 
 	// Create user
-	if err := h.db.Create(&user).Error; err != nil {
-		if strings.Contains(err.Error(), "Error 1062") { // Duplicate entry for key
-			return common.SignupResponse{}, common.Wrap(err.Error(), common.ErrUsernameOrEmailAlreadyInUse)
-		}
-		return common.SignupResponse{}, common.Wrap(err.Error(), common.ErrCreatingUser)
+	if err := h.repos.Users.Create(&user); err != nil {
+		return common.SignupResponse{}, common.Wrap("signup: repos.Users.Create", err)
+	}
+
+	// The user is already created, so if this fails they can ask for it again on /v1/verify-email/resend
+	if err := h.sendVerificationEmail(user); err != nil {
+		h.logger.WithField("user_id", user.ID).Error("signup: " + err.Error())
 	}
 
 	return common.SignupResponse{User: user.ToResponseModel()}, nil
@@ -0,0 +1,53 @@
+package endpoints
+
+import (
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) UnassignUserRole(c *gin.Context) {
+	HandleRequest(c, h.makeUnassignUserRoleRequest, h.unassignUserRole)
+}
+
+func (h *handler) makeUnassignUserRoleRequest(c *gin.Context) (req common.UnassignUserRoleRequest, err error) {
+	req.UserID = getIntFromPath(c, pathUserIDKey)
+	req.RoleName = common.RoleName(c.Param(pathRoleNameKey))
+	if req.UserID == 0 || req.RoleName == "" {
+		return common.UnassignUserRoleRequest{}, common.ErrAllFieldsRequired
+	}
+
+	if req.RoleName == common.AdminRole && !hasPermission(c, common.PermissionManageAdmins) {
+		return common.UnassignUserRoleRequest{}, common.Wrap("makeUnassignUserRoleRequest: !hasPermission", common.ErrForbidden)
+	}
+
+	return req, nil
+}
+
+// unassignUserRole is idempotent. The user keeps the old permissions until their access token expires.
+func (h *handler) unassignUserRole(c *gin.Context, request common.UnassignUserRoleRequest) (common.UnassignUserRoleResponse, error) {
+
+	// Get user
+	user, err := h.repos.Users.Get(request.UserID)
+	if err != nil {
+		return common.UnassignUserRoleResponse{}, common.Wrap("unassignUserRole: repos.Users.Get", err)
+	}
+
+	// Get role
+	roles, err := h.getRolesByName(request.RoleName)
+	if err != nil {
+		return common.UnassignUserRoleResponse{}, common.Wrap("unassignUserRole: getRolesByName", err)
+	}
+
+	// Check we have all of its permissions
+	if err := checkCanHandOutRoles(c, roles); err != nil {
+		return common.UnassignUserRoleResponse{}, common.Wrap("unassignUserRole: checkCanHandOutRoles", err)
+	}
+
+	// Unassign it
+	if err := h.repos.Users.RemoveRoles(&user, roles); err != nil {
+		return common.UnassignUserRoleResponse{}, common.Wrap("unassignUserRole: repos.Users.RemoveRoles", err)
+	}
+
+	return common.UnassignUserRoleResponse{User: user.ToResponseModel()}, nil
+}
@@ -0,0 +1,42 @@
+package endpoints
+
+import (
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) UnlockUser(c *gin.Context) {
+	HandleRequest(c, h.makeUnlockUserRequest, h.unlockUser)
+}
+
+func (h *handler) makeUnlockUserRequest(c *gin.Context) (req common.UnlockUserRequest, err error) {
+	req.UserID = getIntFromPath(c, pathUserIDKey)
+	if req.UserID == 0 {
+		return common.UnlockUserRequest{}, common.ErrInvalidValue(pathUserIDKey)
+	}
+
+	return req, nil
+}
+
+func (h *handler) unlockUser(c *gin.Context, request common.UnlockUserRequest) (common.UnlockUserResponse, error) {
+	user := request.ToUserModel()
+
+	// Check we can manage them
+	if err := h.checkCanManageUser(c, user.ID); err != nil {
+		return common.UnlockUserResponse{}, common.Wrap("unlockUser: checkCanManageUser", err)
+	}
+
+	// Get user
+	user, err := h.repos.Users.Get(user.ID)
+	if err != nil {
+		return common.UnlockUserResponse{}, common.Wrap("unlockUser: repos.Users.Get", err)
+	}
+
+	// Reset their failed logins counter
+	if err := h.loginThrottler.Reset(common.UserThrottleKey(user.ID)); err != nil {
+		return common.UnlockUserResponse{}, common.Wrap("unlockUser: loginThrottler.Reset", err)
+	}
+
+	return common.UnlockUserResponse{User: user.ToResponseModel()}, nil
+}
@@ -0,0 +1,57 @@
+package endpoints
+
+import (
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) UnreactToPost(c *gin.Context) {
+	HandleRequest(c, h.makeUnreactToPostRequest, h.unreactToPost)
+}
+
+func (h *handler) makeUnreactToPostRequest(c *gin.Context) (req common.UnreactToPostRequest, err error) {
+	req.UserID = c.GetInt(contextUserIDKey)
+	if req.UserID == 0 {
+		return common.UnreactToPostRequest{}, common.ErrAllFieldsRequired
+	}
+
+	req.PostID = getIntFromPath(c, pathPostIDKey)
+	if req.PostID == 0 {
+		return common.UnreactToPostRequest{}, common.ErrInvalidValue(pathPostIDKey)
+	}
+
+	req.Kind = c.Param(pathKindKey)
+	if !isValidReactionKind(req.Kind) {
+		return common.UnreactToPostRequest{}, common.ErrInvalidValue(pathKindKey)
+	}
+
+	return req, nil
+}
+
+// unreactToPost doesn't fail if the user hadn't reacted
+func (h *handler) unreactToPost(c *gin.Context, request common.UnreactToPostRequest) (common.UnreactToPostResponse, error) {
+
+	// Check the post is visible
+	if _, err := h.getPost(c, common.GetPostRequest{PostID: request.PostID}); err != nil {
+		return common.UnreactToPostResponse{}, common.Wrap("unreactToPost: getPost", err)
+	}
+
+	// Delete reaction
+	if err := h.repos.Posts.Unreact(request.PostID, request.UserID, request.Kind); err != nil {
+		return common.UnreactToPostResponse{}, common.Wrap("unreactToPost: repos.Posts.Unreact", err)
+	}
+
+	// Return the new counts
+	posts := common.UserPosts{{ID: request.PostID}}
+	if err := h.repos.Posts.LoadCounts(posts); err != nil {
+		return common.UnreactToPostResponse{}, common.Wrap("unreactToPost: repos.Posts.LoadCounts", err)
+	}
+
+	return common.UnreactToPostResponse{
+		PostID:         request.PostID,
+		Kind:           request.Kind,
+		Reacted:        false,
+		ReactionCounts: posts[0].ReactionCounts,
+	}, nil
+}
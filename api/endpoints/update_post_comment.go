@@ -0,0 +1,61 @@
+package endpoints
+
+import (
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) UpdatePostComment(c *gin.Context) {
+	HandleRequest(c, h.makeUpdatePostCommentRequest, h.updatePostComment)
+}
+
+func (h *handler) makeUpdatePostCommentRequest(c *gin.Context) (req common.UpdatePostCommentRequest, err error) {
+
+	if err = c.ShouldBindJSON(&req); err != nil {
+		return common.UpdatePostCommentRequest{}, common.Wrap(err.Error(), common.ErrBindingRequest)
+	}
+
+	req.UserID = c.GetInt(contextUserIDKey)
+	if req.UserID == 0 {
+		return common.UpdatePostCommentRequest{}, common.ErrAllFieldsRequired
+	}
+
+	req.PostID = getIntFromPath(c, pathPostIDKey)
+	if req.PostID == 0 {
+		return common.UpdatePostCommentRequest{}, common.ErrInvalidValue(pathPostIDKey)
+	}
+
+	req.CommentID = getIntFromPath(c, pathCommentIDKey)
+	if req.CommentID == 0 {
+		return common.UpdatePostCommentRequest{}, common.ErrInvalidValue(pathCommentIDKey)
+	}
+
+	if req.Body == "" || len(req.Body) > commentMaxLength {
+		return common.UpdatePostCommentRequest{}, common.ErrInvalidCommentLength(commentMaxLength)
+	}
+
+	return req, nil
+}
+
+// updatePostComment can only be done by the author
+func (h *handler) updatePostComment(c *gin.Context, request common.UpdatePostCommentRequest) (common.UpdatePostCommentResponse, error) {
+
+	// Get comment
+	comment, err := h.repos.Comments.Get(request.PostID, request.CommentID)
+	if err != nil {
+		return common.UpdatePostCommentResponse{}, common.Wrap("updatePostComment: repos.Comments.Get", err)
+	}
+
+	if comment.UserID != request.UserID {
+		return common.UpdatePostCommentResponse{}, common.Wrap("updatePostComment: comment.UserID != request.UserID", common.ErrForbidden)
+	}
+
+	// Update comment
+	comment.Body = request.Body
+	if err := h.repos.Comments.UpdateBody(&comment); err != nil {
+		return common.UpdatePostCommentResponse{}, common.Wrap("updatePostComment: repos.Comments.UpdateBody", err)
+	}
+
+	return common.UpdatePostCommentResponse{Comment: comment.ToResponseModel()}, nil
+}
@@ -1,13 +1,9 @@
 package endpoints
 
 import (
-	"errors"
-	"strings"
-
 	"github.com/gilperopiola/go-rest-example-small/api/common"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 func (h *handler) UpdateUser(c *gin.Context) {
@@ -26,29 +22,28 @@ func (h *handler) makeUpdateUserRequest(c *gin.Context) (req common.UpdateUserRe
 		return common.UpdateUserRequest{}, common.ErrAllFieldsRequired
 	}
 
-	if req.Email != "" && !validEmailRegex.MatchString(req.Email) {
-		return common.UpdateUserRequest{}, common.ErrInvalidEmailFormat
-	}
-
-	if req.Username != "" {
-		if len(req.Username) < usernameMinLength || len(req.Username) > usernameMaxLength {
-			return common.UpdateUserRequest{}, common.ErrInvalidUsernameLength(usernameMinLength, usernameMaxLength)
-		}
+	if err = validateOptionalUsernameAndEmail(req.Username, req.Email); err != nil {
+		return common.UpdateUserRequest{}, common.Wrap("makeUpdateUserRequest", err)
 	}
 
 	return req, nil
 }
 
 func (h *handler) updateUser(c *gin.Context, request common.UpdateUserRequest) (common.UpdateUserResponse, error) {
-	user := request.ToUserModel()
 
 	// Get user
-	query := "(id = ? OR username = ? OR email = ?) AND deleted = false"
-	if err := h.db.Preload("Details").Where(query, user.ID, user.Username, user.Email).First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return common.UpdateUserResponse{}, common.Wrap(err.Error(), common.ErrUserNotFound)
-		}
-		return common.UpdateUserResponse{}, common.Wrap(err.Error(), common.ErrGettingUser)
+	user, err := h.repos.Users.GetActiveByID(request.UserID)
+	if err != nil {
+		return common.UpdateUserResponse{}, common.Wrap("updateUser: repos.Users.GetActiveByID", err)
+	}
+
+	// The new username and email can't belong to someone else
+	taken, err := h.repos.Users.ExistsByUsernameOrEmailExcept(user.ID, request.Username, request.Email)
+	if err != nil {
+		return common.UpdateUserResponse{}, common.Wrap("updateUser: repos.Users.ExistsByUsernameOrEmailExcept", err)
+	}
+	if taken {
+		return common.UpdateUserResponse{}, common.Wrap("updateUser: taken", common.ErrUsernameOrEmailAlreadyInUse)
 	}
 
 	// Overwrite fields that aren't empty
@@ -65,19 +60,21 @@ func (h *handler) updateUser(c *gin.Context, request common.UpdateUserRequest) (
 		user.Details.LastName = *request.LastName
 	}
 
-	// Update user
-	if err := h.db.Omit("Details").Save(&user).Error; err != nil {
-		if strings.Contains(err.Error(), "Error 1062") { // Duplicate entry for key
-			return common.UpdateUserResponse{}, common.Wrap(err.Error(), common.ErrUsernameOrEmailAlreadyInUse)
+	// Update user and details, both or neither
+	err = h.repos.UnitOfWork.Do(func(repos common.Repositories) error {
+		if err := repos.Users.Update(&user); err != nil {
+			return common.Wrap("repos.Users.Update", err)
 		}
-		return common.UpdateUserResponse{}, common.Wrap(err.Error(), common.ErrUpdatingUser)
-	}
 
-	// Update user details
-	if user.Details.ID != 0 {
-		if err := h.db.Save(&user.Details).Error; err != nil {
-			return common.UpdateUserResponse{}, common.Wrap(err.Error(), common.ErrUpdatingUserDetail)
+		// Users that signed up without a name don't have details yet, this creates them
+		user.Details.UserID = user.ID
+		if err := repos.Users.UpdateDetails(&user.Details); err != nil {
+			return common.Wrap("repos.Users.UpdateDetails", err)
 		}
+		return nil
+	})
+	if err != nil {
+		return common.UpdateUserResponse{}, common.Wrap("updateUser: repos.UnitOfWork.Do", err)
 	}
 
 	return common.UpdateUserResponse{User: user.ToResponseModel()}, nil
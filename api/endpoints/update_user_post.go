@@ -0,0 +1,95 @@
+package endpoints
+
+import (
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) UpdateUserPost(c *gin.Context) {
+	HandleRequest(c, h.makeUpdateUserPostRequest, h.updateUserPost)
+}
+
+func (h *handler) makeUpdateUserPostRequest(c *gin.Context) (req common.UpdateUserPostRequest, err error) {
+
+	if err = c.ShouldBindJSON(&req); err != nil {
+		return common.UpdateUserPostRequest{}, common.Wrap(err.Error(), common.ErrBindingRequest)
+	}
+
+	req.UserID = c.GetInt(contextUserIDKey)
+	if req.UserID == 0 || (req.Title == nil && req.Body == nil && req.Tags == nil && req.Status == nil && req.PublishAt == nil) {
+		return common.UpdateUserPostRequest{}, common.ErrAllFieldsRequired
+	}
+
+	req.PostID = getIntFromPath(c, pathPostIDKey)
+	if req.PostID == 0 {
+		return common.UpdateUserPostRequest{}, common.ErrInvalidValue(pathPostIDKey)
+	}
+
+	if req.Title != nil && *req.Title == "" {
+		return common.UpdateUserPostRequest{}, common.ErrInvalidValue("title")
+	}
+
+	if req.PublishAt != nil && req.Status == nil {
+		scheduled := common.PostStatusScheduled
+		req.Status = &scheduled
+	}
+
+	if req.Status != nil {
+		if err = validatePostStatus(*req.Status, req.PublishAt); err != nil {
+			return common.UpdateUserPostRequest{}, common.Wrap("makeUpdateUserPostRequest: validatePostStatus", err)
+		}
+	}
+
+	if req.Tags != nil {
+		tags, err := common.NormalizeTags(*req.Tags)
+		if err != nil {
+			return common.UpdateUserPostRequest{}, common.Wrap("makeUpdateUserPostRequest: NormalizeTags", err)
+		}
+		req.Tags = &tags
+	}
+
+	return req, nil
+}
+
+func (h *handler) updateUserPost(c *gin.Context, request common.UpdateUserPostRequest) (common.UpdateUserPostResponse, error) {
+
+	// Get post
+	userPost, err := h.repos.Posts.GetUserPost(request.UserID, request.PostID)
+	if err != nil {
+		return common.UpdateUserPostResponse{}, common.Wrap("updateUserPost: repos.Posts.GetUserPost", err)
+	}
+
+	// Update post
+	userPost.OverwriteFields(request.Title, request.Body)
+	if request.Status != nil {
+		userPost.SetStatus(*request.Status, request.PublishAt)
+	}
+	err = h.repos.UnitOfWork.Do(func(repos common.Repositories) error {
+		if err := repos.Posts.Update(&userPost); err != nil {
+			return common.Wrap("repos.Posts.Update", err)
+		}
+
+		// Replace tags
+		if request.Tags != nil {
+			tags, err := repos.Posts.FindOrCreateTags(*request.Tags)
+			if err != nil {
+				return common.Wrap("repos.Posts.FindOrCreateTags", err)
+			}
+			if err := repos.Posts.ReplaceTags(&userPost, tags); err != nil {
+				return common.Wrap("repos.Posts.ReplaceTags", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return common.UpdateUserPostResponse{}, common.Wrap("updateUserPost: repos.UnitOfWork.Do", err)
+	}
+
+	userPosts := common.UserPosts{userPost}
+	if err := h.repos.Posts.LoadCounts(userPosts); err != nil {
+		return common.UpdateUserPostResponse{}, common.Wrap("updateUserPost: repos.Posts.LoadCounts", err)
+	}
+
+	return common.UpdateUserPostResponse{UserPost: userPosts[0].ToResponseModel()}, nil
+}
@@ -0,0 +1,69 @@
+package endpoints
+
+import (
+	"testing"
+
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateUser(t *testing.T) {
+	h, _ := newTestHandler(t)
+	user := signupTestUser(t, h, "alice", "alice@example.com")
+
+	firstName := "Alice"
+	response, err := h.updateUser(newTestContext(user.ID), common.UpdateUserRequest{UserID: user.ID, Username: "alice2", FirstName: &firstName})
+	require.NoError(t, err)
+	assert.Equal(t, "alice2", response.User.Username)
+	assert.Equal(t, "alice@example.com", response.User.Email)
+
+	stored, err := h.repos.Users.GetWithDetails(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "alice2", stored.Username)
+	assert.Equal(t, "Alice", stored.Details.FirstName)
+}
+
+func TestUpdateUser_KeepsOwnUsernameAndEmail(t *testing.T) {
+	h, _ := newTestHandler(t)
+	user := signupTestUser(t, h, "alice", "alice@example.com")
+
+	_, err := h.updateUser(newTestContext(user.ID), common.UpdateUserRequest{UserID: user.ID, Username: "alice", Email: "alice@example.com"})
+	assert.NoError(t, err)
+}
+
+// The username or email of another user must not make the update land on that user
+func TestUpdateUser_UsernameOrEmailOfAnotherUser(t *testing.T) {
+	h, _ := newTestHandler(t)
+	alice := signupTestUser(t, h, "alice", "alice@example.com")
+	bob := signupTestUser(t, h, "bob", "bob@example.com")
+
+	requests := []common.UpdateUserRequest{
+		{UserID: bob.ID, Username: "alice"},
+		{UserID: bob.ID, Email: "alice@example.com"},
+		{UserID: bob.ID, Username: "bob2", Email: "alice@example.com"},
+	}
+	for _, request := range requests {
+		_, err := h.updateUser(newTestContext(bob.ID), request)
+		assert.ErrorIs(t, err, common.ErrUsernameOrEmailAlreadyInUse)
+	}
+
+	stored, err := h.repos.Users.Get(alice.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", stored.Username)
+	assert.Equal(t, "alice@example.com", stored.Email)
+
+	stored, err = h.repos.Users.Get(bob.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "bob", stored.Username)
+}
+
+func TestUpdateUser_DeletedUser(t *testing.T) {
+	h, _ := newTestHandler(t)
+	user := signupTestUser(t, h, "alice", "alice@example.com")
+	require.NoError(t, h.repos.Users.SoftDelete(&common.User{ID: user.ID}))
+
+	_, err := h.updateUser(newTestContext(user.ID), common.UpdateUserRequest{UserID: user.ID, Username: "alice2"})
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+}
@@ -0,0 +1,90 @@
+package endpoints
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *handler) VerifyEmail(c *gin.Context) {
+	HandleRequest(c, h.makeVerifyEmailRequest, h.verifyEmail)
+}
+
+func (h *handler) makeVerifyEmailRequest(c *gin.Context) (req common.VerifyEmailRequest, err error) {
+	req.Token = c.Query("token")
+	if req.Token == "" {
+		return common.VerifyEmailRequest{}, common.ErrAllFieldsRequired
+	}
+
+	return req, nil
+}
+
+func (h *handler) verifyEmail(c *gin.Context, request common.VerifyEmailRequest) (common.VerifyEmailResponse, error) {
+
+	// Get token
+	verificationToken, err := h.repos.Tokens.GetEmailVerificationToken(common.HashOpaqueToken(request.Token))
+	if err != nil {
+		return common.VerifyEmailResponse{}, common.Wrap("verifyEmail: repos.Tokens.GetEmailVerificationToken", err)
+	}
+
+	if !verificationToken.IsUsable() {
+		return common.VerifyEmailResponse{}, common.Wrap("verifyEmail: !verificationToken.IsUsable", common.ErrInvalidEmailVerificationToken)
+	}
+
+	// Mark it as used
+	if err := h.repos.Tokens.UseEmailVerificationToken(verificationToken.ID); err != nil {
+		return common.VerifyEmailResponse{}, common.Wrap("verifyEmail: repos.Tokens.UseEmailVerificationToken", err)
+	}
+
+	// Get user
+	user, err := h.repos.Users.GetActive(verificationToken.UserID)
+	if err != nil {
+		return common.VerifyEmailResponse{}, common.Wrap("verifyEmail: repos.Users.GetActive", err)
+	}
+
+	// Verify user
+	if err := h.repos.Users.UpdateFields(&user, map[string]interface{}{"verified": true}); err != nil {
+		return common.VerifyEmailResponse{}, common.Wrap("verifyEmail: repos.Users.UpdateFields", err)
+	}
+
+	return common.VerifyEmailResponse{User: user.ToResponseModel()}, nil
+}
+
+/*-----------------------
+//       HELPERS
+//---------------------*/
+
+// sendVerificationEmail invalidates the previous verification tokens of the user and emails them a new one
+func (h *handler) sendVerificationEmail(user common.User) error {
+
+	// Previous tokens are no longer valid
+	if err := h.repos.Tokens.InvalidateEmailVerificationTokens(user.ID); err != nil {
+		return common.Wrap("sendVerificationEmail: repos.Tokens.InvalidateEmailVerificationTokens", err)
+	}
+
+	// Generate token and save it hashed
+	tokenString, err := common.GenerateOpaqueToken()
+	if err != nil {
+		return common.Wrap(err.Error(), common.ErrCreatingEmailVerificationToken)
+	}
+
+	verificationToken := common.EmailVerificationToken{
+		UserID:    user.ID,
+		TokenHash: common.HashOpaqueToken(tokenString),
+		ExpiresAt: time.Now().Add(time.Hour * time.Duration(h.config.Emails.VerificationTokenHours)),
+	}
+	if err := h.repos.Tokens.CreateEmailVerificationToken(&verificationToken); err != nil {
+		return common.Wrap("sendVerificationEmail: repos.Tokens.CreateEmailVerificationToken", err)
+	}
+
+	// Send it
+	body := fmt.Sprintf("Hi %s! Verify your email by calling GET /v1/verify-email?token=%s", user.Username, tokenString)
+	if err := h.mailer.Send(user.Email, "Verify your email", body); err != nil {
+		return common.Wrap("sendVerificationEmail: mailer.Send", err)
+	}
+
+	return nil
+}
@@ -45,11 +45,12 @@ func (router *router) setEndpoints(h endpoints.Handler, cfg *common.Config, auth
 
 	// Standard endpoints
 	router.GET("/health", h.HealthCheck)
+	router.GET("/.well-known/jwks.json", h.JWKS)
 
 	// V1
 	v1 := router.Group("/v1")
 	{
-		router.setV1Endpoints(v1, h, authI)
+		router.setV1Endpoints(v1, h, cfg, authI)
 	}
 
 	// Monitoring
@@ -58,11 +59,43 @@ func (router *router) setEndpoints(h endpoints.Handler, cfg *common.Config, auth
 	}
 }
 
-func (router *router) setV1Endpoints(v1 *gin.RouterGroup, h endpoints.Handler, authI common.AuthI) {
+func (router *router) setV1Endpoints(v1 *gin.RouterGroup, h endpoints.Handler, cfg *common.Config, authI common.AuthI) {
 
 	// Auth
 	v1.POST("/signup", h.Signup)
 	v1.POST("/login", h.Login)
+	v1.POST("/login/mfa", h.LoginMFA)
+	v1.POST("/token/refresh", h.RefreshToken)
+	v1.POST("/logout", authI.ValidateToken(common.AnyRole, false), h.Logout)
+	v1.POST("/password/forgot", h.ForgotPassword)
+	v1.POST("/password/reset", h.ResetPassword)
+	v1.GET("/verify-email", h.VerifyEmail)
+	v1.POST("/verify-email/resend", h.ResendVerificationEmail)
+
+	// Public posts feed
+	publicRateLimiter := common.NewIPRateLimiterMiddleware(common.NewIPRateLimiter(cfg.RateLimits.PublicRequestsPerSecond, cfg.RateLimits.PublicBurst))
+	feed := v1.Group("/posts", publicRateLimiter)
+	{
+		feed.GET("", h.GetFeed)
+		feed.GET("/:post_id", h.GetPost)
+
+		// Comments. Anyone can read them, but writing needs a token
+		feed.GET("/:post_id/comments", h.ListPostComments)
+		feed.POST("/:post_id/comments", authI.ValidateToken(common.AnyRole, false), h.CreatePostComment)
+		feed.PATCH("/:post_id/comments/:comment_id", authI.ValidateToken(common.AnyRole, false), h.UpdatePostComment)
+		feed.DELETE("/:post_id/comments/:comment_id", authI.ValidateToken(common.AnyRole, false), h.DeletePostComment)
+
+		// Reactions. Both are idempotent
+		feed.PUT("/:post_id/reactions/:kind", authI.ValidateToken(common.AnyRole, false), h.ReactToPost)
+		feed.DELETE("/:post_id/reactions/:kind", authI.ValidateToken(common.AnyRole, false), h.UnreactToPost)
+	}
+
+	// Tags
+	tags := v1.Group("/tags", publicRateLimiter)
+	{
+		tags.GET("", h.GetTags)
+		tags.GET("/:tag/posts", h.GetTagPosts)
+	}
 
 	// Users
 	users := v1.Group("/users", authI.ValidateToken(common.AnyRole, true))
@@ -72,17 +105,45 @@ func (router *router) setV1Endpoints(v1 *gin.RouterGroup, h endpoints.Handler, a
 		users.DELETE("/:user_id", h.DeleteUser)
 		users.PATCH("/:user_id/password", h.ChangePassword)
 
+		// Personal data
+		users.GET("/:user_id/export", h.ExportUserData)
+		users.GET("/:user_id/export/:export_id", h.GetUserDataExport)
+		users.GET("/:user_id/export/:export_id/download", h.DownloadUserDataExport)
+
+		// Two factor authentication
+		users.POST("/:user_id/2fa/enroll", h.EnrollTOTP)
+		users.POST("/:user_id/2fa/confirm", h.ConfirmTOTP)
+		users.POST("/:user_id/2fa/disable", h.DisableTOTP)
+
 		// User posts
 		posts := users.Group("/:user_id/posts")
 		{
 			posts.POST("", h.CreateUserPost)
+			posts.GET("", h.ListUserPosts)
+			posts.GET("/:post_id", h.GetUserPost)
+			posts.PATCH("/:post_id", h.UpdateUserPost)
+			posts.DELETE("/:post_id", h.DeleteUserPost)
 		}
 	}
 
 	// Admins
-	admin := v1.Group("/admin", authI.ValidateToken(common.AdminRole, false))
+	// Each admin endpoint needs its own permission
+	admin := v1.Group("/admin", authI.ValidateToken(common.AnyRole, false))
 	{
-		admin.POST("/user", h.CreateUser)
-		admin.GET("/users", h.SearchUsers)
+		admin.POST("/user", authI.RequirePermission(common.PermissionCreateUsers), h.CreateUser)
+		admin.GET("/users", authI.RequirePermission(common.PermissionSearchUsers), h.SearchUsers)
+		admin.GET("/users/:user_id", authI.RequirePermission(common.PermissionSearchUsers), h.AdminGetUser)
+		admin.PATCH("/users/:user_id", authI.RequirePermission(common.PermissionManageUsers), h.AdminUpdateUser)
+		admin.DELETE("/users/:user_id", authI.RequirePermission(common.PermissionManageUsers), h.AdminDeleteUser)
+		admin.POST("/users/:user_id/restore", authI.RequirePermission(common.PermissionManageUsers), h.AdminRestoreUser)
+		admin.DELETE("/users/:user_id/hard", authI.RequirePermission(common.PermissionManageUsers), h.AdminHardDeleteUser)
+		admin.POST("/users/:user_id/sessions/revoke", authI.RequirePermission(common.PermissionManageUsers), h.RevokeUserSessions)
+		admin.POST("/users/:user_id/unlock", authI.RequirePermission(common.PermissionManageUsers), h.UnlockUser)
+
+		// Roles
+		admin.GET("/roles", authI.RequirePermission(common.PermissionManageRoles), h.GetRoles)
+		admin.POST("/roles", authI.RequirePermission(common.PermissionManageRoles), h.CreateRole)
+		admin.PUT("/users/:user_id/roles/:role_name", authI.RequirePermission(common.PermissionManageRoles), h.AssignUserRole)
+		admin.DELETE("/users/:user_id/roles/:role_name", authI.RequirePermission(common.PermissionManageRoles), h.UnassignUserRole)
 	}
 }
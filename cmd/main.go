@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"os"
 
 	"github.com/gilperopiola/go-rest-example-small/api"
 	"github.com/gilperopiola/go-rest-example-small/api/common"
@@ -28,6 +29,18 @@ func main() {
 	logger := logrus.New()
 	logger.Info("Logger OK")
 
+	// `migrate up|down|status|create` manages the database schema instead of running the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrateCommand(config, logger, os.Args[2:])
+		return
+	}
+
+	// `seed <file>` loads fixtures instead of running the server
+	if len(os.Args) > 1 && os.Args[1] == "seed" {
+		runSeedCommand(config, logger, os.Args[2:])
+		return
+	}
+
 	middlewares := []gin.HandlerFunc{
 		gin.Recovery(), // Panic recovery
 		common.NewRateLimiterMiddleware(common.NewRateLimiter(200)),                     // Rate Limiter
@@ -39,13 +52,44 @@ func main() {
 	}
 	logger.Info("Middlewares OK")
 
-	auth := common.NewAuth(config.JWTSecret, 7)
-	logger.Info("Auth OK")
-
 	database := common.NewDatabase(config, logger)
 	logger.Info("Database OK")
 
-	handler := endpoints.NewHandler(config, database.DB, auth)
+	auth := common.NewAuth(common.NewKeySet(config), config.Sessions.AccessTokenMinutes, config.MFA.RequiredForAdmins, common.NewRevocationStore(database.DB))
+	logger.Info("Auth OK")
+
+	hasher := common.NewPasswordHasher(config.Passwords, config.HashSalt)
+	logger.Info("Password Hasher OK")
+
+	mailer := common.NewMailer(config.Mail, logger)
+	logger.Info("Mailer OK")
+
+	loginThrottler := common.NewLoginThrottler(config.Lockout, database.DB)
+	logger.Info("Login Throttler OK")
+
+	postScheduler := common.NewPostScheduler(config.Scheduler, config.Monitoring, database.DB, logger)
+	go postScheduler.Run()
+	logger.Info("Post Scheduler OK")
+
+	txOptions, err := config.Database.GetTxOptions()
+	if err != nil {
+		log.Fatalf("error in database config: %v", err)
+	}
+	repositories := common.NewGormRepositories(database.DB, txOptions)
+	logger.Info("Repositories OK")
+
+	userPurger := common.NewUserPurger(config.Deletion, repositories, logger)
+	go userPurger.Run()
+	logger.Info("User Purger OK")
+
+	if err := common.BootstrapAdmin(config.Admin, repositories, hasher, logger); err != nil {
+		log.Fatalf("error creating admin: %v", err)
+	}
+	if config.Seed.File != "" {
+		seedFixtures(config.Seed.File, repositories, hasher, logger)
+	}
+
+	handler := endpoints.NewHandler(config, repositories, auth, hasher, mailer, loginThrottler, logger)
 	logger.Info("Handler OK")
 
 	router := api.NewRouter(handler, config, auth, middlewares...)
@@ -57,8 +101,7 @@ func main() {
 
 	logger.Info("Running server on port " + config.Port)
 
-	err := router.Run(":" + config.Port)
-	if err != nil {
+	if err := router.Run(":" + config.Port); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 
@@ -69,8 +112,6 @@ func main() {
 // - Redis
 // - More tests
 // - Batch insert
-// - Reset password
-// - Roles to DB
 // - Request IDs
 // - Logic from DeleteUser to service layer
 // - Search & Fix TODOs
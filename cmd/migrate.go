@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/sirupsen/logrus"
+)
+
+const migrateUsage = "usage: migrate up | down [steps] | status | create <name>"
+
+// runMigrateCommand handles `go-rest-example migrate ...`. It doesn't start the server.
+func runMigrateCommand(config *common.Config, logger *logrus.Logger, args []string) {
+	if len(args) == 0 {
+		log.Fatal(migrateUsage)
+	}
+
+	// Creating files doesn't need a database
+	if args[0] == "create" {
+		if len(args) != 2 {
+			log.Fatal(migrateUsage)
+		}
+		paths, err := common.CreateMigrationFiles(config.Migrations.Path, args[1])
+		if err != nil {
+			log.Fatalf("error creating migration: %v", err)
+		}
+		for _, path := range paths {
+			fmt.Println("Created " + path)
+		}
+		return
+	}
+
+	database := common.NewDatabaseConnection(config, logger)
+	migrator, err := common.NewSchemaMigrator(config.Migrations, database.DB, logger)
+	if err != nil {
+		log.Fatalf("error loading migrations: %v", err)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
+		if err != nil {
+			log.Fatalf("error applying migrations: %v", err)
+		}
+		fmt.Printf("%d migrations applied\n", applied)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				log.Fatal(migrateUsage)
+			}
+		}
+		reverted, err := migrator.Down(steps)
+		if err != nil {
+			log.Fatalf("error reverting migrations: %v", err)
+		}
+		fmt.Printf("%d migrations reverted\n", reverted)
+
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.Fatalf("error getting migrations status: %v", err)
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if status.ChecksumMismatch {
+				state += " (modified after being applied)"
+			}
+			fmt.Printf("%-16d %-40s %s\n", status.Version, status.Name, state)
+		}
+
+	default:
+		log.Fatal(migrateUsage)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"log"
+
+	"github.com/gilperopiola/go-rest-example-small/api/common"
+
+	"github.com/sirupsen/logrus"
+)
+
+const seedUsage = "usage: seed <file.json|file.yaml>"
+
+// runSeedCommand handles `go-rest-example seed <file>`. It doesn't start the server.
+func runSeedCommand(config *common.Config, logger *logrus.Logger, args []string) {
+	if len(args) != 1 {
+		log.Fatal(seedUsage)
+	}
+
+	txOptions, err := config.Database.GetTxOptions()
+	if err != nil {
+		log.Fatalf("error in database config: %v", err)
+	}
+
+	database := common.NewDatabase(config, logger)
+	repositories := common.NewGormRepositories(database.DB, txOptions)
+	hasher := common.NewPasswordHasher(config.Passwords, config.HashSalt)
+
+	seedFixtures(args[0], repositories, hasher, logger)
+}
+
+// seedFixtures is also used on startup, when there's a seed file in the config
+func seedFixtures(path string, repositories common.Repositories, hasher common.PasswordHasher, logger *logrus.Logger) {
+	fixtures, err := common.LoadFixtures(path)
+	if err != nil {
+		log.Fatalf("error loading fixtures: %v", err)
+	}
+
+	created, err := common.SeedFixtures(fixtures, repositories, hasher)
+	if err != nil {
+		log.Fatalf("error seeding fixtures: %v", err)
+	}
+	logger.Infof("Seed: %d of %d users created, the others already existed", created, len(fixtures.Users))
+}